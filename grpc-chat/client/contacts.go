@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc"
+)
+
+// Payload of the "contact" SSE event
+type contactEvent struct {
+	Kind string `json:"kind"` // requested, accepted, declined or removed
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Signature shared by the contact action RPCs, used as a method expression on the client
+type contactAction func(pb.ChatServiceClient, context.Context, *pb.ContactActionRequest, ...grpc.CallOption) (*pb.ContactActionResponse, error)
+
+// Build a handler for a contact action on the user given by name
+func contactActionHandler(description string, action contactAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientIP := getClientIdentifier(r)
+		username, ok := usernameFor(clientIP)
+		if !ok {
+			http.Error(w, "Not authenticated", http.StatusUnauthorized)
+			return
+		}
+
+		resp, err := action(client, context.Background(), &pb.ContactActionRequest{
+			Username: username,
+			Target:   strings.TrimSpace(r.URL.Query().Get("name")),
+		})
+		if err != nil {
+			log.Printf("Error %s for %s: %v", description, username, err)
+			writeGRPCError(w, err)
+			return
+		}
+
+		writeJSON(w, resp)
+	}
+}
+
+// Handler to list contacts with open incoming and outgoing requests and blocked users
+func contactsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.ListContacts(context.Background(), &pb.ListContactsRequest{Username: username})
+	if err != nil {
+		log.Printf("Error listing contacts for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Payload of the "dm" SSE event
+type directMessageEvent struct {
+	ID          string `json:"id"`
+	Sender      string `json:"sender"`
+	DisplayName string `json:"display_name,omitempty"` // Of the sender
+	Recipient   string `json:"recipient"`
+	Message     string `json:"message"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// Handler to send a private message to a single user
+func directMessageHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	sender, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	recipient := strings.TrimSpace(r.URL.Query().Get("to"))
+	msg := r.URL.Query().Get("message")
+	if recipient == "" || msg == "" {
+		http.Error(w, "Both 'to' and 'message' are required", http.StatusBadRequest)
+		return
+	}
+
+	stream, ok := streamFor(clientIP)
+	if !ok || stream == nil {
+		http.Error(w, "Chat service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	timestamp := time.Now().Format("15:04:05")
+	err := stream.Send(&pb.ChatMessage{
+		Sender:    sender,
+		Message:   msg,
+		Timestamp: timestamp,
+		Recipient: recipient,
+	})
+	if err != nil {
+		log.Printf("Error sending direct message: %v", err)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Direct message sent from %s to %s", sender, recipient)
+
+	writeJSON(w, map[string]interface{}{
+		"success":   true,
+		"sender":    sender,
+		"recipient": recipient,
+		"timestamp": timestamp,
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	pb "grpc-chat/proto"
+)
+
+// Payload of the "edit" and "delete" SSE events
+type messageChangeEvent struct {
+	ID      string `json:"id"`
+	Room    string `json:"room"`
+	Sender  string `json:"sender"`
+	Message string `json:"message,omitempty"` // New text, empty for deletes
+}
+
+// Handler to edit a message by ID
+func editMessageHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.EditMessage(context.Background(), &pb.EditMessageRequest{
+		Username:  username,
+		MessageId: r.URL.Query().Get("id"),
+		Message:   r.URL.Query().Get("message"),
+	})
+	if err != nil {
+		log.Printf("Error editing message for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to delete a message by ID
+func deleteMessageHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.DeleteMessage(context.Background(), &pb.DeleteMessageRequest{
+		Username:  username,
+		MessageId: r.URL.Query().Get("id"),
+	})
+	if err != nil {
+		log.Printf("Error deleting message for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
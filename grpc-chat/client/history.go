@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	pb "grpc-chat/proto"
+)
+
+// Parse an optional integer query parameter, missing values are zero
+func queryInt(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Handler for paginated room history, accepts room, before, after and limit
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	before, err := queryInt(r, "before")
+	if err != nil {
+		http.Error(w, "Invalid 'before' parameter", http.StatusBadRequest)
+		return
+	}
+	after, err := queryInt(r, "after")
+	if err != nil {
+		http.Error(w, "Invalid 'after' parameter", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		http.Error(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := client.GetHistory(context.Background(), &pb.GetHistoryRequest{
+		Username:       username,
+		Room:           normalizeRoom(r.URL.Query().Get("room")),
+		BeforeSequence: before,
+		AfterSequence:  after,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		log.Printf("Error fetching history for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -21,18 +21,60 @@ import (
 	pb "grpc-chat/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var client pb.ChatServiceClient
 var clientPort int
-var messageChannels []chan *pb.ChatMessage
 var baseDir string
-var loggedInUsers = make(map[string]bool)                          // Track logged in users by their IP
-var usernames = make(map[string]string)                            // Maps IP to username
-var userStreams = make(map[string]pb.ChatService_ChatStreamClient) // Each user gets their own stream
+var loggedInUsers = make(map[string]bool)      // Track logged in users by their IP
+var usernames = make(map[string]string)        // Maps IP to username
+var userStreams = make(map[string]*chatStream) // Each user gets their own stream
+var usersMutex sync.RWMutex                    // Guards loggedInUsers, usernames and userStreams
+
+// A client's chat stream. Handlers send on it concurrently, which grpc-go doesn't allow, so sends take turns.
+type chatStream struct {
+	pb.ChatService_ChatStreamClient
+	sendMutex sync.Mutex
+}
+
+func newChatStream(stream pb.ChatService_ChatStreamClient) *chatStream {
+	return &chatStream{ChatService_ChatStreamClient: stream}
+}
 
-// Add a global variable for the active users stream
-var activeUsersStream pb.ChatService_ActiveUsersStreamClient
+func (c *chatStream) Send(msg *pb.ChatMessage) error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	return c.ChatService_ChatStreamClient.Send(msg)
+}
+
+// Get the username a client logged in with
+func usernameFor(clientIP string) (string, bool) {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	username, ok := usernames[clientIP]
+	return username, ok
+}
+
+// Check whether a client is logged in
+func loggedIn(clientIP string) bool {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	return loggedInUsers[clientIP]
+}
+
+// Get the chat stream of a client
+func streamFor(clientIP string) (*chatStream, bool) {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	stream, ok := userStreams[clientIP]
+	return stream, ok
+}
 
 // Add map to track the client session IDs for more reliable identification
 var clientSessions = make(map[string]string) // Maps cookie ID to clientIP
@@ -48,10 +90,9 @@ var (
 var (
 	statusStreams     map[string]pb.ChatService_UpdateStatusClient
 	statusStreamMutex sync.Mutex
-	lastStatusUpdate  time.Time
 )
 
-// Generate a unique ID for messages
+// Generate a content based key for messages that have no server assigned ID yet
 func generateMessageID(sender, message, timestamp string) string {
 	return fmt.Sprintf("%s_%s_%s", sender, message, timestamp)
 }
@@ -155,7 +196,7 @@ func renderHTML(w http.ResponseWriter, r *http.Request) {
 
 	// Check for cookie-based authentication instead of IP - use port-specific cookie
 	cookie, err := r.Cookie(fmt.Sprintf("username_port%d", clientPort))
-	isLoggedIn := loggedInUsers[clientIP] && err == nil && cookie != nil
+	isLoggedIn := loggedIn(clientIP) && err == nil && cookie != nil
 
 	log.Printf("Checking login status for client %s: %v (Cookie: %v)", clientIP, isLoggedIn, cookie != nil)
 
@@ -220,21 +261,24 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a dedicated stream for this client
-	newStream, err := client.ChatStream(context.Background())
+	opened, err := client.ChatStream(context.Background())
 	if err != nil {
 		log.Printf("Failed to create chat stream: %v", err)
 		http.Error(w, "Tidak bisa streaming chat", http.StatusInternalServerError)
 		return
 	}
+	newStream := newChatStream(opened)
 
-	// Store the stream for this specific client
+	// Store the stream and username for this specific client
+	usersMutex.Lock()
 	userStreams[clientIP] = newStream
-
-	// Store username with IP for this client
 	usernames[clientIP] = loginUsername
+	usersMutex.Unlock()
 
 	// Kirim pesan bahwa user bergabung
-	err = newStream.Send(&pb.ChatMessage{Sender: loginUsername, Message: "joined the chat", Timestamp: time.Now().Format("15:04:05")})
+	device := deviceLabel(r)
+	setClientDevice(clientIP, device)
+	err = newStream.Send(&pb.ChatMessage{Sender: loginUsername, Message: "joined the chat", Timestamp: time.Now().Format("15:04:05"), Device: device})
 	if err != nil {
 		log.Printf("Failed to send join message: %v", err)
 		http.Error(w, "Gagal mengirim pesan ke server", http.StatusInternalServerError)
@@ -242,7 +286,9 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Mark user as logged in
+	usersMutex.Lock()
 	loggedInUsers[clientIP] = true
+	usersMutex.Unlock()
 
 	// Set a cookie to track login state with the username - make it port-specific
 	cookie := &http.Cookie{
@@ -255,13 +301,9 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, cookie)
 
 	log.Printf("User %s logged in successfully from client %s", loginUsername, clientIP)
+	usersMutex.RLock()
 	log.Printf("Current logged in users: %v", usernames)
-
-	// Start streaming active users for this client - do this first to ensure immediate display
-	go startActiveUsersStream(loginUsername)
-
-	// Brief delay to allow the active users list to be processed
-	time.Sleep(100 * time.Millisecond)
+	usersMutex.RUnlock()
 
 	// Jalankan goroutine untuk menerima pesan dari this user's stream
 	go receiveMessagesForUser(clientIP, newStream)
@@ -271,10 +313,18 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `{"username":"%s","message":"%s","redirect":true}`, resp.Username, resp.Message)
 }
 
-// New function for message deduplication with thread safety
-func isMessageDuplicate(msg *pb.ChatMessage) bool {
-	// Generate a unique message ID
-	messageID := generateMessageID(msg.Sender, msg.Message, msg.Timestamp)
+// New function for message deduplication with thread safety, scoped per client
+func isMessageDuplicate(clientIP string, msg *pb.ChatMessage) bool {
+	// Events such as edits reuse the ID of the message they refer to
+	if msg.Type != pb.ChatMessage_CHAT {
+		return false
+	}
+
+	// Prefer the server assigned ID, older servers don't send one
+	messageID := clientIP + "|" + generateMessageID(msg.Sender, msg.Message, msg.Timestamp)
+	if msg.Id != "" {
+		messageID = clientIP + "|" + msg.Id
+	}
 
 	// Use mutex to protect map access
 	processMutex.Lock()
@@ -315,7 +365,7 @@ func receiveMessagesForUser(clientIP string, stream pb.ChatService_ChatStreamCli
 
 	for {
 		// Check if client is still logged in
-		if !loggedInUsers[clientIP] {
+		if !loggedIn(clientIP) {
 			log.Printf("Client %s no longer logged in, stopping message receiver", clientIP)
 			return
 		}
@@ -324,47 +374,68 @@ func receiveMessagesForUser(clientIP string, stream pb.ChatService_ChatStreamCli
 		msg, err := stream.Recv()
 		if err != nil {
 			log.Printf("Error receiving message for client %s: %v", clientIP, err)
-			return
+
+			// Reopen the stream and resume from the last seen sequences instead of losing messages
+			if stream = reconnectChatStream(clientIP); stream == nil {
+				return
+			}
+			continue
 		}
 
+		// Track sequences so a reconnect can pick up where this stream left off
+		recordLastSeen(clientIP, msg)
+
 		// Skip duplicate messages
-		if isMessageDuplicate(msg) {
+		if isMessageDuplicate(clientIP, msg) {
 			continue
 		}
 
-		log.Printf("RECEIVED FROM SERVER: [%s] %s: %s", msg.Timestamp, msg.Sender, msg.Message)
+		log.Printf("RECEIVED FROM SERVER: [%s] #%s %s: %s", msg.Timestamp, msg.Room, msg.Sender, msg.Message)
 
-		// Broadcast to active message channels
-		broadcastCount := 0
-		for _, ch := range messageChannels {
-			select {
-			case ch <- msg:
-				broadcastCount++
-			default:
-				log.Printf("Channel buffer full, skipping")
-			}
-		}
-		log.Printf("Message broadcast to %d channels", broadcastCount)
+		// Only this client's browser should see messages from its own stream
+		deliverToClient(clientIP, msg)
+	}
+}
+
+// Push a message to the SSE channel of a single client
+func deliverToClient(clientIP string, msg *pb.ChatMessage) {
+	channelsMutex.RLock()
+	defer channelsMutex.RUnlock()
+
+	ch, ok := clientMessageChannels[clientIP]
+	if !ok {
+		log.Printf("No SSE channel for client %s, dropping message", clientIP)
+		return
+	}
+
+	select {
+	case ch <- msg:
+	default:
+		log.Printf("Channel buffer full for client %s, skipping", clientIP)
 	}
 }
 
 // Handler untuk mengirim pesan
 func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 	msg := r.URL.Query().Get("message")
+	room := normalizeRoom(r.URL.Query().Get("room"))
+	parentID := r.URL.Query().Get("parent") // Set when replying in a thread
 	clientIP := getClientIdentifier(r)
 
 	// Log that we're handling a message
 	log.Printf("Handling message request from client %s", clientIP)
 
 	// Get correct username for this specific client
-	sender, ok := usernames[clientIP]
+	sender, ok := usernameFor(clientIP)
 	if !ok {
 		// Get from cookie as fallback - use port-specific cookie
 		cookie, err := r.Cookie(fmt.Sprintf("username_port%d", clientPort))
 		if err == nil && cookie != nil {
 			sender = cookie.Value
 			// Update the mapping for next time
+			usersMutex.Lock()
 			usernames[clientIP] = sender
+			usersMutex.Unlock()
 			log.Printf("Retrieved username %s from port-specific cookie for client %s", sender, clientIP)
 		} else {
 			// Return an error if we can't identify the user
@@ -374,20 +445,22 @@ func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	log.Printf("Message from %s (Client: %s): %s", sender, clientIP, msg)
+	log.Printf("Message from %s (Client: %s) to room %s: %s", sender, clientIP, room, msg)
 
 	// Get the stream for this client
-	stream, ok := userStreams[clientIP]
+	stream, ok := streamFor(clientIP)
 	if !ok || stream == nil {
 		// Create a new stream if needed
-		var err error
-		stream, err = client.ChatStream(context.Background())
+		opened, err := client.ChatStream(context.Background())
 		if err != nil {
 			log.Printf("Failed to create new stream for %s: %v", clientIP, err)
 			http.Error(w, "Chat service unavailable", http.StatusServiceUnavailable)
 			return
 		}
+		stream = newChatStream(opened)
+		usersMutex.Lock()
 		userStreams[clientIP] = stream
+		usersMutex.Unlock()
 		// Start a receiver for this new stream
 		go receiveMessagesForUser(clientIP, stream)
 	}
@@ -397,16 +470,10 @@ func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 		Sender:    sender,
 		Message:   msg,
 		Timestamp: timestamp,
+		Room:      room,
+		ParentId:  parentID,
 	}
 
-	// Generate message ID for deduplication with proper locking
-	messageID := generateMessageID(sender, msg, timestamp)
-
-	// Use mutex to protect map access
-	processMutex.Lock()
-	processedMessages[messageID] = true
-	processMutex.Unlock()
-
 	// Send the message to the server
 	err := stream.Send(chatMessage)
 	if err != nil {
@@ -418,13 +485,19 @@ func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Message sent successfully from %s with timestamp %s", sender, timestamp)
 
 	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	fmt.Fprintf(w, `{"success":true,"sender":"%s","timestamp":"%s"}`, sender, timestamp)
+	writeJSON(w, map[string]interface{}{
+		"success":   true,
+		"sender":    sender,
+		"timestamp": timestamp,
+		"room":      room,
+	})
 }
 
 // Ensure each client gets a unique message channel to prevent duplicates
-var clientMessageChannels = make(map[string]chan *pb.ChatMessage)
+var (
+	clientMessageChannels = make(map[string]chan *pb.ChatMessage)
+	channelsMutex         sync.RWMutex // Protects clientMessageChannels
+)
 
 // Streaming ke UI untuk semua client
 func streamMessagesHandler(w http.ResponseWriter, r *http.Request) {
@@ -446,26 +519,28 @@ func streamMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	clientIP := getClientIdentifier(r)
 
 	// Get username for this client to verify message ownership
-	clientUsername := usernames[clientIP]
+	clientUsername, _ := usernameFor(clientIP)
 
-	log.Printf("New SSE connection from client %s (username: %s)", clientIP, clientUsername)
+	// Only messages for the room being viewed are forwarded to the browser
+	room := normalizeRoom(r.URL.Query().Get("room"))
 
+	log.Printf("New SSE connection from client %s (username: %s, room: %s)", clientIP, clientUsername, room)
+
+	channelsMutex.Lock()
 	// Check if this client already has a channel and close the old one
 	if existingChannel, found := clientMessageChannels[clientIP]; found {
 		close(existingChannel)
-		// Remove from global channels array if it exists
-		for i, ch := range messageChannels {
-			if ch == existingChannel {
-				messageChannels = append(messageChannels[:i], messageChannels[i+1:]...)
-				break
-			}
-		}
 	}
 
 	// Create new message channel for this client
 	msgChannel := make(chan *pb.ChatMessage, 100)
-	messageChannels = append(messageChannels, msgChannel)
 	clientMessageChannels[clientIP] = msgChannel
+	channelsMutex.Unlock()
+
+	// Reopen the client's active users stream so the new channel starts from a full list
+	if clientUsername != "" {
+		go startActiveUsersStream(clientIP, presenceRequest(clientIP, clientUsername, room))
+	}
 
 	// Test message directly to browser
 	fmt.Fprintf(w, "data: <System> Connection established\n\n")
@@ -489,29 +564,24 @@ func streamMessagesHandler(w http.ResponseWriter, r *http.Request) {
 
 		log.Printf("Client %s disconnected from SSE", clientIP)
 
-		// Safe cleanup with mutex protection
-		delete(clientMessageChannels, clientIP)
-
-		// Find and remove the message channel safely
-		for i, ch := range messageChannels {
-			if ch == msgChannel {
-				// Use safe slice operation
-				if i < len(messageChannels)-1 {
-					messageChannels = append(messageChannels[:i], messageChannels[i+1:]...)
-				} else {
-					messageChannels = messageChannels[:i]
-				}
-				break
-			}
+		// Safe cleanup with mutex protection, a newer connection may already have replaced this channel
+		channelsMutex.Lock()
+		if clientMessageChannels[clientIP] == msgChannel {
+			delete(clientMessageChannels, clientIP)
+			close(msgChannel)
+			// Nobody is left to show presence to until the browser reconnects
+			stopActiveUsersStream(clientIP, false)
 		}
-
-		close(msgChannel)
+		channelsMutex.Unlock()
 	}()
 
 	// Keep-alive ticker with longer interval (reduce frequency of keep-alive messages)
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	// Display names already sent on this connection
+	sentDisplayNames := make(map[string]string)
+
 	// Process messages as they arrive
 	for {
 		select {
@@ -533,8 +603,186 @@ func streamMessagesHandler(w http.ResponseWriter, r *http.Request) {
 				continue // Skip system ping messages
 			}
 
-			// Format as SSE message and send
+			// Typing indicators, for direct conversations or the room being shown
+			if msg.Type == pb.ChatMessage_TYPING {
+				if msg.Recipient == "" && msg.Room != room {
+					continue
+				}
+				if err := writeSSEEvent(w, "typing", typingEvent{
+					Sender:    msg.Sender,
+					Room:      msg.Room,
+					Recipient: msg.Recipient,
+					Typing:    msg.Typing,
+				}); err != nil {
+					log.Printf("Error sending typing event to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Contact requests and changes concern the user, whatever room is shown
+			if msg.Type == pb.ChatMessage_CONTACT && msg.Contact != nil {
+				if err := writeSSEEvent(w, "contact", contactEvent{
+					Kind: strings.ToLower(msg.Contact.Kind.String()),
+					From: msg.Contact.From,
+					To:   msg.Contact.To,
+				}); err != nil {
+					log.Printf("Error sending contact event to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Direct messages are sent as their own event type so the browser can show them apart
+			if msg.Recipient != "" {
+				if err := writeSSEEvent(w, "dm", directMessageEvent{
+					ID:          msg.Id,
+					Sender:      msg.Sender,
+					DisplayName: msg.SenderDisplayName,
+					Recipient:   msg.Recipient,
+					Message:     msg.Message,
+					Timestamp:   msg.Timestamp,
+				}); err != nil {
+					log.Printf("Error sending direct message to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Mentions are delivered whichever room the browser is showing
+			if msg.Type == pb.ChatMessage_MENTION {
+				if err := writeSSEEvent(w, "mention", mentionEvent{
+					ID:          msg.Id,
+					Room:        msg.Room,
+					Sender:      msg.Sender,
+					DisplayName: msg.SenderDisplayName,
+					Message:     msg.Message,
+					Timestamp:   msg.Timestamp,
+					ParentID:    msg.ParentId,
+				}); err != nil {
+					log.Printf("Error sending mention to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Skip messages for other rooms, gateway system messages have no room
+			if msg.Room != "" && msg.Room != room {
+				continue
+			}
+
+			// Edits and deletes update a message the browser already shows
+			if msg.Type == pb.ChatMessage_EDIT || msg.Type == pb.ChatMessage_DELETE {
+				event := "edit"
+				if msg.Type == pb.ChatMessage_DELETE {
+					event = "delete"
+				}
+				if err := writeSSEEvent(w, event, messageChangeEvent{
+					ID:      msg.Id,
+					Room:    msg.Room,
+					Sender:  msg.Sender,
+					Message: msg.Message,
+				}); err != nil {
+					log.Printf("Error sending %s event to client %s: %v", event, clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Thread replies and reply counts stay out of the main timeline
+			if msg.ParentId != "" && msg.Type == pb.ChatMessage_CHAT {
+				if err := writeSSEEvent(w, "reply", threadReplyEvent{
+					ID:          msg.Id,
+					ParentID:    msg.ParentId,
+					Room:        msg.Room,
+					Sender:      msg.Sender,
+					DisplayName: msg.SenderDisplayName,
+					Message:     msg.Message,
+					Timestamp:   msg.Timestamp,
+				}); err != nil {
+					log.Printf("Error sending reply event to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+			if msg.Type == pb.ChatMessage_THREAD_UPDATE {
+				if err := writeSSEEvent(w, "thread", threadUpdateEvent{
+					ID:         msg.Id,
+					Room:       msg.Room,
+					ReplyCount: msg.ReplyCount,
+				}); err != nil {
+					log.Printf("Error sending thread event to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Reaction changes carry the full summary for the message
+			if msg.Type == pb.ChatMessage_REACTION {
+				if err := writeSSEEvent(w, "reaction", reactionEvent{
+					ID:        msg.Id,
+					Room:      msg.Room,
+					Reactions: msg.Reactions,
+				}); err != nil {
+					log.Printf("Error sending reaction event to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Receipts tell the author who received and read their message
+			if msg.Type == pb.ChatMessage_RECEIPT && msg.Receipt != nil {
+				if err := writeSSEEvent(w, "receipt", receiptEvent{
+					ID:          msg.Id,
+					Room:        msg.Room,
+					DeliveredTo: msg.Receipt.DeliveredTo,
+					ReadBy:      msg.Receipt.ReadBy,
+				}); err != nil {
+					log.Printf("Error sending receipt to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Let the browser know part of the room history was lost while disconnected
+			if msg.Type == pb.ChatMessage_HISTORY_GAP {
+				if err := writeSSEEvent(w, "gap", historyGapEvent{
+					Room:     msg.Room,
+					Sequence: msg.Sequence,
+					Message:  msg.Message,
+				}); err != nil {
+					log.Printf("Error sending history gap to client %s: %v", clientIP, err)
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+
+			// Chat lines keep the username, the browser swaps in the display name it was last told about
+			if msg.SenderDisplayName != "" && sentDisplayNames[msg.Sender] != msg.SenderDisplayName {
+				if err := writeSSEEvent(w, "name", displayNameEvent{
+					Username:    msg.Sender,
+					DisplayName: msg.SenderDisplayName,
+				}); err != nil {
+					log.Printf("Error sending display name to client %s: %v", clientIP, err)
+					return
+				}
+				sentDisplayNames[msg.Sender] = msg.SenderDisplayName
+			}
+
+			// Format as SSE message and send, the server ID lets the browser reference the message
 			chatMsg := fmt.Sprintf("data: <%s> %s\n\n", msg.Sender, msg.Message)
+			// Always set the id field, an empty one stops the browser reusing the previous message's ID
+			chatMsg = fmt.Sprintf("id: %s\n%s", msg.Id, chatMsg)
 			log.Printf("Sending to client %s: %s", clientIP, chatMsg)
 
 			_, err := fmt.Fprint(w, chatMsg)
@@ -548,13 +796,23 @@ func streamMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Write a named SSE event with a JSON payload
+func writeSSEEvent(w io.Writer, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
 // Add a logout handler to properly handle user logout
 func logoutHandler(w http.ResponseWriter, r *http.Request) {
 	clientIP := getClientIdentifier(r)
-	loggedInUsername := usernames[clientIP]
+	loggedInUsername, _ := usernameFor(clientIP)
 
 	// Close the gRPC stream for this client if it exists
-	if stream, ok := userStreams[clientIP]; ok && loggedInUsername != "" {
+	if stream, ok := streamFor(clientIP); ok && loggedInUsername != "" {
 		// Try to send a leave message - this will trigger removal from active users
 		log.Printf("Sending leave message for user %s", loggedInUsername)
 		leaveMsg := &pb.ChatMessage{
@@ -572,12 +830,16 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(100 * time.Millisecond)
 	}
 
-	wasLoggedIn := loggedInUsers[clientIP]
-
 	// Remove user from all maps
+	usersMutex.Lock()
+	wasLoggedIn := loggedInUsers[clientIP]
 	delete(loggedInUsers, clientIP)
 	delete(usernames, clientIP)
 	delete(userStreams, clientIP)
+	usersMutex.Unlock()
+	stopActiveUsersStream(clientIP, true)
+	clearLastSeen(clientIP)
+	clearClientDevice(clientIP)
 
 	// Clear the cookie - make it port-specific
 	cookie := &http.Cookie{
@@ -607,7 +869,7 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 // Add a check-session handler
 func checkSessionHandler(w http.ResponseWriter, r *http.Request) {
 	clientIP := getClientIdentifier(r)
-	isLoggedIn := loggedInUsers[clientIP]
+	isLoggedIn := loggedIn(clientIP)
 
 	log.Printf("Session check for %s: logged in = %v", clientIP, isLoggedIn)
 
@@ -690,87 +952,93 @@ func setStandardHeaders(w http.ResponseWriter) {
 	w.Header().Set("Expires", "0")
 }
 
-// Add a function to start streaming active users
-func startActiveUsersStream(username string) {
-	// Close any existing stream first
-	if activeUsersStream != nil {
-		activeUsersStream.CloseSend()
-	}
-
-	var err error
-	log.Printf("Starting active users stream for %s", username)
+// Open an active users stream for a client, replacing the one it had
+func startActiveUsersStream(clientIP string, req *pb.ActiveUsersRequest) {
+	log.Printf("Starting active users stream for %s (client %s) with the %s scope", req.Username, clientIP, req.Scope)
 
 	// Create a stream for active users
-	activeUsersStream, err = client.ActiveUsersStream(context.Background(), &pb.ActiveUsersRequest{
-		Username: username,
-	})
-
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.ActiveUsersStream(ctx, req)
 	if err != nil {
+		cancel()
 		log.Printf("Failed to create active users stream: %v", err)
 		return
 	}
+	sub := &presenceSubscription{clientIP: clientIP, request: req, stream: stream, cancel: cancel}
+
+	// Close any existing stream, which stops its receiver
+	presenceMutex.Lock()
+	if existing, ok := presenceSubs[clientIP]; ok {
+		existing.cancel()
+	}
+	presenceSubs[clientIP] = sub
+	presenceMutex.Unlock()
 
 	// Start a goroutine to receive active user updates
-	go receiveActiveUserUpdates()
+	go receiveActiveUserUpdates(sub)
 }
 
-// Add a function to receive active user updates - remove duplicate stream existence check
-func receiveActiveUserUpdates() {
-	for {
-		// Check if stream exists
-		if activeUsersStream == nil {
-			log.Printf("Active users stream is nil, exiting receiver")
-			return
-		}
+// Receive the updates of an active users stream until it is closed or replaced
+func receiveActiveUserUpdates(sub *presenceSubscription) {
+	defer sub.cancel()
 
+	for {
 		// Receive updates
-		update, err := activeUsersStream.Recv()
+		update, err := sub.stream.Recv()
 		if err != nil {
-			log.Printf("Error receiving active users update: %v", err)
-			if err == io.EOF {
-				// Stream closed normally
-				return
+			if err != io.EOF && status.Code(err) != codes.Canceled {
+				log.Printf("Error receiving active users update for client %s: %v", sub.clientIP, err)
 			}
-			// Try to reconnect after a delay
-			time.Sleep(5 * time.Second)
 			return
 		}
 
-		// Share code for broadcasting messages to all clients
+		// Updates only go to the browser of the client that subscribed
 		broadcastSystemMessage := func(msg string) {
-			for _, ch := range messageChannels {
-				systemMsg := &pb.ChatMessage{
-					Sender:    "System",
-					Message:   msg,
-					Timestamp: time.Now().Format("15:04:05"),
-				}
+			deliverToClient(sub.clientIP, &pb.ChatMessage{
+				Sender:    "System",
+				Message:   msg,
+				Timestamp: time.Now().Format("15:04:05"),
+			})
+		}
 
-				select {
-				case ch <- systemMsg:
-					// Message sent successfully
-				default:
-					log.Printf("Channel buffer full, skipping update")
-				}
-			}
+		// Skip deltas we already have and resync after missed ones
+		update, ok := sub.sequence(update)
+		if !ok {
+			continue
 		}
 
 		// Process the update based on the type
 		switch update.UpdateType {
 		case pb.ActiveUsersUpdate_FULL_LIST:
 			// Full list of active users
-			log.Printf("Received full active users list: %v", update.Users)
+			log.Printf("Received full active users list at version %d: %v", update.Version, update.Users)
 
 			// Create a copy of update.Users to avoid any potential race conditions
 			usersCopy := make([]string, len(update.Users))
 			copy(usersCopy, update.Users)
 
-			// Use the shared function
-			broadcastSystemMessage(fmt.Sprintf("ActiveUsersList: %s", strings.Join(usersCopy, ", ")))
+			// Use the shared function, with statuses when the server sent them
+			if len(update.UserStatuses) > 0 {
+				userStatusesJSON, err := json.Marshal(update.UserStatuses)
+				if err != nil {
+					log.Printf("Error marshaling user statuses: %v", err)
+					return
+				}
+				broadcastSystemMessage(fmt.Sprintf("ActiveUsersList: %s", string(userStatusesJSON)))
+			} else {
+				broadcastSystemMessage(fmt.Sprintf("ActiveUsersList: %s", strings.Join(usersCopy, ", ")))
+			}
+			broadcastCustomStatuses(update, broadcastSystemMessage)
+			broadcastSessions(update, broadcastSystemMessage)
+			broadcastProfiles(update, broadcastSystemMessage)
 
 		case pb.ActiveUsersUpdate_JOIN:
 			// User joined
 			log.Printf("User joined: %s", update.Username)
+			broadcastProfiles(update, broadcastSystemMessage)
 			broadcastSystemMessage(fmt.Sprintf("UserJoined: %s", update.Username))
+			broadcastUserStatus(update, broadcastSystemMessage)
+			broadcastSessions(update, broadcastSystemMessage)
 
 		case pb.ActiveUsersUpdate_LEAVE:
 			// User left
@@ -781,15 +1049,19 @@ func receiveActiveUserUpdates() {
 			// User status changed
 			log.Printf("User status changed: %s now %s", update.Username, update.UserStatuses[update.Username])
 
-			// Konversi status ke format JSON agar dapat dipahami oleh JavaScript client
-			userStatusesJSON, err := json.Marshal(update.UserStatuses)
-			if err != nil {
-				log.Printf("Error marshaling user statuses: %v", err)
-				return
-			}
+			// Only the changed user is sent, the browser merges it into its list
+			broadcastUserStatus(update, broadcastSystemMessage)
+			broadcastSessions(update, broadcastSystemMessage)
+
+		case pb.ActiveUsersUpdate_SESSION_CHANGE:
+			// User opened or closed another session
+			log.Printf("Sessions of %s changed: %v", update.Username, update.Sessions[update.Username])
+			broadcastSessions(update, broadcastSystemMessage)
 
-			// Kirim pesan status update ke client
-			broadcastSystemMessage(fmt.Sprintf("ActiveUsersList: %s", string(userStatusesJSON)))
+		case pb.ActiveUsersUpdate_PROFILE_CHANGE:
+			// User changed their display name or avatar
+			log.Printf("Profile of %s changed: %q", update.Username, update.DisplayNames[update.Username])
+			broadcastProfiles(update, broadcastSystemMessage)
 		}
 	}
 }
@@ -797,15 +1069,21 @@ func receiveActiveUserUpdates() {
 // New handler for status updates
 func statusUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	clientIP := getClientIdentifier(r)
-	username, ok := usernames[clientIP]
+	username, ok := usernameFor(clientIP)
 	if !ok {
 		http.Error(w, "Not authenticated", http.StatusUnauthorized)
 		return
 	}
 
 	status := r.URL.Query().Get("status")
-	if status != "typing" && status != "online" {
-		http.Error(w, "Invalid status. Must be 'typing' or 'online'", http.StatusBadRequest)
+	custom, err := customStatusFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The status may be left out when only the custom status changes
+	if !validStatuses[status] && (status != "" || custom == nil) {
+		http.Error(w, "Invalid status. Must be 'online', 'away', 'busy', 'dnd' or 'invisible'", http.StatusBadRequest)
 		return
 	}
 
@@ -823,6 +1101,7 @@ func statusUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		Username:  username,
 		Status:    status,
 		Timestamp: timestamp,
+		Custom:    custom,
 	})
 
 	if err != nil {
@@ -837,34 +1116,6 @@ func statusUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only keep the stream open briefly for typing statuses
-	// to avoid having too many open streams
-	if status == "typing" {
-		// For typing events, we'll let the stream close after the online status is sent
-		lastStatusUpdate = time.Now()
-
-		// In the background, send "online" after a delay if no more typing
-		go func() {
-			time.Sleep(3 * time.Second)
-
-			// If no status updates in the last 3 seconds, send "online"
-			if time.Since(lastStatusUpdate) >= 3*time.Second {
-				// Try to reuse the same stream
-				if stream, ok := statusStreams[clientIP]; ok {
-					err := stream.Send(&pb.StatusUpdate{
-						Username:  username,
-						Status:    "online",
-						Timestamp: time.Now().Format("15:04:05"),
-					})
-
-					if err != nil {
-						log.Printf("Error sending automatic online status: %v", err)
-					}
-				}
-			}
-		}()
-	}
-
 	// Return success response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -961,6 +1212,35 @@ func main() {
 	http.HandleFunc("/cleanup", cleanupHandler) // Add cleanup handler
 	http.HandleFunc("/ping", pingHandler)       // Add the ping handler
 	http.HandleFunc("/status", statusUpdateHandler)
+	http.HandleFunc("/presence", presenceScopeHandler)
+	http.HandleFunc("/dm", directMessageHandler)
+	http.HandleFunc("/history", historyHandler)
+	http.HandleFunc("/search", searchHandler)
+	http.HandleFunc("/edit", editMessageHandler)
+	http.HandleFunc("/delete", deleteMessageHandler)
+	http.HandleFunc("/react", addReactionHandler)
+	http.HandleFunc("/unreact", removeReactionHandler)
+	http.HandleFunc("/thread", threadHandler)
+	http.HandleFunc("/ack", ackHandler)
+	http.HandleFunc("/receipts", receiptsHandler)
+	http.HandleFunc("/user", userProfileHandler)
+	http.HandleFunc("/typing", typingHandler)
+	http.HandleFunc("/profile", profileHandler)
+	http.HandleFunc("/profile/update", updateProfileHandler)
+	http.HandleFunc("/avatar", avatarHandler)
+	http.HandleFunc("/avatar/upload", uploadAvatarHandler)
+	http.HandleFunc("/contacts", contactsHandler)
+	http.HandleFunc("/contacts/request", contactActionHandler("sending contact request", pb.ChatServiceClient.SendContactRequest))
+	http.HandleFunc("/contacts/accept", contactActionHandler("accepting contact request", pb.ChatServiceClient.AcceptContactRequest))
+	http.HandleFunc("/contacts/decline", contactActionHandler("declining contact request", pb.ChatServiceClient.DeclineContactRequest))
+	http.HandleFunc("/contacts/remove", contactActionHandler("removing contact", pb.ChatServiceClient.RemoveContact))
+	http.HandleFunc("/contacts/block", contactActionHandler("blocking user", pb.ChatServiceClient.BlockUser))
+	http.HandleFunc("/contacts/unblock", contactActionHandler("unblocking user", pb.ChatServiceClient.UnblockUser))
+	http.HandleFunc("/rooms", listRoomsHandler)
+	http.HandleFunc("/rooms/create", createRoomHandler)
+	http.HandleFunc("/rooms/join", joinRoomHandler)
+	http.HandleFunc("/rooms/leave", leaveRoomHandler)
+	http.HandleFunc("/rooms/retention", roomRetentionHandler)
 
 	// Make sure there's no active-users HTTP endpoint here
 
@@ -981,8 +1261,10 @@ func setupSignalHandling() {
 		decrementClientCount()
 
 		// Perform other cleanup like sending "left the chat" messages
+		usersMutex.RLock()
+		defer usersMutex.RUnlock()
 		for clientIP, username := range usernames {
-			if stream, ok := userStreams[clientIP]; ok && username != "" {
+			if stream, ok := streamFor(clientIP); ok && username != "" {
 				log.Printf("Sending leave message for user %s", username)
 				stream.Send(&pb.ChatMessage{
 					Sender:    username,
@@ -0,0 +1,12 @@
+package main
+
+// Payload of the "mention" SSE event
+type mentionEvent struct {
+	ID          string `json:"id"`
+	Room        string `json:"room"`
+	Sender      string `json:"sender"`
+	DisplayName string `json:"display_name,omitempty"` // Of the sender
+	Message     string `json:"message"`
+	Timestamp   string `json:"timestamp"`
+	ParentID    string `json:"parent_id,omitempty"` // Set when the mention is a thread reply
+}
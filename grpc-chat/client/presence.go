@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Statuses accepted by /status
+var validStatuses = map[string]bool{
+	"online":    true,
+	"away":      true,
+	"busy":      true,
+	"dnd":       true,
+	"invisible": true,
+}
+
+// Scopes accepted by /presence
+var presenceScopes = map[string]pb.ActiveUsersRequest_Scope{
+	"all":      pb.ActiveUsersRequest_ALL,
+	"room":     pb.ActiveUsersRequest_ROOM,
+	"contacts": pb.ActiveUsersRequest_CONTACTS,
+	"list":     pb.ActiveUsersRequest_LIST,
+}
+
+// The active users stream of a client and the presence version applied from it. Each stream has a single
+// receiver, replacing the subscription cancels the old stream so its receiver stops.
+type presenceSubscription struct {
+	clientIP string
+	request  *pb.ActiveUsersRequest // Scope of the stream, resync snapshots use it too
+	stream   pb.ChatService_ActiveUsersStreamClient
+	cancel   context.CancelFunc
+	version  atomic.Int64 // Last presence version applied
+}
+
+// Active users stream of every client with a browser connected
+var (
+	presenceSubs  = make(map[string]*presenceSubscription) // Maps client IP to its subscription
+	presenceMutex sync.Mutex                               // Protects presenceSubs
+)
+
+// Build the request for a client's active users stream, keeping the scope it chose.
+// The room scope follows the room the browser is showing.
+func presenceRequest(clientIP, username, room string) *pb.ActiveUsersRequest {
+	req := &pb.ActiveUsersRequest{Username: username}
+
+	presenceMutex.Lock()
+	if sub, ok := presenceSubs[clientIP]; ok && sub.request.Username == username {
+		req.Scope = sub.request.Scope
+		req.Usernames = sub.request.Usernames
+	}
+	presenceMutex.Unlock()
+
+	if req.Scope == pb.ActiveUsersRequest_ROOM {
+		req.Room = room
+	}
+	return req
+}
+
+// Close the active users stream of a client. Its scope is kept for when the browser reconnects,
+// unless forget is set, e.g. when the client logs out.
+func stopActiveUsersStream(clientIP string, forget bool) {
+	presenceMutex.Lock()
+	defer presenceMutex.Unlock()
+
+	if sub, ok := presenceSubs[clientIP]; ok {
+		sub.cancel()
+		if forget {
+			delete(presenceSubs, clientIP)
+		}
+	}
+}
+
+// Browser payload for a change to a single user's status
+type userStatusEvent struct {
+	Username string           `json:"username"`
+	Status   string           `json:"status"`
+	Custom   *pb.CustomStatus `json:"custom,omitempty"`
+}
+
+// Order an update against the presence version already applied. Deltas and resync snapshots older
+// than what we have are skipped, and a delta that follows missed ones is replaced by a fresh snapshot.
+func (sub *presenceSubscription) sequence(update *pb.ActiveUsersUpdate) (*pb.ActiveUsersUpdate, bool) {
+	applied := sub.version.Load()
+	if update.UpdateType == pb.ActiveUsersUpdate_FULL_LIST {
+		// The first list on a stream has no previous version and is always taken, the server may have restarted
+		if update.PreviousVersion > 0 && update.Version < applied {
+			return nil, false
+		}
+		sub.version.Store(update.Version)
+		return update, true
+	}
+	if update.Version <= applied {
+		return nil, false
+	}
+
+	if update.PreviousVersion > applied {
+		log.Printf("Missed presence updates between versions %d and %d, fetching a snapshot", applied, update.PreviousVersion)
+		snapshot, err := client.GetActiveUsers(context.Background(), sub.request)
+		if err != nil {
+			// Apply the delta anyway, keeping the old version makes the next delta retry the snapshot
+			log.Printf("Error fetching active users snapshot: %v", err)
+			return update, true
+		}
+		update = snapshot
+	}
+
+	sub.version.Store(update.Version)
+	return update, true
+}
+
+// Handler to choose whose presence a client follows, accepts scope (all, room, contacts or list),
+// room for the room scope and users, comma separated, for the list scope
+func presenceScopeHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	scopeName := query.Get("scope")
+	if scopeName == "" {
+		scopeName = "all"
+	}
+	scope, ok := presenceScopes[scopeName]
+	if !ok {
+		http.Error(w, "Invalid scope. Must be 'all', 'room', 'contacts' or 'list'", http.StatusBadRequest)
+		return
+	}
+	req := &pb.ActiveUsersRequest{Username: username, Scope: scope}
+	switch scope {
+	case pb.ActiveUsersRequest_ROOM:
+		req.Room = normalizeRoom(query.Get("room"))
+	case pb.ActiveUsersRequest_LIST:
+		for _, user := range strings.Split(query.Get("users"), ",") {
+			if user = strings.TrimSpace(user); user != "" {
+				req.Usernames = append(req.Usernames, user)
+			}
+		}
+	}
+
+	// The snapshot checks the scope, the stream would only fail once it is read
+	snapshot, err := client.GetActiveUsers(context.Background(), req)
+	if err != nil {
+		log.Printf("Error changing the presence scope of %s to %s: %v", username, scopeName, err)
+		writeGRPCError(w, err)
+		return
+	}
+	startActiveUsersStream(clientIP, req)
+
+	writeJSON(w, snapshot)
+}
+
+// Pass the status of the user in a delta on to the browsers as a system message
+func broadcastUserStatus(update *pb.ActiveUsersUpdate, broadcast func(string)) {
+	status, ok := update.UserStatuses[update.Username]
+	if !ok {
+		return
+	}
+	statusJSON, err := json.Marshal(userStatusEvent{
+		Username: update.Username,
+		Status:   status,
+		Custom:   update.CustomStatuses[update.Username],
+	})
+	if err != nil {
+		log.Printf("Error marshaling user status: %v", err)
+		return
+	}
+	broadcast(fmt.Sprintf("UserStatus: %s", string(statusJSON)))
+}
+
+// Read the custom status parameters of a /status request.
+// Returns nil when the request leaves the custom status alone, clear=true returns an empty one.
+func customStatusFromRequest(r *http.Request) (*pb.CustomStatus, error) {
+	query := r.URL.Query()
+	if query.Get("clear") == "true" {
+		return &pb.CustomStatus{}, nil
+	}
+
+	text := strings.TrimSpace(query.Get("text"))
+	emoji := strings.TrimSpace(query.Get("emoji"))
+	expires := query.Get("expires")
+	if text == "" && emoji == "" {
+		if expires != "" {
+			return nil, fmt.Errorf("'expires' needs a custom status text or emoji")
+		}
+		return nil, nil
+	}
+
+	custom := &pb.CustomStatus{Text: text, Emoji: emoji}
+	if expires != "" {
+		// Expiry is given as a duration like 30m or 2h
+		duration, err := time.ParseDuration(expires)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid 'expires' parameter %q", expires)
+		}
+		custom.ExpiresAt = time.Now().Add(duration).Unix()
+	}
+	return custom, nil
+}
+
+// Pass the custom statuses of an update on to the browsers as a system message
+func broadcastCustomStatuses(update *pb.ActiveUsersUpdate, broadcast func(string)) {
+	customJSON, err := json.Marshal(update.CustomStatuses)
+	if err != nil {
+		log.Printf("Error marshaling custom statuses: %v", err)
+		return
+	}
+	broadcast(fmt.Sprintf("CustomStatuses: %s", string(customJSON)))
+}
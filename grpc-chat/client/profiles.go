@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	pb "grpc-chat/proto"
+)
+
+const (
+	maxAvatarUpload = 1 << 20 // Must match the server limit
+	avatarChunkSize = 32 * 1024
+)
+
+// Display name and avatar version of a user, sent to the browser keyed by username
+type profileSummary struct {
+	DisplayName   string `json:"display_name"`
+	AvatarVersion int64  `json:"avatar_version,omitempty"` // Unix time of the upload, used to bust the image cache
+}
+
+// Payload of the "name" SSE event, sent before a chat line whose sender has a new display name
+type displayNameEvent struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// Pass the display names and avatars of the users in an update on to the browsers as a system message
+func broadcastProfiles(update *pb.ActiveUsersUpdate, broadcast func(string)) {
+	if len(update.DisplayNames) == 0 {
+		return
+	}
+	profiles := make(map[string]profileSummary, len(update.DisplayNames))
+	for user, name := range update.DisplayNames {
+		profiles[user] = profileSummary{
+			DisplayName:   name,
+			AvatarVersion: update.AvatarVersions[user],
+		}
+	}
+	profilesJSON, err := json.Marshal(profiles)
+	if err != nil {
+		log.Printf("Error marshaling profiles: %v", err)
+		return
+	}
+	broadcast(fmt.Sprintf("Profiles: %s", string(profilesJSON)))
+}
+
+// Handler to get the profile of a user, accepts name and defaults to the logged in user
+func profileHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = username
+	}
+
+	resp, err := client.GetProfile(context.Background(), &pb.GetProfileRequest{Username: name})
+	if err != nil {
+		log.Printf("Error fetching profile of %s for %s: %v", name, username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to change the display name or bio, parameters that are left out stay unchanged
+func updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	req := &pb.UpdateProfileRequest{Username: username}
+	query := r.URL.Query()
+	if _, ok := query["display_name"]; ok {
+		displayName := query.Get("display_name")
+		req.DisplayName = &displayName
+	}
+	if _, ok := query["bio"]; ok {
+		bio := query.Get("bio")
+		req.Bio = &bio
+	}
+	if req.DisplayName == nil && req.Bio == nil {
+		http.Error(w, "Nothing to update, pass 'display_name' or 'bio'", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := client.UpdateProfile(context.Background(), req)
+	if err != nil {
+		log.Printf("Error updating profile of %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to upload an avatar, the request body is the image itself and is streamed to the server in chunks
+func uploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stream, err := client.UploadAvatar(context.Background())
+	if err != nil {
+		log.Printf("Error starting avatar upload for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxAvatarUpload)
+	buf := make([]byte, avatarChunkSize)
+	first := true
+	for {
+		n, readErr := io.ReadFull(body, buf)
+		if n > 0 || first {
+			chunk := &pb.AvatarChunk{Data: append([]byte(nil), buf[:n]...)}
+			if first {
+				chunk.Username = username
+				first = false
+			}
+			if err := stream.Send(chunk); err != nil {
+				// The server ended the stream early, the reason comes back from CloseAndRecv
+				break
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			stream.CloseSend()
+			var tooLarge *http.MaxBytesError
+			if errors.As(readErr, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Avatar is larger than %d bytes", maxAvatarUpload), http.StatusRequestEntityTooLarge)
+				return
+			}
+			log.Printf("Error reading avatar upload from %s: %v", username, readErr)
+			http.Error(w, "Failed to read avatar", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		log.Printf("Error uploading avatar for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to serve the avatar of a user, accepts name.
+// Browsers add the avatar version to the URL, so the image can be cached.
+func avatarHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	if _, ok := usernameFor(clientIP); !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	stream, err := client.GetAvatar(r.Context(), &pb.GetProfileRequest{Username: name})
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	// Errors such as a missing avatar arrive with the first chunk, before anything is written
+	chunk, err := stream.Recv()
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", chunk.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	for {
+		if _, err := w.Write(chunk.Data); err != nil {
+			log.Printf("Error sending avatar of %s: %v", name, err)
+			return
+		}
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Printf("Error receiving avatar of %s: %v", name, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	pb "grpc-chat/proto"
+)
+
+// Payload of the "reaction" SSE event
+type reactionEvent struct {
+	ID        string         `json:"id"`
+	Room      string         `json:"room"`
+	Reactions []*pb.Reaction `json:"reactions"`
+}
+
+// Handler to react to a message with an emoji
+func addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	changeReactionHandler(w, r, true)
+}
+
+// Handler to remove an emoji reaction from a message
+func removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	changeReactionHandler(w, r, false)
+}
+
+// Shared implementation of the reaction handlers
+func changeReactionHandler(w http.ResponseWriter, r *http.Request, add bool) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	req := &pb.ReactionRequest{
+		Username:  username,
+		MessageId: r.URL.Query().Get("id"),
+		Emoji:     r.URL.Query().Get("emoji"),
+	}
+
+	var resp *pb.MessageActionResponse
+	var err error
+	if add {
+		resp, err = client.AddReaction(context.Background(), req)
+	} else {
+		resp, err = client.RemoveReaction(context.Background(), req)
+	}
+	if err != nil {
+		log.Printf("Error changing reaction for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
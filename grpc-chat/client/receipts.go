@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	pb "grpc-chat/proto"
+)
+
+// Payload of the "receipt" SSE event
+type receiptEvent struct {
+	ID          string   `json:"id"`
+	Room        string   `json:"room"`
+	DeliveredTo []string `json:"delivered_to"`
+	ReadBy      []string `json:"read_by"`
+}
+
+// Handler to acknowledge messages in a room, accepts room, id or seq, and type=delivered|read
+func ackHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	sequence, err := queryInt(r, "seq")
+	if err != nil {
+		http.Error(w, "Invalid 'seq' parameter", http.StatusBadRequest)
+		return
+	}
+
+	ackType := pb.AckRequest_DELIVERED
+	switch r.URL.Query().Get("type") {
+	case "", "delivered":
+	case "read":
+		ackType = pb.AckRequest_READ
+	default:
+		http.Error(w, "Invalid 'type' parameter, expected delivered or read", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := client.AckMessages(context.Background(), &pb.AckRequest{
+		Username:  username,
+		Room:      normalizeRoom(r.URL.Query().Get("room")),
+		MessageId: r.URL.Query().Get("id"),
+		Sequence:  sequence,
+		Type:      ackType,
+	})
+	if err != nil {
+		log.Printf("Error acknowledging messages for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to query the receipts of messages, ids is a comma separated list
+func receiptsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var ids []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "Missing 'ids' parameter", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := client.GetReceipts(context.Background(), &pb.GetReceiptsRequest{
+		Username:   username,
+		MessageIds: ids,
+	})
+	if err != nil {
+		log.Printf("Error fetching receipts for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp.Receipts)
+}
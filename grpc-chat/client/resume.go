@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Reconnect attempts made before giving up on a dropped chat stream
+const maxReconnectAttempts = 5
+
+// Payload of the "gap" SSE event
+type historyGapEvent struct {
+	Room     string `json:"room"`
+	Sequence int64  `json:"sequence"` // First sequence still available, zero when not known
+	Message  string `json:"message"`
+}
+
+// Track the last sequence seen per room for every client so a dropped stream can resume
+var (
+	lastSeenSequences = make(map[string]map[string]int64) // Maps client to room to sequence
+	lastSeenMutex     sync.Mutex
+)
+
+// Remember the highest sequence received for a room
+func recordLastSeen(clientIP string, msg *pb.ChatMessage) {
+	if msg.Room == "" || msg.Sequence == 0 || msg.Type != pb.ChatMessage_CHAT {
+		return
+	}
+
+	lastSeenMutex.Lock()
+	defer lastSeenMutex.Unlock()
+
+	rooms, ok := lastSeenSequences[clientIP]
+	if !ok {
+		rooms = make(map[string]int64)
+		lastSeenSequences[clientIP] = rooms
+	}
+	if msg.Sequence > rooms[msg.Room] {
+		rooms[msg.Room] = msg.Sequence
+	}
+}
+
+// Get a copy of the last seen sequences of a client
+func lastSeenFor(clientIP string) map[string]int64 {
+	lastSeenMutex.Lock()
+	defer lastSeenMutex.Unlock()
+
+	rooms := make(map[string]int64, len(lastSeenSequences[clientIP]))
+	for room, seq := range lastSeenSequences[clientIP] {
+		rooms[room] = seq
+	}
+	return rooms
+}
+
+// Forget the resume state of a client, used on logout
+func clearLastSeen(clientIP string) {
+	lastSeenMutex.Lock()
+	delete(lastSeenSequences, clientIP)
+	lastSeenMutex.Unlock()
+}
+
+// Open a new chat stream after the old one dropped and resume every joined room from the last seen sequence.
+// Rooms without one, e.g. after logging in again, get their recent messages and a gap notice.
+// Returns nil if the client logged out or the server stayed unreachable.
+func reconnectChatStream(clientIP string) pb.ChatService_ChatStreamClient {
+	delay := time.Second
+
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		time.Sleep(delay)
+		delay *= 2
+
+		username, ok := usernameFor(clientIP)
+		if !loggedIn(clientIP) || !ok {
+			return nil
+		}
+
+		log.Printf("Reconnecting chat stream for %s (attempt %d/%d)", username, attempt, maxReconnectAttempts)
+
+		stream, err := client.ChatStream(context.Background())
+		if err != nil {
+			log.Printf("Failed to reopen chat stream for %s: %v", username, err)
+			continue
+		}
+
+		joined, err := client.ListRooms(context.Background(), &pb.ListRoomsRequest{Username: username})
+		if err != nil {
+			log.Printf("Failed to list rooms of %s: %v", username, err)
+			stream.CloseSend()
+			continue
+		}
+		lastSeen := lastSeenFor(clientIP)
+
+		// Ask for everything missed before announcing ourselves, so the replay arrives in order
+		resumeFailed := false
+		var unknown []string
+		for _, info := range joined.Rooms {
+			room := info.Name
+			seq := lastSeen[room]
+			if seq == 0 {
+				unknown = append(unknown, room)
+			}
+			err = stream.Send(&pb.ChatMessage{
+				Sender:    username,
+				Room:      room,
+				Sequence:  seq,
+				Type:      pb.ChatMessage_HISTORY_REQUEST,
+				Timestamp: time.Now().Format("15:04:05"),
+			})
+			if err != nil {
+				resumeFailed = true
+				break
+			}
+			log.Printf("Resuming %s in room %s after sequence %d", username, room, seq)
+		}
+		if !resumeFailed {
+			err = stream.Send(&pb.ChatMessage{Sender: username, Message: "joined the chat", Timestamp: time.Now().Format("15:04:05"), Device: clientDeviceFor(clientIP)})
+		}
+		if err != nil {
+			log.Printf("Failed to resume chat stream for %s: %v", username, err)
+			stream.CloseSend()
+			continue
+		}
+
+		// The client may have logged out, or in again with a new stream, while this one was opened
+		usersMutex.Lock()
+		if !loggedInUsers[clientIP] || usernames[clientIP] != username {
+			usersMutex.Unlock()
+			stream.CloseSend()
+			return nil
+		}
+		resumed := newChatStream(stream)
+		userStreams[clientIP] = resumed
+		usersMutex.Unlock()
+
+		// Nothing tells where these rooms were left, only their recent messages come back
+		for _, room := range unknown {
+			deliverToClient(clientIP, &pb.ChatMessage{
+				Sender:    "System",
+				Room:      room,
+				Type:      pb.ChatMessage_HISTORY_GAP,
+				Message:   fmt.Sprintf("Messages sent in %s while the connection was down may be missing, only the latest are shown", room),
+				Timestamp: time.Now().Format("15:04:05"),
+			})
+		}
+		return resumed
+	}
+
+	log.Printf("Giving up reconnecting chat stream for client %s", clientIP)
+	return nil
+}
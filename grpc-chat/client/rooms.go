@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Room used when a request doesn't name one, must match the server
+const defaultRoom = "general"
+
+// Map an empty room parameter to the default room
+func normalizeRoom(room string) string {
+	room = strings.ToLower(strings.TrimSpace(room))
+	if room == "" {
+		return defaultRoom
+	}
+	return room
+}
+
+// Translate a gRPC error into the closest HTTP status code
+func httpStatusFromError(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Write an error returned by the chat server back to the browser
+func writeGRPCError(w http.ResponseWriter, err error) {
+	http.Error(w, status.Convert(err).Message(), httpStatusFromError(err))
+}
+
+// Write a JSON response with the standard no-cache headers
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	setStandardHeaders(w)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// Handler to list rooms, pass mine=true to only list joined rooms
+func listRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	req := &pb.ListRoomsRequest{}
+	if r.URL.Query().Get("mine") == "true" {
+		req.Username = username
+	}
+
+	resp, err := client.ListRooms(context.Background(), req)
+	if err != nil {
+		log.Printf("Error listing rooms for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp.Rooms)
+}
+
+// Handler to create a new room
+func createRoomHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.CreateRoom(context.Background(), &pb.CreateRoomRequest{
+		Username:    username,
+		Room:        r.URL.Query().Get("room"),
+		Description: r.URL.Query().Get("description"),
+	})
+	if err != nil {
+		log.Printf("Error creating room for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to join an existing room
+func joinRoomHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.JoinRoom(context.Background(), &pb.RoomRequest{
+		Username: username,
+		Room:     r.URL.Query().Get("room"),
+	})
+	if err != nil {
+		log.Printf("Error joining room for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Handler to leave a room
+func leaveRoomHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.LeaveRoom(context.Background(), &pb.RoomRequest{
+		Username: username,
+		Room:     r.URL.Query().Get("room"),
+	})
+	if err != nil {
+		log.Printf("Error leaving room for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// Parse a retention age like "30d" or "12h", days aren't understood by time.ParseDuration
+func parseRetentionAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days %q", days)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Handler to change how long a room keeps messages, accepts room, max_age, max_messages, forever and reset
+func roomRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	var policy *pb.RetentionPolicy
+	if query.Get("reset") != "true" {
+		policy = &pb.RetentionPolicy{KeepForever: query.Get("forever") == "true"}
+		if value := query.Get("max_age"); value != "" {
+			age, err := parseRetentionAge(value)
+			if err != nil {
+				http.Error(w, "Invalid 'max_age' parameter", http.StatusBadRequest)
+				return
+			}
+			policy.MaxAgeSeconds = int64(age / time.Second)
+		}
+		count, err := queryInt(r, "max_messages")
+		if err != nil {
+			http.Error(w, "Invalid 'max_messages' parameter", http.StatusBadRequest)
+			return
+		}
+		policy.MaxMessages = int32(count)
+	}
+
+	resp, err := client.SetRoomRetention(context.Background(), &pb.SetRoomRetentionRequest{
+		Username:  username,
+		Room:      query.Get("room"),
+		Retention: policy,
+	})
+	if err != nil {
+		log.Printf("Error setting retention for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Parse an optional time query parameter given as Unix seconds or a 2006-01-02 date, missing values are zero
+func queryTime(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return unix, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return 0, err
+	}
+	return date.Unix(), nil
+}
+
+// Handler for message search, accepts q, sender, room, since, until, limit and page
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	since, err := queryTime(r, "since")
+	if err != nil {
+		http.Error(w, "Invalid 'since' parameter", http.StatusBadRequest)
+		return
+	}
+	until, err := queryTime(r, "until")
+	if err != nil {
+		http.Error(w, "Invalid 'until' parameter", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		http.Error(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	resp, err := client.SearchMessages(context.Background(), &pb.SearchMessagesRequest{
+		Username:  username,
+		Query:     query.Get("q"),
+		Sender:    query.Get("sender"),
+		Room:      query.Get("room"),
+		Since:     since,
+		Until:     until,
+		Limit:     int32(limit),
+		PageToken: query.Get("page"),
+	})
+	if err != nil {
+		log.Printf("Error searching messages for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	pb "grpc-chat/proto"
+)
+
+// Device description of every client, sent with its join messages
+var (
+	clientDevices     = make(map[string]string)
+	clientDevicesLock sync.Mutex
+)
+
+// Describe the browser and OS of a request, like "Firefox on Linux"
+func deviceLabel(r *http.Request) string {
+	agent := r.UserAgent()
+
+	browser := "Browser"
+	switch {
+	case strings.Contains(agent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(agent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(agent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(agent, "Safari/"):
+		browser = "Safari"
+	}
+
+	system := "unknown OS"
+	switch {
+	case strings.Contains(agent, "Android"):
+		system = "Android"
+	case strings.Contains(agent, "iPhone"), strings.Contains(agent, "iPad"):
+		system = "iOS"
+	case strings.Contains(agent, "Windows"):
+		system = "Windows"
+	case strings.Contains(agent, "Mac OS"):
+		system = "macOS"
+	case strings.Contains(agent, "Linux"):
+		system = "Linux"
+	}
+
+	return fmt.Sprintf("%s on %s (gateway :%d)", browser, system, clientPort)
+}
+
+// Remember the device of a client
+func setClientDevice(clientIP, device string) {
+	clientDevicesLock.Lock()
+	clientDevices[clientIP] = device
+	clientDevicesLock.Unlock()
+}
+
+// Get the device of a client, empty if unknown
+func clientDeviceFor(clientIP string) string {
+	clientDevicesLock.Lock()
+	defer clientDevicesLock.Unlock()
+	return clientDevices[clientIP]
+}
+
+// Forget the device of a client, used on logout
+func clearClientDevice(clientIP string) {
+	clientDevicesLock.Lock()
+	delete(clientDevices, clientIP)
+	clientDevicesLock.Unlock()
+}
+
+// Pass the sessions of an update on to the browsers as a system message
+func broadcastSessions(update *pb.ActiveUsersUpdate, broadcast func(string)) {
+	if len(update.Sessions) == 0 {
+		return
+	}
+	sessionsJSON, err := json.Marshal(update.Sessions)
+	if err != nil {
+		log.Printf("Error marshaling sessions: %v", err)
+		return
+	}
+	broadcast(fmt.Sprintf("Sessions: %s", string(sessionsJSON)))
+}
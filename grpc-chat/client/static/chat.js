@@ -4,8 +4,20 @@ let lastMessage = "";
 
 // Add these variables for status tracking below existing variables at the top
 let typingTimer;
-const TYPING_DELAY = 1000; // 1 second delay before reverting to "online"
+const TYPING_IDLE = 4000; // Stop typing after 4 seconds without a keystroke
+const TYPING_REFRESH = 3000; // Repeat start while typing, the server forgets indicators after 6 seconds
+let typingTarget = null; // Room or "@user" we last told the server we're typing in
+let typingSentAt = 0;
+let typingUsers = new Map(); // Users typing in the current room or to us, cleared by the server's stop events
 let lastStatusSent = "online"; // Track last status to avoid sending duplicates
+let myPresence = "online"; // Presence chosen with /status
+let customStatuses = {}; // Custom status text and emoji per user
+let userSessions = {}; // Session count and devices per user
+let displayNames = {}; // Display name per username, from presence and "name" events
+let avatarVersions = {}; // Avatar upload time per username, users without an avatar are left out
+
+// Room currently shown in the chat box, remembered across page reloads
+let currentRoom = localStorage.getItem('chat_room') || "general";
 
 // Boot sequence text
 const bootText = `
@@ -141,7 +153,12 @@ function startChat() {
     myMessagesPrefix = `client_${Math.random().toString(36).substring(2, 10)}`;
     console.log("Generated unique message tracking prefix:", myMessagesPrefix);
     
-    eventSource = new EventSource(`/stream?t=${timestamp}&clientId=${clientId}`);
+    eventSource = new EventSource(`/stream?t=${timestamp}&clientId=${clientId}&room=${encodeURIComponent(currentRoom)}`);
+    
+    // Load recent context for the room, it is inserted above any live messages
+    oldestSequence = 0;
+    hasMoreHistory = true;
+    loadHistory();
     
     // Debug any messages coming through
     eventSource.addEventListener('message', function(event) {
@@ -167,6 +184,63 @@ function startChat() {
                 return;
             }
             
+            // Handle custom statuses sent along with the users list
+            if (systemMessage.startsWith("CustomStatuses:")) {
+                try {
+                    customStatuses = JSON.parse(systemMessage.replace("CustomStatuses:", "").trim()) || {};
+                } catch (e) {
+                    console.error("Error parsing custom statuses:", e);
+                }
+                updateActiveUsersList();
+                return;
+            }
+            
+            // Handle session counts, only the users in the update are replaced
+            if (systemMessage.startsWith("Sessions:")) {
+                try {
+                    Object.assign(userSessions, JSON.parse(systemMessage.replace("Sessions:", "").trim()) || {});
+                } catch (e) {
+                    console.error("Error parsing sessions:", e);
+                }
+                updateActiveUsersList();
+                return;
+            }
+            
+            // Handle display names and avatars, only the users in the update are replaced
+            if (systemMessage.startsWith("Profiles:")) {
+                try {
+                    const profiles = JSON.parse(systemMessage.replace("Profiles:", "").trim()) || {};
+                    for (const [user, profile] of Object.entries(profiles)) {
+                        displayNames[user] = profile.display_name || user;
+                        if (profile.avatar_version) {
+                            avatarVersions[user] = profile.avatar_version;
+                        } else {
+                            delete avatarVersions[user];
+                        }
+                    }
+                } catch (e) {
+                    console.error("Error parsing profiles:", e);
+                }
+                updateActiveUsersList();
+                return;
+            }
+            
+            // Handle a status change of a single user, which also brings back users that stop being invisible
+            if (systemMessage.startsWith("UserStatus:")) {
+                try {
+                    const change = JSON.parse(systemMessage.replace("UserStatus:", "").trim());
+                    if (change.custom) {
+                        customStatuses[change.username] = change.custom;
+                    } else {
+                        delete customStatuses[change.username];
+                    }
+                    addActiveUser(change.username, change.status);
+                } catch (e) {
+                    console.error("Error parsing user status:", e);
+                }
+                return;
+            }
+            
             // Handle user joined notification
             if (systemMessage.startsWith("UserJoined:")) {
                 const joinedUser = systemMessage.replace("UserJoined:", "").trim();
@@ -203,7 +277,75 @@ function startChat() {
         }
         
         // Add to chat display with proper ownership flag
-        addMessageToChat(msg, false, isOwnMessage);
+        // The gateway sets the SSE id to the server assigned message ID
+        addMessageToChat(msg, false, isOwnMessage, event.lastEventId || null);
+        if (!isOwnMessage && event.lastEventId) {
+            scheduleAck(event.lastEventId);
+        }
+    });
+    
+    // Direct messages arrive as their own event type
+    eventSource.addEventListener('dm', function(event) {
+        const dm = JSON.parse(event.data);
+        console.log("Direct message received:", dm);
+        addDirectMessageToChat(dm);
+    });
+    
+    // Edits and deletes change a message already in the chat box
+    eventSource.addEventListener('edit', function(event) {
+        const change = JSON.parse(event.data);
+        updateMessageInChat(change.id, change.sender, change.message);
+    });
+    eventSource.addEventListener('delete', function(event) {
+        const change = JSON.parse(event.data);
+        updateMessageInChat(change.id, change.sender, null);
+    });
+    eventSource.addEventListener('reaction', function(event) {
+        const change = JSON.parse(event.data);
+        renderReactions(change.id, change.reactions || []);
+    });
+    
+    // Who received and read one of our messages
+    eventSource.addEventListener('receipt', function(event) {
+        const receipt = JSON.parse(event.data);
+        renderReceipt(receipt.id, receipt.delivered_to || [], receipt.read_by || []);
+    });
+    
+    // Contact requests and changes to our contacts
+    eventSource.addEventListener('contact', function(event) {
+        showContactEvent(JSON.parse(event.data));
+    });
+    
+    // A sender's display name, sent before their next chat line when it is new or changed
+    eventSource.addEventListener('name', function(event) {
+        const change = JSON.parse(event.data);
+        displayNames[change.username] = change.display_name;
+    });
+    
+    // Someone started or stopped typing in this room or to us
+    eventSource.addEventListener('typing', function(event) {
+        showTyping(JSON.parse(event.data));
+    });
+    
+    // Someone mentioned us, possibly in another room
+    eventSource.addEventListener('mention', function(event) {
+        showMention(JSON.parse(event.data));
+    });
+    
+    // Thread replies are shown under their root message, not in the timeline
+    eventSource.addEventListener('reply', function(event) {
+        addReplyToThread(JSON.parse(event.data));
+    });
+    eventSource.addEventListener('thread', function(event) {
+        const update = JSON.parse(event.data);
+        renderReplyCount(update.id, update.reply_count);
+    });
+    
+    // The gateway lost its connection and could not recover every message
+    eventSource.addEventListener('gap', function(event) {
+        const gap = JSON.parse(event.data);
+        console.warn("History gap:", gap);
+        addMessageToChat(`<System> ${gap.message}`, false, false);
     });
     
     // Use explicit open handler for debugging
@@ -301,10 +443,7 @@ function processActiveUsersList(usersList) {
     // Ensure current user is in the list
     const currentUser = getCookie('username');
     if (currentUser && currentUser !== "System") {
-        // Don't override current user's status if they're typing
-        if (!activeUsers.has(currentUser) || activeUsers.get(currentUser) !== "typing") {
-            activeUsers.set(currentUser, lastStatusSent || "online");
-        }
+        activeUsers.set(currentUser, lastStatusSent || "online");
     }
     
     // Update the UI to reflect new statuses
@@ -325,7 +464,7 @@ function handleUserStatusChange(senderName, messageContent) {
 }
 
 // Improved function to add messages to chat - without extractUsernameFromMessage
-function addMessageToChat(message, isLocalEcho = false, isOwnMessage = false) {
+function addMessageToChat(message, isLocalEcho = false, isOwnMessage = false, messageId = null, isHistory = false) {
     console.log(`Adding message to chat box: ${message} (local echo: ${isLocalEcho}, own message: ${isOwnMessage})`);
     
     // Get the chat box element
@@ -336,9 +475,21 @@ function addMessageToChat(message, isLocalEcho = false, isOwnMessage = false) {
         return;
     }
     
-    // If this is a non-local echo that matches a local echo, skip it
-    if (!isLocalEcho && localEchoMessages.has(message)) {
+    // Skip messages that are already shown, e.g. both loaded from history and received live
+    if (messageId && chatBox.querySelector(`[data-message-id="${messageId}"]`)) {
+        return;
+    }
+    
+    // If this is a non-local echo that matches a local echo, give the echo its server ID and skip it
+    if (!isHistory && !isLocalEcho && localEchoMessages.has(message)) {
         console.log(`Skipping duplicate message that was already echoed locally: ${message}`);
+        if (messageId) {
+            const echo = Array.from(chatBox.querySelectorAll('[data-local-echo="true"]:not([data-message-id])'))
+                .find(el => el.getAttribute('data-text') === message);
+            if (echo) {
+                echo.setAttribute('data-message-id', messageId);
+            }
+        }
         return;
     }
     
@@ -349,10 +500,17 @@ function addMessageToChat(message, isLocalEcho = false, isOwnMessage = false) {
     // Create a new message element
     let messageElement = document.createElement("p");
     messageElement.style.marginBottom = "5px";
+    if (messageId) {
+        messageElement.setAttribute('data-message-id', messageId);
+    }
+    if (isOwnMessage) {
+        messageElement.setAttribute('data-own', 'true');
+    }
     
     // If it's a local echo, mark it as such
     if (isLocalEcho) {
         messageElement.setAttribute('data-local-echo', 'true');
+        messageElement.setAttribute('data-text', message);
         localEchoMessages.add(message);
     }
     
@@ -365,19 +523,32 @@ function addMessageToChat(message, isLocalEcho = false, isOwnMessage = false) {
             
             console.log(`Formatted message - Sender: ${sender}, Content: ${messageContent}`);
             
+            // Message text comes from other users, it is only ever added as text
+            const label = document.createElement("span");
+            label.className = "username-highlight";
+            label.style.color = "#ff0";
+            label.style.fontWeight = "bold";
+            label.title = sender;
+            label.textContent = `<${displayNameOf(sender)}>`;
+            messageElement.appendChild(label);
+            messageElement.appendChild(document.createTextNode(" "));
+            
             // Check if this is a "left the chat" message
             if (messageContent === "left the chat" || messageContent === "left the chat (client shutdown)") {
                 // We don't need to call removeActiveUser here as we're handling this via dedicated gRPC messages
                 
                 // Add with special style
-                messageElement.innerHTML = `<span class="username-highlight" style="color: #ff0; font-weight: bold;">&lt;${sender}&gt;</span> <span style="color: #f55;">${messageContent}</span>`;
+                const notice = document.createElement("span");
+                notice.style.color = "#f55";
+                notice.textContent = messageContent;
+                messageElement.appendChild(notice);
             }
             else {
                 // Regular message
-                messageElement.innerHTML = `<span class="username-highlight" style="color: #ff0; font-weight: bold;">&lt;${sender}&gt;</span> ${messageContent}`;
+                messageElement.appendChild(document.createTextNode(messageContent));
                 
-                // Any user who sends a message is active
-                if (sender && sender !== "System") {
+                // Any user who sends a live message is active
+                if (!isHistory && sender && sender !== "System") {
                     addActiveUser(sender);
                 }
             }
@@ -387,6 +558,12 @@ function addMessageToChat(message, isLocalEcho = false, isOwnMessage = false) {
             messageElement.textContent = message;
         }
         
+        // History goes above everything already shown
+        if (isHistory) {
+            chatBox.insertBefore(messageElement, chatBox.firstChild);
+            return;
+        }
+        
         // Add to chat box
         chatBox.appendChild(messageElement);
         
@@ -438,8 +615,8 @@ window.addEventListener('beforeunload', function() {
         }
     }
 
-    // Set status to online before leaving
-    sendStatusUpdate('online');
+    // Set status back to the chosen presence before leaving
+    sendStatusUpdate(myPresence);
 });
 
 // Enhanced logout function that properly closes the EventSource
@@ -480,12 +657,92 @@ function sendMessage() {
         
         // Clear input immediately to prevent double-sending
         input.value = "";
+
+        // The server clears our typing indicator when the message arrives
+        resetTyping();
         
         // Check if this is a multiple message command
         if (messageText.startsWith("/multiple")) {
             handleMultipleMessages(messageText);
             return;
         }
+
+        // Check if this is a room command
+        if (handleRoomCommand(messageText)) {
+            return;
+        }
+
+        // Check if this is a contacts command
+        if (messageText === "/contacts") {
+            listContacts();
+            return;
+        }
+        if (messageText === "/contact" || messageText.startsWith("/contact ")) {
+            handleContactCommand(messageText);
+            return;
+        }
+
+        // Check if this is a profile change
+        if (messageText === "/nick" || messageText.startsWith("/nick ")) {
+            updateProfile({ display_name: messageText.substring("/nick".length).trim() });
+            return;
+        }
+        if (messageText === "/bio" || messageText.startsWith("/bio ")) {
+            updateProfile({ bio: messageText.substring("/bio".length).trim() });
+            return;
+        }
+        if (messageText === "/avatar") {
+            chooseAvatar();
+            return;
+        }
+
+        // Check if this is a user lookup
+        if (messageText.startsWith("/whois ")) {
+            showUserProfile(messageText.substring("/whois ".length).trim());
+            return;
+        }
+
+        // Check if this is a message search
+        if (messageText === "/search" || messageText.startsWith("/search ")) {
+            searchMessages(messageText.substring("/search".length).trim());
+            return;
+        }
+
+        // Check if this is a presence change
+        if (messageText === "/status" || messageText.startsWith("/status ")) {
+            handleStatusCommand(messageText);
+            return;
+        }
+
+        // Check if this changes whose presence we follow
+        if (messageText === "/presence" || messageText.startsWith("/presence ")) {
+            handlePresenceCommand(messageText);
+            return;
+        }
+
+        // Check if this is a reply in a thread
+        if (messageText.startsWith("/reply ")) {
+            replyToThread(messageText.substring("/reply ".length).trim());
+            return;
+        }
+
+        // Check if this is a reaction to the last message
+        if (messageText.startsWith("/react ")) {
+            reactToLastMessage(messageText.substring("/react ".length).trim());
+            return;
+        }
+
+        // Check if this is an edit or delete of our last message
+        if (messageText.startsWith("/edit ") || messageText === "/delete") {
+            changeLastMessage(messageText);
+            return;
+        }
+
+        // Check if this is a direct message command
+        if (messageText.startsWith("/dm ")) {
+            sendDirectMessage(messageText);
+            return;
+        }
         
         // Always get username directly from cookie
         const currentUsername = getCookie('username');
@@ -503,7 +760,7 @@ function sendMessage() {
         addMessageToChat(localMessage, true, true); // true indicates this is a local echo and own message
         
         // Send to server with client id for additional attribution
-        fetch(`/send?message=${encodeURIComponent(messageToSend)}&t=${timestamp}`, {
+        fetch(`/send?message=${encodeURIComponent(messageToSend)}&room=${encodeURIComponent(currentRoom)}&t=${timestamp}`, {
             method: 'GET',
             credentials: 'same-origin',
             headers: {
@@ -564,7 +821,7 @@ function handleMultipleMessages(messageText) {
             addMessageToChat(localMessage, true, true);
             
             // Send to server
-            fetch("/send?message=" + encodeURIComponent(msg) + "&t=" + timestamp, {
+            fetch("/send?message=" + encodeURIComponent(msg) + "&room=" + encodeURIComponent(currentRoom) + "&t=" + timestamp, {
                 method: 'GET',
                 credentials: 'same-origin',
                 headers: {
@@ -598,154 +855,817 @@ function handleMultipleMessages(messageText) {
     });
 }
 
-// A set to keep track of local echo messages
-const localEchoMessages = new Set();
+// Function to handle room commands, returns true if the text was a room command
+function handleRoomCommand(messageText) {
+    const parts = messageText.split(/\s+/);
+    const command = parts[0];
+    const room = parts[1] || "";
 
-// Function to remove local echo if server sending fails
-function removeLocalEcho(message) {
-    const chatBox = document.getElementById("chat-box");
-    if (!chatBox) return;
-    
-    // Find and remove the local echo message
-    const children = chatBox.children;
-    for (let i = children.length - 1; i >= 0; i--) {
-        const child = children[i];
-        if (child.getAttribute('data-local-echo') === 'true' && 
-            child.textContent.includes(message)) {
-            chatBox.removeChild(child);
-            break;
+    switch (command) {
+        case "/rooms":
+            fetch("/rooms", { credentials: 'same-origin' })
+                .then(response => response.json())
+                .then(rooms => {
+                    const names = rooms.map(r => `${r.name} (${(r.members || []).length})`);
+                    addMessageToChat(`<System> Rooms: ${names.join(", ")}`, true, false);
+                })
+                .catch(error => console.error("Error listing rooms:", error));
+            return true;
+        case "/create":
+        case "/join":
+        case "/leave": {
+            if (!room) {
+                addMessageToChat(`<System> Usage: ${command} <room>`, true, false);
+                return true;
+            }
+            const description = parts.slice(2).join(" ");
+            const endpoint = `/rooms/${command.substring(1)}?room=${encodeURIComponent(room)}&description=${encodeURIComponent(description)}`;
+            fetch(endpoint, { credentials: 'same-origin' })
+                .then(async response => {
+                    if (!response.ok) {
+                        throw new Error(await response.text());
+                    }
+                    return response.json();
+                })
+                .then(result => {
+                    addMessageToChat(`<System> ${result.message}: ${result.room ? result.room.name : room}`, true, false);
+                    if (command === "/leave") {
+                        switchRoom("general");
+                    } else {
+                        switchRoom(result.room ? result.room.name : room);
+                    }
+                })
+                .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+            return true;
         }
+        case "/room":
+            switchRoom(room || "general");
+            return true;
+        case "/retention":
+            setRoomRetention(parts.slice(1));
+            return true;
     }
-    
-    localEchoMessages.delete(message);
+    return false;
 }
 
-function getCurrentTime() {
-    let now = new Date();
-    return now.getHours().toString().padStart(2, '0') + ":" +
-        now.getMinutes().toString().padStart(2, '0') + ":" +
-        now.getSeconds().toString().padStart(2, '0');
+// Change how long the current room keeps messages: an age like 30d, a message count, "forever" or "default"
+function setRoomRetention(args) {
+    const params = new URLSearchParams({ room: currentRoom });
+    for (const arg of args) {
+        if (arg === "forever") {
+            params.set("forever", "true");
+        } else if (arg === "default") {
+            params.set("reset", "true");
+        } else if (/^\d+$/.test(arg)) {
+            params.set("max_messages", arg);
+        } else if (/^\d+[dhm]$/.test(arg)) {
+            params.set("max_age", arg);
+        } else {
+            params.delete("room");
+        }
+    }
+    if (args.length === 0 || !params.has("room")) {
+        addMessageToChat("<System> Usage: /retention [30d] [500], /retention forever or /retention default", true, false);
+        return;
+    }
+
+    fetch(`/rooms/retention?${params}`, { credentials: 'same-origin' })
+        .then(async response => {
+            if (!response.ok) {
+                throw new Error((await response.text()).trim());
+            }
+            return response.json();
+        })
+        .then(result => {
+            const policy = result.room && result.room.retention;
+            let description = "follows the server's retention policy";
+            if (policy && policy.keep_forever) {
+                description = "keeps messages forever";
+            } else if (policy) {
+                const limits = [];
+                if (policy.max_age_seconds) {
+                    limits.push(`for ${Math.round(policy.max_age_seconds / 86400 * 10) / 10} days`);
+                }
+                if (policy.max_messages) {
+                    limits.push(`the newest ${policy.max_messages}`);
+                }
+                description = limits.length ? `keeps messages ${limits.join(", ")}` : "keeps messages as long as the server allows";
+            }
+            addMessageToChat(`<System> #${currentRoom} now ${description}`, true, false);
+        })
+        .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
 }
 
-let activeUsers = new Set();
-let isTalking = false; // Track which image is currently shown
+// Switch the chat box to another room and reconnect the stream
+function switchRoom(room) {
+    if (room === currentRoom) {
+        return;
+    }
+    currentRoom = room;
+    localStorage.setItem('chat_room', room);
+    stopTyping();
+    typingUsers.clear();
 
-// Updated to handle port-specific cookies
-function getCookie(name) {
-    // First, try to get the port-specific cookie
-    const portParam = new URLSearchParams(window.location.search).get('port');
-    const port = portParam || window.location.port || '8080'; // Default to 8080 if no port specified
-    
-    // Try port-specific cookie first
-    const portSpecificName = `${name}_port${port}`;
-    const value = `; ${document.cookie}`;
-    
-    // First check for port-specific cookie
-    let parts = value.split(`; ${portSpecificName}=`);
-    if (parts.length === 2) {
-        return parts.pop().split(';').shift();
+    const chatBox = document.getElementById("chat-box");
+    if (chatBox) {
+        chatBox.innerHTML = "";
     }
-    
-    // Fallback to regular cookie for backward compatibility
-    parts = value.split(`; ${name}=`);
-    if (parts.length === 2) {
-        return parts.pop().split(';').shift();
+    addMessageToChat(`<System> Now chatting in #${room}`, true, false);
+    startChat();
+}
+
+// Replace the text of a shown message, a null message marks it as deleted
+function updateMessageInChat(messageId, sender, message) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${messageId}"]`) : null;
+    if (!element) {
+        return;
+    }
+
+    // Keep the reactions of an edited message
+    const reactions = element.querySelector('.reactions');
+    element.innerHTML = "";
+    const label = document.createElement("span");
+    label.className = "username-highlight";
+    label.textContent = `<${displayNameOf(sender)}>`;
+    label.title = sender;
+    element.appendChild(label);
+
+    if (message === null) {
+        const deleted = document.createElement("span");
+        deleted.style.color = "#888";
+        deleted.style.fontStyle = "italic";
+        deleted.textContent = " message deleted";
+        element.appendChild(deleted);
+        element.removeAttribute('data-own');
+        return;
+    }
+
+    element.appendChild(document.createTextNode(" " + message));
+    markMessageEdited(messageId);
+    if (reactions) {
+        element.appendChild(reactions);
     }
-    
-    return null;
 }
 
-// Function to alternate between normal and talk images
-function alternateProfileImage() {
-    const profileImg = document.getElementById('profile-image');
-    if (!profileImg) return;
-    
-    isTalking = !isTalking;
-    profileImg.src = isTalking ? '/static/img/talk.png' : '/static/img/normal.png';
+// Add an "(edited)" marker to a shown message
+function markMessageEdited(messageId) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${messageId}"]`) : null;
+    if (!element || element.querySelector('.edited-marker')) {
+        return;
+    }
+
+    const marker = document.createElement("span");
+    marker.className = "edited-marker";
+    marker.style.color = "#888";
+    marker.style.fontSize = "0.8em";
+    marker.textContent = " (edited)";
+    element.appendChild(marker);
 }
 
-// Function to add username to active users
-function addActiveUser(username, status = "online") {
-    // Never add System to the active users list
-    if (username === "System") {
+// Show the reaction summary below a message, clicking an emoji toggles our own reaction
+function renderReactions(messageId, reactions) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${messageId}"]`) : null;
+    if (!element) {
         return;
     }
-    
-    // Convert activeUsers to Map to store status
-    if (!(activeUsers instanceof Map)) {
-        // Convert Set to Map if needed
-        const tempSet = activeUsers;
-        activeUsers = new Map();
-        tempSet.forEach(user => {
-            activeUsers.set(user, "online");
-        });
+
+    let container = element.querySelector('.reactions');
+    if (!container) {
+        container = document.createElement("div");
+        container.className = "reactions";
+        container.style.fontSize = "0.8em";
+        element.appendChild(container);
     }
-    
-    // Add or update user with status
-    activeUsers.set(username, status);
-    updateActiveUsersList();
+    container.innerHTML = "";
+
+    const currentUser = getCookie('username');
+    reactions.forEach(reaction => {
+        const users = reaction.users || [];
+        const mine = users.includes(currentUser);
+
+        const button = document.createElement("span");
+        button.textContent = `${reaction.emoji} ${reaction.count} `;
+        button.title = users.join(", ");
+        button.style.cursor = "pointer";
+        button.style.marginRight = "6px";
+        button.style.color = mine ? "#0ff" : "#aaa";
+        button.addEventListener('click', () => sendReaction(messageId, reaction.emoji, !mine));
+        container.appendChild(button);
+    });
 }
 
-// Function to remove a user from the active users list
-function removeActiveUser(username) {
-    if (activeUsers instanceof Map) {
-        if (activeUsers.has(username)) {
-            console.log(`Removing user ${username} from active users list`);
-            activeUsers.delete(username);
-            updateActiveUsersList();
-        }
-    } else if (activeUsers instanceof Set) {
-        if (activeUsers.has(username)) {
-            console.log(`Removing user ${username} from active users list`);
-            activeUsers.delete(username);
-            updateActiveUsersList();
+// Add or remove our reaction on a message
+function sendReaction(messageId, emoji, add) {
+    const endpoint = add ? "/react" : "/unreact";
+    fetch(`${endpoint}?id=${encodeURIComponent(messageId)}&emoji=${encodeURIComponent(emoji)}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            addMessageToChat(`<System> ${(await response.text()).trim()}`, true, false);
         }
+    })
+    .catch(error => console.error("Error sending reaction:", error));
+}
+
+// Handle "/react <emoji>" for the most recent message in this room
+function reactToLastMessage(emoji) {
+    const chatBox = document.getElementById("chat-box");
+    const messages = chatBox ? chatBox.querySelectorAll('[data-message-id]') : [];
+    if (!emoji || messages.length === 0) {
+        addMessageToChat("<System> Usage: /react <emoji> reacts to the latest message", true, false);
+        return;
     }
+    sendReaction(messages[messages.length - 1].getAttribute('data-message-id'), emoji, true);
 }
 
-// Update to refresh UI more consistently
-function updateActiveUsersList() {
-    const activeUsersElement = document.getElementById('active-users');
-    if (!activeUsersElement) return; // Safety check
-    
-    // Keep the heading
-    activeUsersElement.innerHTML = '<h3>Active Users</h3>';
-    
-    // Get current username once - prevent duplicate lookups
+// Latest message waiting to be acknowledged, and the latest one delivered but not yet read
+let pendingAckId = null;
+let unreadAckId = null;
+let ackRoom = null;
+let ackTimer = null;
+
+// Acknowledge a received message shortly, as read if the page is being looked at
+function scheduleAck(messageId) {
+    if (ackRoom !== currentRoom) {
+        // Switching rooms drops the acknowledgements of the previous one
+        unreadAckId = null;
+        ackRoom = currentRoom;
+    }
+    pendingAckId = messageId;
+    if (ackTimer) {
+        return;
+    }
+    ackTimer = setTimeout(() => {
+        ackTimer = null;
+        const id = pendingAckId;
+        pendingAckId = null;
+        if (ackRoom !== currentRoom) {
+            return;
+        }
+        if (document.visibilityState === "visible" && document.hasFocus()) {
+            unreadAckId = null;
+            sendAck(id, "read");
+        } else {
+            unreadAckId = id;
+            sendAck(id, "delivered");
+        }
+    }, 500);
+}
+
+// Report how far we got in the current room
+function sendAck(messageId, type) {
+    fetch(`/ack?room=${encodeURIComponent(currentRoom)}&id=${encodeURIComponent(messageId)}&type=${type}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .catch(error => console.error("Error sending ack:", error));
+}
+
+// Mark the first history page as read and show the receipts of our own messages in it
+function acknowledgeHistory(messages) {
+    if (messages.length === 0) {
+        return;
+    }
+    scheduleAck(messages[messages.length - 1].id);
+
     const currentUser = getCookie('username');
-    
-    if (activeUsers instanceof Map) {
-        // Sort users alphabetically for consistent display
-        const sortedUsers = Array.from(activeUsers.entries()).sort((a, b) => a[0].localeCompare(b[0]));
-        
-        console.log("Rendering active users with statuses:", sortedUsers);
-        
+    const ownIds = messages.filter(m => m.sender === currentUser).map(m => m.id);
+    if (ownIds.length === 0) {
+        return;
+    }
+    fetch(`/receipts?ids=${encodeURIComponent(ownIds.join(","))}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(response => response.ok ? response.json() : [])
+    .then(receipts => receipts.forEach(receipt =>
+        renderReceipt(receipt.message_id, receipt.delivered_to || [], receipt.read_by || [])))
+    .catch(error => console.error("Error loading receipts:", error));
+}
+
+// Show a delivered or read marker on one of our messages
+function renderReceipt(messageId, deliveredTo, readBy) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${messageId}"]`) : null;
+    if (!element || deliveredTo.length === 0) {
+        return;
+    }
+
+    let marker = element.querySelector('.receipt');
+    if (!marker) {
+        marker = document.createElement("span");
+        marker.className = "receipt";
+        marker.style.fontSize = "0.8em";
+        marker.style.marginLeft = "6px";
+        element.appendChild(marker);
+    }
+    if (readBy.length > 0) {
+        marker.textContent = "✓✓";
+        marker.style.color = "#0ff";
+        marker.title = `Read by ${readBy.join(", ")}`;
+    } else {
+        marker.textContent = "✓";
+        marker.style.color = "#aaa";
+        marker.title = `Delivered to ${deliveredTo.join(", ")}`;
+    }
+}
+
+// Messages that arrived while the page was hidden are read once it is shown again
+document.addEventListener('visibilitychange', function() {
+    if (document.visibilityState === "visible" && unreadAckId) {
+        scheduleAck(unreadAckId);
+    }
+});
+
+// Add a System line with text other users wrote, e.g. their names or messages. It is only ever added
+// as text and doesn't go through addMessageToChat, which would take it for a chat message.
+function addSystemText(text) {
+    const chatBox = document.getElementById("chat-box");
+    if (!chatBox) {
+        return null;
+    }
+    const element = document.createElement("p");
+    element.style.marginBottom = "5px";
+    const label = document.createElement("span");
+    label.className = "username-highlight";
+    label.style.color = "#ff0";
+    label.style.fontWeight = "bold";
+    label.textContent = "<System>";
+    element.appendChild(label);
+    element.appendChild(document.createTextNode(" " + text));
+    chatBox.appendChild(element);
+    chatBox.scrollTop = chatBox.scrollHeight;
+    return element;
+}
+
+// Highlight a message that mentions us and raise a browser notification
+function showMention(mention) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${mention.id}"]`) : null;
+    if (element) {
+        element.classList.add("mention");
+        element.style.backgroundColor = "rgba(255, 215, 0, 0.15)";
+    } else if (mention.room !== currentRoom) {
+        const notice = addSystemText(`${mention.display_name || mention.sender} mentioned you in #${mention.room}: ${mention.message}`);
+        if (notice) {
+            notice.classList.add("mention");
+            notice.style.backgroundColor = "rgba(255, 215, 0, 0.15)";
+        }
+    }
+
+    if (!("Notification" in window)) {
+        return;
+    }
+    if (Notification.permission === "granted") {
+        new Notification(`${mention.display_name || mention.sender} mentioned you in #${mention.room}`, { body: mention.message });
+    } else if (Notification.permission === "default") {
+        Notification.requestPermission();
+    }
+}
+
+// Thread whose replies are currently expanded, "/reply" answers in it
+let openThreadId = null;
+
+// Show a clickable "N replies" marker below a thread root
+function renderReplyCount(messageId, count) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${messageId}"]`) : null;
+    if (!element) {
+        return;
+    }
+
+    let marker = element.querySelector('.reply-count');
+    if (!marker) {
+        marker = document.createElement("div");
+        marker.className = "reply-count";
+        marker.style.fontSize = "0.8em";
+        marker.style.color = "#0ff";
+        marker.style.cursor = "pointer";
+        marker.addEventListener('click', () => toggleThread(messageId));
+        element.appendChild(marker);
+    }
+    marker.textContent = count > 0 ? `💬 ${count} ${count === 1 ? "reply" : "replies"}` : "";
+}
+
+// Expand or collapse the replies of a thread
+function toggleThread(messageId) {
+    const chatBox = document.getElementById("chat-box");
+    const element = chatBox ? chatBox.querySelector(`[data-message-id="${messageId}"]`) : null;
+    if (!element) {
+        return;
+    }
+
+    const existing = element.querySelector('.thread-replies');
+    if (existing) {
+        existing.remove();
+        if (openThreadId === messageId) {
+            openThreadId = null;
+        }
+        return;
+    }
+
+    fetch(`/thread?id=${encodeURIComponent(messageId)}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        return response.json();
+    })
+    .then(thread => {
+        const container = document.createElement("div");
+        container.className = "thread-replies";
+        container.style.marginLeft = "20px";
+        container.style.borderLeft = "2px solid #0ff";
+        container.style.paddingLeft = "6px";
+        element.appendChild(container);
+        openThreadId = messageId;
+
+        (thread.replies || []).forEach(reply => addReplyToThread(reply));
+    })
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Add a reply to its thread if the thread is expanded
+function addReplyToThread(reply) {
+    const chatBox = document.getElementById("chat-box");
+    const root = chatBox ? chatBox.querySelector(`[data-message-id="${reply.parent_id}"]`) : null;
+    const container = root ? root.querySelector('.thread-replies') : null;
+    if (!container || container.querySelector(`[data-reply-id="${reply.id}"]`)) {
+        return;
+    }
+
+    const element = document.createElement("div");
+    element.setAttribute('data-reply-id', reply.id);
+    const label = document.createElement("span");
+    label.className = "username-highlight";
+    label.textContent = `<${reply.display_name || reply.sender_display_name || displayNameOf(reply.sender)}>`;
+    label.title = reply.sender;
+    element.appendChild(label);
+    element.appendChild(document.createTextNode(" " + reply.message));
+    container.appendChild(element);
+}
+
+// Handle "/reply <text>" for the open thread, or the latest message in this room
+function replyToThread(messageText) {
+    const chatBox = document.getElementById("chat-box");
+    const messages = chatBox ? chatBox.querySelectorAll('[data-message-id]') : [];
+    let parentId = openThreadId;
+    if (!parentId && messages.length > 0) {
+        parentId = messages[messages.length - 1].getAttribute('data-message-id');
+    }
+    if (!messageText || !parentId) {
+        addMessageToChat("<System> Usage: /reply <message> answers the open thread or the latest message", true, false);
+        return;
+    }
+
+    fetch(`/send?message=${encodeURIComponent(messageText)}&room=${encodeURIComponent(currentRoom)}&parent=${encodeURIComponent(parentId)}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            addMessageToChat(`<System> ${(await response.text()).trim()}`, true, false);
+        }
+    })
+    .catch(error => console.error("Error sending reply:", error));
+}
+
+// Handle "/edit <text>" and "/delete" for our most recent message in this room
+function changeLastMessage(messageText) {
+    const chatBox = document.getElementById("chat-box");
+    const own = chatBox ? chatBox.querySelectorAll('[data-own="true"][data-message-id]') : [];
+    if (own.length === 0) {
+        addMessageToChat("<System> No message of yours to change yet", true, false);
+        return;
+    }
+
+    const messageId = own[own.length - 1].getAttribute('data-message-id');
+    let url = `/delete?id=${encodeURIComponent(messageId)}`;
+    if (messageText.startsWith("/edit ")) {
+        const text = messageText.substring("/edit ".length).trim();
+        url = `/edit?id=${encodeURIComponent(messageId)}&message=${encodeURIComponent(text)}`;
+    }
+
+    fetch(url, { credentials: 'same-origin', headers: { 'Cache-Control': 'no-cache' } })
+        .then(async response => {
+            if (!response.ok) {
+                addMessageToChat(`<System> ${(await response.text()).trim()}`, true, false);
+            }
+        })
+        .catch(error => console.error("Error changing message:", error));
+}
+
+// History paging state for the current room
+let oldestSequence = 0;
+let hasMoreHistory = true;
+let loadingHistory = false;
+
+// Load a page of room history, older than the oldest message shown so far
+function loadHistory() {
+    if (loadingHistory || !hasMoreHistory) {
+        return;
+    }
+    loadingHistory = true;
+
+    const firstPage = oldestSequence === 0;
+    let url = `/history?room=${encodeURIComponent(currentRoom)}&limit=50&t=${new Date().getTime()}`;
+    if (!firstPage) {
+        url += `&before=${oldestSequence}`;
+    }
+
+    const chatBox = document.getElementById("chat-box");
+    const previousHeight = chatBox ? chatBox.scrollHeight : 0;
+
+    fetch(url, { credentials: 'same-origin', headers: { 'Cache-Control': 'no-cache' } })
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`History request failed: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(page => {
+            const messages = page.messages || [];
+            hasMoreHistory = !!page.has_more;
+
+            // Prepend newest first so the oldest ends up on top
+            for (let i = messages.length - 1; i >= 0; i--) {
+                const m = messages[i];
+                if (oldestSequence === 0 || m.sequence < oldestSequence) {
+                    oldestSequence = m.sequence;
+                }
+                if (m.parent_id) {
+                    continue;
+                }
+                // Names from history may be outdated, presence has the current ones
+                if (m.sender_display_name && !displayNames[m.sender]) {
+                    displayNames[m.sender] = m.sender_display_name;
+                }
+                addMessageToChat(`<${m.sender}> ${m.message}`, false, m.sender === getCookie('username'), m.id, true);
+                if (m.edited) {
+                    markMessageEdited(m.id);
+                }
+                if (m.reactions) {
+                    renderReactions(m.id, m.reactions);
+                }
+                if (m.reply_count) {
+                    renderReplyCount(m.id, m.reply_count);
+                }
+            }
+
+            if (firstPage) {
+                acknowledgeHistory(messages);
+            }
+
+            if (chatBox) {
+                if (firstPage) {
+                    chatBox.scrollTop = chatBox.scrollHeight;
+                } else {
+                    // Keep the view anchored where the user was reading
+                    chatBox.scrollTop = chatBox.scrollHeight - previousHeight;
+                }
+            }
+        })
+        .catch(error => console.error("Error loading history:", error))
+        .finally(() => {
+            loadingHistory = false;
+        });
+}
+
+// Function to send a direct message using "/dm <user> <message>"
+function sendDirectMessage(messageText) {
+    const match = messageText.match(/^\/dm\s+(\S+)\s+(.+)$/);
+    if (!match) {
+        addMessageToChat("<System> Usage: /dm <user> <message>", true, false);
+        return;
+    }
+
+    const recipient = match[1];
+    const text = match[2];
+    fetch(`/dm?to=${encodeURIComponent(recipient)}&message=${encodeURIComponent(text)}&t=${new Date().getTime()}`, {
+        method: 'GET',
+        credentials: 'same-origin',
+        headers: {
+            'Cache-Control': 'no-cache'
+        }
+    })
+    .then(response => {
+        if (!response.ok) {
+            addMessageToChat(`<System> Failed to send direct message to ${recipient}`, true, false);
+        }
+    })
+    .catch(error => console.error("Error sending direct message:", error));
+}
+
+// Function to show a direct message in the chat box
+function addDirectMessageToChat(dm) {
+    const chatBox = document.getElementById("chat-box");
+    if (!chatBox) return;
+
+    const messageElement = document.createElement("p");
+    messageElement.style.marginBottom = "5px";
+    messageElement.style.color = "#f0f";
+
+    const label = document.createElement("span");
+    label.className = "username-highlight";
+    label.textContent = `[DM] <${dm.display_name || displayNameOf(dm.sender)} → ${displayNameOf(dm.recipient)}>`;
+    messageElement.appendChild(label);
+    messageElement.appendChild(document.createTextNode(" " + dm.message));
+
+    chatBox.appendChild(messageElement);
+    chatBox.scrollTop = chatBox.scrollHeight;
+}
+
+// A set to keep track of local echo messages
+const localEchoMessages = new Set();
+
+// Function to remove local echo if server sending fails
+function removeLocalEcho(message) {
+    const chatBox = document.getElementById("chat-box");
+    if (!chatBox) return;
+    
+    // Find and remove the local echo message
+    const children = chatBox.children;
+    for (let i = children.length - 1; i >= 0; i--) {
+        const child = children[i];
+        if (child.getAttribute('data-local-echo') === 'true' && 
+            child.textContent.includes(message)) {
+            chatBox.removeChild(child);
+            break;
+        }
+    }
+    
+    localEchoMessages.delete(message);
+}
+
+function getCurrentTime() {
+    let now = new Date();
+    return now.getHours().toString().padStart(2, '0') + ":" +
+        now.getMinutes().toString().padStart(2, '0') + ":" +
+        now.getSeconds().toString().padStart(2, '0');
+}
+
+let activeUsers = new Set();
+let isTalking = false; // Track which image is currently shown
+
+// Updated to handle port-specific cookies
+function getCookie(name) {
+    // First, try to get the port-specific cookie
+    const portParam = new URLSearchParams(window.location.search).get('port');
+    const port = portParam || window.location.port || '8080'; // Default to 8080 if no port specified
+    
+    // Try port-specific cookie first
+    const portSpecificName = `${name}_port${port}`;
+    const value = `; ${document.cookie}`;
+    
+    // First check for port-specific cookie
+    let parts = value.split(`; ${portSpecificName}=`);
+    if (parts.length === 2) {
+        return parts.pop().split(';').shift();
+    }
+    
+    // Fallback to regular cookie for backward compatibility
+    parts = value.split(`; ${name}=`);
+    if (parts.length === 2) {
+        return parts.pop().split(';').shift();
+    }
+    
+    return null;
+}
+
+// Function to alternate between normal and talk images
+function alternateProfileImage() {
+    const profileImg = document.getElementById('profile-image');
+    if (!profileImg) return;
+    
+    isTalking = !isTalking;
+    profileImg.src = isTalking ? '/static/img/talk.png' : '/static/img/normal.png';
+}
+
+// Function to add username to active users
+function addActiveUser(username, status = "online") {
+    // Never add System to the active users list
+    if (username === "System") {
+        return;
+    }
+    
+    // Convert activeUsers to Map to store status
+    if (!(activeUsers instanceof Map)) {
+        // Convert Set to Map if needed
+        const tempSet = activeUsers;
+        activeUsers = new Map();
+        tempSet.forEach(user => {
+            activeUsers.set(user, "online");
+        });
+    }
+    
+    // Add or update user with status
+    activeUsers.set(username, status);
+    updateActiveUsersList();
+}
+
+// Function to remove a user from the active users list
+function removeActiveUser(username) {
+    if (activeUsers instanceof Map) {
+        if (activeUsers.has(username)) {
+            console.log(`Removing user ${username} from active users list`);
+            activeUsers.delete(username);
+            updateActiveUsersList();
+        }
+    } else if (activeUsers instanceof Set) {
+        if (activeUsers.has(username)) {
+            console.log(`Removing user ${username} from active users list`);
+            activeUsers.delete(username);
+            updateActiveUsersList();
+        }
+    }
+}
+
+// Update to refresh UI more consistently
+function updateActiveUsersList() {
+    const activeUsersElement = document.getElementById('active-users');
+    if (!activeUsersElement) return; // Safety check
+    
+    // Keep the heading
+    activeUsersElement.innerHTML = '<h3>Active Users</h3>';
+    
+    // Get current username once - prevent duplicate lookups
+    const currentUser = getCookie('username');
+    
+    if (activeUsers instanceof Map) {
+        // Sort users alphabetically for consistent display
+        const sortedUsers = Array.from(activeUsers.entries()).sort((a, b) => a[0].localeCompare(b[0]));
+        
+        console.log("Rendering active users with statuses:", sortedUsers);
+        
         // Add each user as a list item with status
         sortedUsers.forEach(([user, status]) => {
             const userElement = document.createElement('div');
             userElement.className = 'user-item';
             
             // Highlight current user
+            if (avatarVersions[user]) {
+                const avatar = document.createElement('img');
+                avatar.className = 'user-avatar';
+                avatar.src = avatarURL(user);
+                avatar.alt = "";
+                userElement.appendChild(avatar);
+            }
+            const nameElement = document.createElement('span');
+            nameElement.textContent = displayNameOf(user);
+            nameElement.title = user;
             if (user === currentUser) {
                 userElement.style.fontWeight = 'bold';
                 userElement.style.color = '#00ffff'; // Cyan color for current user
-                userElement.innerHTML = `${user} (you)`;
-            } else {
-                userElement.innerHTML = user;
+                nameElement.textContent += " (you)";
             }
+            userElement.appendChild(nameElement);
             
             // Add status indicator
-            if (status === "typing") {
-                console.log(`Setting typing indicator for ${user}`);
+            const onlineIndicator = document.createElement('span');
+            onlineIndicator.className = `online-indicator presence-${status}`;
+            onlineIndicator.textContent = ` ${PRESENCE_LABELS[status] || status}`;
+            userElement.appendChild(onlineIndicator);
+
+            const typing = typingUsers.get(user);
+            if (typing) {
                 const typingIndicator = document.createElement('span');
                 typingIndicator.className = 'typing-indicator';
-                typingIndicator.textContent = ' typing...';
+                typingIndicator.textContent = typing.direct ? ' typing to you...' : ' typing...';
                 userElement.appendChild(typingIndicator);
-            } else {
-                const onlineIndicator = document.createElement('span');
-                onlineIndicator.className = 'online-indicator';
-                onlineIndicator.textContent = ' online';
-                userElement.appendChild(onlineIndicator);
+            }
+            
+            // Show how many devices the user is connected from
+            const sessions = userSessions[user];
+            if (sessions && sessions.count > 1) {
+                const sessionsElement = document.createElement('span');
+                sessionsElement.className = 'session-count';
+                sessionsElement.textContent = ` (${sessions.count} devices)`;
+                sessionsElement.title = (sessions.devices || []).join("\n");
+                userElement.appendChild(sessionsElement);
+            }
+            
+            // Clicking a user shows when they joined and how active they are
+            userElement.style.cursor = 'pointer';
+            userElement.addEventListener('click', () => showUserProfile(user));
+            
+            // Add the custom status if the user set one
+            const custom = customStatuses[user];
+            if (custom && (custom.text || custom.emoji)) {
+                const customElement = document.createElement('div');
+                customElement.className = 'custom-status';
+                customElement.textContent = `${custom.emoji || ""} ${custom.text || ""}`.trim();
+                if (custom.expires_at) {
+                    customElement.title = `Until ${new Date(custom.expires_at * 1000).toLocaleTimeString()}`;
+                }
+                userElement.appendChild(customElement);
             }
             
             activeUsersElement.appendChild(userElement);
@@ -796,6 +1716,16 @@ document.addEventListener('DOMContentLoaded', function() {
     // Set up image alternating every 1 second
     setInterval(alternateProfileImage, 1000);
 
+    // Load older messages when scrolled to the top of the chat box
+    const chatBoxElement = document.getElementById('chat-box');
+    if (chatBoxElement) {
+        chatBoxElement.addEventListener('scroll', function() {
+            if (chatBoxElement.scrollTop === 0) {
+                loadHistory();
+            }
+        });
+    }
+
     // Add typing detection to the message input
     const messageInput = document.getElementById('message');
     if (messageInput) {
@@ -803,15 +1733,7 @@ document.addEventListener('DOMContentLoaded', function() {
         messageInput.addEventListener('keydown', function(e) {
             // Don't trigger on special keys like arrows, ctrl, etc.
             if (e.key.length === 1 || e.key === 'Backspace' || e.key === 'Delete') {
-                sendStatusUpdate('typing');
-                
-                // Clear existing timer
-                clearTimeout(typingTimer);
-                
-                // Set timer to revert to online after delay
-                typingTimer = setTimeout(() => {
-                    sendStatusUpdate('online');
-                }, TYPING_DELAY);
+                notifyTyping(messageInput.value);
             }
         });
     }
@@ -891,6 +1813,475 @@ function pingServer() {
 }
 
 // Function to send status update to server via client streaming RPC
+// Describe how long ago a Unix time was, like "5 minutes ago"
+function formatTimeAgo(unixSeconds) {
+    const seconds = Math.max(0, Math.floor(Date.now() / 1000) - unixSeconds);
+    const units = [["day", 86400], ["hour", 3600], ["minute", 60]];
+    for (const [name, size] of units) {
+        const count = Math.floor(seconds / size);
+        if (count >= 1) {
+            return `${count} ${name}${count === 1 ? "" : "s"} ago`;
+        }
+    }
+    return "just now";
+}
+
+// Show a user's presence and activity in the chat box
+function showUserProfile(name) {
+    if (!name) {
+        addMessageToChat("<System> Usage: /whois <user>", true, false);
+        return;
+    }
+
+    fetch(`/user?name=${encodeURIComponent(name)}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        return response.json();
+    })
+    .then(profile => {
+        const parts = [];
+        if (profile.online) {
+            parts.push(PRESENCE_LABELS[profile.status] || profile.status);
+            if (profile.joined_at) {
+                parts.push(`joined ${formatTimeAgo(profile.joined_at)}`);
+            }
+        } else {
+            parts.push(profile.last_seen ? `last seen ${formatTimeAgo(profile.last_seen)}` : "offline");
+        }
+        const custom = profile.custom_status;
+        if (custom && (custom.text || custom.emoji)) {
+            parts.push(`${custom.emoji || ""} ${custom.text || ""}`.trim());
+        }
+        const count = profile.message_count || 0;
+        parts.push(`${count} message${count === 1 ? "" : "s"}`);
+
+        const details = profile.profile || {};
+        const name = details.display_name && details.display_name !== profile.username
+            ? `${details.display_name} (${profile.username})`
+            : profile.username;
+        addSystemText(`${name}: ${parts.join(" · ")}`);
+        if (details.bio) {
+            // Bios may have several lines
+            const bio = addSystemText(details.bio);
+            if (bio) {
+                bio.style.whiteSpace = "pre-wrap";
+            }
+        }
+        if (details.avatar_type) {
+            const chatBox = document.getElementById("chat-box");
+            const avatar = document.createElement("img");
+            avatar.className = "profile-avatar";
+            avatar.src = avatarURL(profile.username, details.avatar_updated_at);
+            avatar.alt = `Avatar of ${profile.username}`;
+            chatBox.appendChild(avatar);
+            chatBox.scrollTop = chatBox.scrollHeight;
+        }
+    })
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Parameters of the last search and where its next page starts, for "/search more"
+let lastSearch = null;
+
+// Search messages in our rooms: "/search [from:user] [in:room] words", "/search more" shows older matches
+function searchMessages(args) {
+    let params;
+    if (args === "more") {
+        if (!lastSearch || !lastSearch.page) {
+            addMessageToChat("<System> No more search results", true, false);
+            return;
+        }
+        params = lastSearch;
+    } else {
+        params = { q: [], sender: "", room: "" };
+        for (const word of args.split(/\s+/).filter(Boolean)) {
+            if (word.startsWith("from:")) {
+                params.sender = word.substring("from:".length);
+            } else if (word.startsWith("in:")) {
+                params.room = word.substring("in:".length);
+            } else {
+                params.q.push(word);
+            }
+        }
+        if (params.q.length === 0 && !params.sender) {
+            addMessageToChat("<System> Usage: /search [from:user] [in:room] <words>, then /search more for older matches", true, false);
+            return;
+        }
+        params.q = params.q.join(" ");
+        params.page = "";
+    }
+
+    const query = new URLSearchParams({ q: params.q, sender: params.sender, room: params.room, limit: "20", page: params.page });
+    fetch(`/search?${query}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        return response.json();
+    })
+    .then(result => {
+        const messages = result.messages || [];
+        if (messages.length === 0) {
+            addMessageToChat("<System> No messages found", true, false);
+        }
+        for (const msg of messages) {
+            const when = msg.sent_at ? formatTimeAgo(msg.sent_at) : msg.timestamp;
+            addSystemText(`#${msg.room} ${msg.sender} (${when}): ${msg.message}`);
+        }
+        params.page = result.next_page_token || "";
+        lastSearch = params;
+        if (params.page) {
+            addMessageToChat("<System> Type /search more for older matches", true, false);
+        }
+    })
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Labels shown next to each presence in the users list
+const PRESENCE_LABELS = {
+    online: "online",
+    away: "away",
+    busy: "busy",
+    dnd: "do not disturb",
+    invisible: "invisible"
+};
+
+// Endpoints of the "/contact" subcommands
+const CONTACT_ACTIONS = {
+    add: "/contacts/request",
+    accept: "/contacts/accept",
+    decline: "/contacts/decline",
+    remove: "/contacts/remove",
+    block: "/contacts/block",
+    unblock: "/contacts/unblock"
+};
+
+// Handle "/contact <add|accept|decline|remove|block|unblock> <user>"
+function handleContactCommand(messageText) {
+    const match = messageText.match(/^\/contact\s+(\S+)\s+(\S+)$/);
+    if (!match || !CONTACT_ACTIONS[match[1]]) {
+        addMessageToChat("<System> Usage: /contact <add|accept|decline|remove|block|unblock> <user>, or /contacts to list them", true, false);
+        return;
+    }
+
+    fetch(`${CONTACT_ACTIONS[match[1]]}?name=${encodeURIComponent(match[2])}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        return response.json();
+    })
+    .then(result => addMessageToChat(`<System> ${result.message}`, true, false))
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Show contacts, open requests and blocked users in the chat box
+function listContacts() {
+    fetch(`/contacts?t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        return response.json();
+    })
+    .then(list => {
+        const contacts = list.contacts || [];
+        if (contacts.length === 0) {
+            addMessageToChat("<System> You have no contacts yet, add one with /contact add <user>", true, false);
+        } else {
+            const names = contacts.map(c => `${c.display_name || c.username}${c.online ? " (online)" : ""}`);
+            addMessageToChat(`<System> Contacts: ${names.join(", ")}`, true, false);
+        }
+        const incoming = list.incoming || [];
+        if (incoming.length > 0) {
+            addMessageToChat(`<System> Waiting for your answer: ${incoming.map(r => r.username).join(", ")}`, true, false);
+        }
+        const outgoing = list.outgoing || [];
+        if (outgoing.length > 0) {
+            addMessageToChat(`<System> Waiting for an answer from: ${outgoing.map(r => r.username).join(", ")}`, true, false);
+        }
+        const blocked = list.blocked || [];
+        if (blocked.length > 0) {
+            addMessageToChat(`<System> Blocked: ${blocked.map(b => b.username).join(", ")}`, true, false);
+        }
+    })
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Describe a contact event from our point of view
+function showContactEvent(event) {
+    const me = getCookie('username');
+    const other = event.from === me ? event.to : event.from;
+    const name = displayNameOf(other);
+    let text;
+    switch (event.kind) {
+    case "requested":
+        text = event.to === me
+            ? `${name} wants to add you as a contact, answer with /contact accept ${other} or /contact decline ${other}`
+            : `Contact request sent to ${name}`;
+        break;
+    case "accepted":
+        text = `You and ${name} are now contacts`;
+        break;
+    case "declined":
+        text = `The contact request between you and ${name} was closed`;
+        break;
+    case "removed":
+        text = event.from === me
+            ? `You removed ${name} from your contacts`
+            : `${name} removed you from their contacts`;
+        break;
+    default:
+        return;
+    }
+    addMessageToChat(`<System> ${text}`, true, false);
+}
+
+// Name to show for a user, their display name when we know it
+function displayNameOf(user) {
+    return displayNames[user] || user;
+}
+
+// URL of a user's avatar, the version makes the browser fetch a new upload
+function avatarURL(user, version) {
+    return `/avatar?name=${encodeURIComponent(user)}&v=${version || avatarVersions[user] || 0}`;
+}
+
+// Change the display name or bio, an empty value resets it
+function updateProfile(changes) {
+    const params = new URLSearchParams(changes);
+    params.set("t", new Date().getTime());
+    fetch(`/profile/update?${params.toString()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        return response.json();
+    })
+    .then(profile => {
+        displayNames[profile.username] = profile.display_name;
+        updateActiveUsersList();
+        if ("display_name" in changes) {
+            addMessageToChat(`<System> You are now shown as ${profile.display_name}`, true, false);
+        } else {
+            addMessageToChat(`<System> ${profile.bio ? "Bio updated" : "Bio cleared"}`, true, false);
+        }
+    })
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Let the user pick an image and upload it as their avatar
+function chooseAvatar() {
+    const input = document.createElement("input");
+    input.type = "file";
+    input.accept = "image/png,image/jpeg,image/gif,image/webp";
+    input.addEventListener("change", () => {
+        const file = input.files[0];
+        if (!file) {
+            return;
+        }
+        fetch(`/avatar/upload?t=${new Date().getTime()}`, {
+            method: 'POST',
+            credentials: 'same-origin',
+            body: file
+        })
+        .then(async response => {
+            if (!response.ok) {
+                throw new Error((await response.text()).trim());
+            }
+            return response.json();
+        })
+        .then(profile => {
+            avatarVersions[profile.username] = profile.avatar_updated_at;
+            updateActiveUsersList();
+            addMessageToChat("<System> Avatar updated", true, false);
+        })
+        .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+    });
+    input.click();
+}
+
+// Handle "/status <presence> [emoji] [text] [for <duration>]" and "/status clear"
+function handleStatusCommand(messageText) {
+    const args = messageText.substring("/status".length).trim();
+    if (args === "clear") {
+        sendCustomStatus("", "clear=true");
+        return;
+    }
+
+    const match = args.match(/^(online|away|busy|dnd|invisible)(?:\s+(.*?))?(?:\s+for\s+(\d+[smh]))?$/);
+    if (!match) {
+        addMessageToChat("<System> Usage: /status <online|away|busy|dnd|invisible> [emoji] [text] [for 30m], or /status clear", true, false);
+        return;
+    }
+
+    const presence = match[1];
+    let text = (match[2] || "").trim();
+    let emoji = "";
+    // A first word without letters or digits is taken as the emoji
+    const firstWord = text.split(/\s+/)[0];
+    if (firstWord && !/[\p{L}\p{N}]/u.test(firstWord)) {
+        emoji = firstWord;
+        text = text.substring(firstWord.length).trim();
+    }
+
+    let params = "";
+    if (text || emoji) {
+        params = `text=${encodeURIComponent(text)}&emoji=${encodeURIComponent(emoji)}`;
+        if (match[3]) {
+            params += `&expires=${match[3]}`;
+        }
+    }
+
+    myPresence = presence;
+    sendCustomStatus(presence, params);
+}
+
+// Send a presence together with custom status parameters
+function sendCustomStatus(presence, params) {
+    let url = `/status?status=${encodeURIComponent(presence)}`;
+    if (params) {
+        url += `&${params}`;
+    }
+    fetch(url, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            addMessageToChat(`<System> ${(await response.text()).trim()}`, true, false);
+            return;
+        }
+        if (presence) {
+            lastStatusSent = presence;
+        }
+    })
+    .catch(error => console.error("Error updating status:", error));
+}
+
+// Handle "/presence all|room|contacts" or "/presence users <user> [user...]", the room scope follows the room shown
+function handlePresenceCommand(messageText) {
+    const parts = messageText.substring("/presence".length).trim().split(/[\s,]+/).filter(Boolean);
+    const scope = parts[0] === "users" ? "list" : parts[0];
+    const params = new URLSearchParams({ scope: scope || "" });
+    if (scope === "room") {
+        params.set("room", currentRoom);
+    } else if (scope === "list") {
+        params.set("users", parts.slice(1).join(","));
+    }
+    if (!["all", "room", "contacts", "list"].includes(scope) || (scope === "list" && parts.length < 2)) {
+        addMessageToChat("<System> Usage: /presence all, /presence room, /presence contacts or /presence users <user> [user...]", true, false);
+        return;
+    }
+
+    fetch(`/presence?${params}&t=${new Date().getTime()}`, {
+        credentials: 'same-origin',
+        headers: { 'Cache-Control': 'no-cache' }
+    })
+    .then(async response => {
+        if (!response.ok) {
+            throw new Error((await response.text()).trim());
+        }
+        const described = {
+            all: "everyone",
+            room: `members of #${currentRoom}`,
+            contacts: "your contacts",
+            list: parts.slice(1).join(", ")
+        };
+        addMessageToChat(`<System> Now showing the presence of ${described[scope]}`, true, false);
+    })
+    .catch(error => addMessageToChat(`<System> ${error.message}`, true, false));
+}
+
+// Work out where the message being typed will go, null for commands other than /dm
+function typingTargetFor(text) {
+    const dm = text.match(/^\/dm\s+(\S+)\s/);
+    if (dm) {
+        return "@" + dm[1];
+    }
+    if (text.startsWith("/")) {
+        return null;
+    }
+    return currentRoom;
+}
+
+// Tell the server we're typing, repeating while keys keep coming in
+function notifyTyping(text) {
+    const target = typingTargetFor(text);
+    if (target !== typingTarget) {
+        stopTyping();
+    }
+    if (!target) {
+        return;
+    }
+
+    const now = Date.now();
+    if (target !== typingTarget || now - typingSentAt >= TYPING_REFRESH) {
+        typingTarget = target;
+        typingSentAt = now;
+        sendTyping(target, "start");
+    }
+
+    clearTimeout(typingTimer);
+    typingTimer = setTimeout(stopTyping, TYPING_IDLE);
+}
+
+// Tell the server we stopped typing before sending anything
+function stopTyping() {
+    if (typingTarget) {
+        sendTyping(typingTarget, "stop");
+    }
+    resetTyping();
+}
+
+// Forget the typing state without notifying the server
+function resetTyping() {
+    clearTimeout(typingTimer);
+    typingTarget = null;
+    typingSentAt = 0;
+}
+
+function sendTyping(target, state) {
+    const param = target.startsWith("@")
+        ? `to=${encodeURIComponent(target.substring(1))}`
+        : `room=${encodeURIComponent(target)}`;
+    fetch(`/typing?${param}&state=${state}&t=${new Date().getTime()}`, {
+        method: 'GET',
+        credentials: 'same-origin',
+        headers: {
+            'Cache-Control': 'no-cache'
+        }
+    })
+    .catch(error => console.error("Error sending typing indicator:", error));
+}
+
+// Show or clear a typing indicator, the server sends stop when an indicator expires
+function showTyping(event) {
+    if (event.typing) {
+        typingUsers.set(event.sender, { direct: !!event.recipient });
+    } else {
+        typingUsers.delete(event.sender);
+    }
+    updateActiveUsersList();
+}
+
 function sendStatusUpdate(status) {
     // Avoid sending duplicate statuses
     if (status === lastStatusSent) {
@@ -927,6 +2318,22 @@ function sendStatusUpdate(status) {
 document.addEventListener('DOMContentLoaded', function() {
     const style = document.createElement('style');
     style.textContent = `
+        .user-avatar {
+            width: 16px;
+            height: 16px;
+            border-radius: 50%;
+            object-fit: cover;
+            vertical-align: middle;
+            margin-right: 4px;
+        }
+
+        .profile-avatar {
+            display: block;
+            max-width: 96px;
+            max-height: 96px;
+            margin: 0 0 5px 0;
+        }
+
         .typing-indicator {
             color: #0f0;
             font-style: italic;
@@ -945,6 +2352,21 @@ document.addEventListener('DOMContentLoaded', function() {
             margin-left: 5px;
             font-size: 0.8em;
         }
+        
+        .presence-away { color: #ffb000; }
+        .presence-busy, .presence-dnd { color: #ff4040; }
+        .presence-invisible { color: #888; }
+        
+        .session-count {
+            color: #888;
+            font-size: 0.8em;
+        }
+        
+        .custom-status {
+            color: #aaa;
+            font-size: 0.8em;
+            margin-left: 10px;
+        }
     `;
     document.head.appendChild(style);
 });
\ No newline at end of file
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	pb "grpc-chat/proto"
+)
+
+// Payload of the "reply" SSE event
+type threadReplyEvent struct {
+	ID          string `json:"id"`
+	ParentID    string `json:"parent_id"`
+	Room        string `json:"room"`
+	Sender      string `json:"sender"`
+	DisplayName string `json:"display_name,omitempty"` // Of the sender
+	Message     string `json:"message"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// Payload of the "thread" SSE event
+type threadUpdateEvent struct {
+	ID         string `json:"id"`
+	Room       string `json:"room"`
+	ReplyCount int32  `json:"reply_count"`
+}
+
+// Handler to fetch a thread root and its replies
+func threadHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.GetThread(context.Background(), &pb.GetThreadRequest{
+		Username:  username,
+		MessageId: r.URL.Query().Get("id"),
+	})
+	if err != nil {
+		log.Printf("Error fetching thread for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
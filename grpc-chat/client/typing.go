@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	pb "grpc-chat/proto"
+)
+
+// Payload of the "typing" SSE event
+type typingEvent struct {
+	Sender    string `json:"sender"`
+	Room      string `json:"room,omitempty"`
+	Recipient string `json:"recipient,omitempty"` // Set when typing a direct message
+	Typing    bool   `json:"typing"`
+}
+
+// Handler for typing indicators, accepts room or to, and state=start|stop.
+// Browsers repeat start while typing, the server expires indicators that stop being refreshed.
+func typingHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	sender, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var typing bool
+	switch r.URL.Query().Get("state") {
+	case "start":
+		typing = true
+	case "stop":
+	default:
+		http.Error(w, "Invalid 'state' parameter, expected start or stop", http.StatusBadRequest)
+		return
+	}
+
+	stream, ok := streamFor(clientIP)
+	if !ok || stream == nil {
+		http.Error(w, "Chat service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	msg := &pb.ChatMessage{
+		Sender:    sender,
+		Recipient: strings.TrimSpace(r.URL.Query().Get("to")),
+		Type:      pb.ChatMessage_TYPING,
+		Typing:    typing,
+	}
+	if msg.Recipient == "" {
+		msg.Room = normalizeRoom(r.URL.Query().Get("room"))
+	}
+
+	if err := stream.Send(msg); err != nil {
+		log.Printf("Error sending typing indicator for %s: %v", sender, err)
+		http.Error(w, "Failed to send typing indicator", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
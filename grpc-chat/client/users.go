@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	pb "grpc-chat/proto"
+)
+
+// Handler to look up a user's presence and activity, accepts name
+func userProfileHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIdentifier(r)
+	username, ok := usernameFor(clientIP)
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := client.GetUserProfile(context.Background(), &pb.GetUserProfileRequest{
+		Username: username,
+		Target:   r.URL.Query().Get("name"),
+	})
+	if err != nil {
+		log.Printf("Error fetching user profile for %s: %v", username, err)
+		writeGRPCError(w, err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -21,13 +21,142 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type ChatMessage_MessageType int32
+
+const (
+	ChatMessage_CHAT            ChatMessage_MessageType = 0 // Regular message
+	ChatMessage_HISTORY_REQUEST ChatMessage_MessageType = 1 // Sent by a client to replay messages of a room after sequence, or the latest if zero
+	ChatMessage_HISTORY_GAP     ChatMessage_MessageType = 2 // Sent by the server when messages after the requested sequence can't be replayed,
+	// sequence holds the first sequence that is still available
+	ChatMessage_EDIT          ChatMessage_MessageType = 3  // Sent by the server when the message with this id was edited
+	ChatMessage_DELETE        ChatMessage_MessageType = 4  // Sent by the server when the message with this id was deleted
+	ChatMessage_REACTION      ChatMessage_MessageType = 5  // Sent by the server when the reactions on the message with this id changed
+	ChatMessage_THREAD_UPDATE ChatMessage_MessageType = 6  // Sent by the server when the reply count of the message with this id changed
+	ChatMessage_MENTION       ChatMessage_MessageType = 7  // Sent by the server to a mentioned user, a copy of the message that mentions them
+	ChatMessage_RECEIPT       ChatMessage_MessageType = 8  // Sent by the server to the author when the receipt of the message with this id changed
+	ChatMessage_TYPING        ChatMessage_MessageType = 9  // Sent by a client while typing in the room or to the recipient, relayed when typing starts or stops
+	ChatMessage_CONTACT       ChatMessage_MessageType = 10 // Sent by the server to both users when a contact request or contact changed
+)
+
+// Enum value maps for ChatMessage_MessageType.
+var (
+	ChatMessage_MessageType_name = map[int32]string{
+		0:  "CHAT",
+		1:  "HISTORY_REQUEST",
+		2:  "HISTORY_GAP",
+		3:  "EDIT",
+		4:  "DELETE",
+		5:  "REACTION",
+		6:  "THREAD_UPDATE",
+		7:  "MENTION",
+		8:  "RECEIPT",
+		9:  "TYPING",
+		10: "CONTACT",
+	}
+	ChatMessage_MessageType_value = map[string]int32{
+		"CHAT":            0,
+		"HISTORY_REQUEST": 1,
+		"HISTORY_GAP":     2,
+		"EDIT":            3,
+		"DELETE":          4,
+		"REACTION":        5,
+		"THREAD_UPDATE":   6,
+		"MENTION":         7,
+		"RECEIPT":         8,
+		"TYPING":          9,
+		"CONTACT":         10,
+	}
+)
+
+func (x ChatMessage_MessageType) Enum() *ChatMessage_MessageType {
+	p := new(ChatMessage_MessageType)
+	*p = x
+	return p
+}
+
+func (x ChatMessage_MessageType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChatMessage_MessageType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_chat_proto_enumTypes[0].Descriptor()
+}
+
+func (ChatMessage_MessageType) Type() protoreflect.EnumType {
+	return &file_proto_chat_proto_enumTypes[0]
+}
+
+func (x ChatMessage_MessageType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChatMessage_MessageType.Descriptor instead.
+func (ChatMessage_MessageType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{2, 0}
+}
+
+// Which users a subscriber hears about, the subscriber itself is always included
+type ActiveUsersRequest_Scope int32
+
+const (
+	ActiveUsersRequest_ALL      ActiveUsersRequest_Scope = 0 // Every active user, or the members of room when it is set
+	ActiveUsersRequest_ROOM     ActiveUsersRequest_Scope = 1 // Members of room
+	ActiveUsersRequest_CONTACTS ActiveUsersRequest_Scope = 2 // Contacts of username
+	ActiveUsersRequest_LIST     ActiveUsersRequest_Scope = 3 // The users named in usernames
+)
+
+// Enum value maps for ActiveUsersRequest_Scope.
+var (
+	ActiveUsersRequest_Scope_name = map[int32]string{
+		0: "ALL",
+		1: "ROOM",
+		2: "CONTACTS",
+		3: "LIST",
+	}
+	ActiveUsersRequest_Scope_value = map[string]int32{
+		"ALL":      0,
+		"ROOM":     1,
+		"CONTACTS": 2,
+		"LIST":     3,
+	}
+)
+
+func (x ActiveUsersRequest_Scope) Enum() *ActiveUsersRequest_Scope {
+	p := new(ActiveUsersRequest_Scope)
+	*p = x
+	return p
+}
+
+func (x ActiveUsersRequest_Scope) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ActiveUsersRequest_Scope) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_chat_proto_enumTypes[1].Descriptor()
+}
+
+func (ActiveUsersRequest_Scope) Type() protoreflect.EnumType {
+	return &file_proto_chat_proto_enumTypes[1]
+}
+
+func (x ActiveUsersRequest_Scope) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ActiveUsersRequest_Scope.Descriptor instead.
+func (ActiveUsersRequest_Scope) EnumDescriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{4, 0}
+}
+
 type ActiveUsersUpdate_UpdateType int32
 
 const (
-	ActiveUsersUpdate_FULL_LIST     ActiveUsersUpdate_UpdateType = 0
-	ActiveUsersUpdate_JOIN          ActiveUsersUpdate_UpdateType = 1
-	ActiveUsersUpdate_LEAVE         ActiveUsersUpdate_UpdateType = 2
-	ActiveUsersUpdate_STATUS_CHANGE ActiveUsersUpdate_UpdateType = 3
+	ActiveUsersUpdate_FULL_LIST      ActiveUsersUpdate_UpdateType = 0
+	ActiveUsersUpdate_JOIN           ActiveUsersUpdate_UpdateType = 1
+	ActiveUsersUpdate_LEAVE          ActiveUsersUpdate_UpdateType = 2
+	ActiveUsersUpdate_STATUS_CHANGE  ActiveUsersUpdate_UpdateType = 3 // Also sent when an invisible user reappears, clients add users they don't know yet
+	ActiveUsersUpdate_SESSION_CHANGE ActiveUsersUpdate_UpdateType = 4 // A user opened or closed a session while staying online
+	ActiveUsersUpdate_PROFILE_CHANGE ActiveUsersUpdate_UpdateType = 5 // A user changed their display name or avatar
 )
 
 // Enum value maps for ActiveUsersUpdate_UpdateType.
@@ -37,12 +166,16 @@ var (
 		1: "JOIN",
 		2: "LEAVE",
 		3: "STATUS_CHANGE",
+		4: "SESSION_CHANGE",
+		5: "PROFILE_CHANGE",
 	}
 	ActiveUsersUpdate_UpdateType_value = map[string]int32{
-		"FULL_LIST":     0,
-		"JOIN":          1,
-		"LEAVE":         2,
-		"STATUS_CHANGE": 3,
+		"FULL_LIST":      0,
+		"JOIN":           1,
+		"LEAVE":          2,
+		"STATUS_CHANGE":  3,
+		"SESSION_CHANGE": 4,
+		"PROFILE_CHANGE": 5,
 	}
 )
 
@@ -57,11 +190,11 @@ func (x ActiveUsersUpdate_UpdateType) String() string {
 }
 
 func (ActiveUsersUpdate_UpdateType) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_chat_proto_enumTypes[0].Descriptor()
+	return file_proto_chat_proto_enumTypes[2].Descriptor()
 }
 
 func (ActiveUsersUpdate_UpdateType) Type() protoreflect.EnumType {
-	return &file_proto_chat_proto_enumTypes[0]
+	return &file_proto_chat_proto_enumTypes[2]
 }
 
 func (x ActiveUsersUpdate_UpdateType) Number() protoreflect.EnumNumber {
@@ -70,7 +203,105 @@ func (x ActiveUsersUpdate_UpdateType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ActiveUsersUpdate_UpdateType.Descriptor instead.
 func (ActiveUsersUpdate_UpdateType) EnumDescriptor() ([]byte, []int) {
-	return file_proto_chat_proto_rawDescGZIP(), []int{4, 0}
+	return file_proto_chat_proto_rawDescGZIP(), []int{5, 0}
+}
+
+type AckRequest_AckType int32
+
+const (
+	AckRequest_DELIVERED AckRequest_AckType = 0 // Messages reached the client
+	AckRequest_READ      AckRequest_AckType = 1 // Messages were shown to the user, implies delivered
+)
+
+// Enum value maps for AckRequest_AckType.
+var (
+	AckRequest_AckType_name = map[int32]string{
+		0: "DELIVERED",
+		1: "READ",
+	}
+	AckRequest_AckType_value = map[string]int32{
+		"DELIVERED": 0,
+		"READ":      1,
+	}
+)
+
+func (x AckRequest_AckType) Enum() *AckRequest_AckType {
+	p := new(AckRequest_AckType)
+	*p = x
+	return p
+}
+
+func (x AckRequest_AckType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AckRequest_AckType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_chat_proto_enumTypes[3].Descriptor()
+}
+
+func (AckRequest_AckType) Type() protoreflect.EnumType {
+	return &file_proto_chat_proto_enumTypes[3]
+}
+
+func (x AckRequest_AckType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AckRequest_AckType.Descriptor instead.
+func (AckRequest_AckType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{28, 0}
+}
+
+type ContactEvent_Kind int32
+
+const (
+	ContactEvent_REQUESTED ContactEvent_Kind = 0 // from asked to to
+	ContactEvent_ACCEPTED  ContactEvent_Kind = 1 // to accepted the request of from, they are now contacts
+	ContactEvent_DECLINED  ContactEvent_Kind = 2 // The request of from to to was declined or withdrawn
+	ContactEvent_REMOVED   ContactEvent_Kind = 3 // from removed to from their contacts
+)
+
+// Enum value maps for ContactEvent_Kind.
+var (
+	ContactEvent_Kind_name = map[int32]string{
+		0: "REQUESTED",
+		1: "ACCEPTED",
+		2: "DECLINED",
+		3: "REMOVED",
+	}
+	ContactEvent_Kind_value = map[string]int32{
+		"REQUESTED": 0,
+		"ACCEPTED":  1,
+		"DECLINED":  2,
+		"REMOVED":   3,
+	}
+)
+
+func (x ContactEvent_Kind) Enum() *ContactEvent_Kind {
+	p := new(ContactEvent_Kind)
+	*p = x
+	return p
+}
+
+func (x ContactEvent_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ContactEvent_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_chat_proto_enumTypes[4].Descriptor()
+}
+
+func (ContactEvent_Kind) Type() protoreflect.EnumType {
+	return &file_proto_chat_proto_enumTypes[4]
+}
+
+func (x ContactEvent_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ContactEvent_Kind.Descriptor instead.
+func (ContactEvent_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{46, 0}
 }
 
 // Existing message types
@@ -171,12 +402,28 @@ func (x *LoginResponse) GetMessage() string {
 }
 
 type ChatMessage struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-	Timestamp     string                 `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state             protoimpl.MessageState  `protogen:"open.v1"`
+	Sender            string                  `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
+	Message           string                  `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Timestamp         string                  `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Room              string                  `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`           // Empty means the default room
+	Recipient         string                  `protobuf:"bytes,5,opt,name=recipient,proto3" json:"recipient,omitempty"` // Set for direct messages, which skip the room
+	Id                string                  `protobuf:"bytes,6,opt,name=id,proto3" json:"id,omitempty"`               // Unique ID assigned by the server
+	Sequence          int64                   `protobuf:"varint,7,opt,name=sequence,proto3" json:"sequence,omitempty"`  // Per-room sequence number assigned by the server
+	Type              ChatMessage_MessageType `protobuf:"varint,8,opt,name=type,proto3,enum=chat.ChatMessage_MessageType" json:"type,omitempty"`
+	Edited            bool                    `protobuf:"varint,9,opt,name=edited,proto3" json:"edited,omitempty"` // Set once the message has been edited
+	Reactions         []*Reaction             `protobuf:"bytes,10,rep,name=reactions,proto3" json:"reactions,omitempty"`
+	ParentId          string                  `protobuf:"bytes,11,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`                              // ID of the thread root this message replies to
+	ReplyCount        int32                   `protobuf:"varint,12,opt,name=reply_count,json=replyCount,proto3" json:"reply_count,omitempty"`                       // Number of replies, set on thread roots
+	Mentions          []string                `protobuf:"bytes,13,rep,name=mentions,proto3" json:"mentions,omitempty"`                                              // Users mentioned with @username, filled in by the server
+	Receipt           *MessageReceipt         `protobuf:"bytes,14,opt,name=receipt,proto3" json:"receipt,omitempty"`                                                // Set on receipt events
+	Device            string                  `protobuf:"bytes,15,opt,name=device,proto3" json:"device,omitempty"`                                                  // Describes the client session, set on join messages
+	Typing            bool                    `protobuf:"varint,16,opt,name=typing,proto3" json:"typing,omitempty"`                                                 // On typing messages, whether the sender is typing or stopped
+	SenderDisplayName string                  `protobuf:"bytes,17,opt,name=sender_display_name,json=senderDisplayName,proto3" json:"sender_display_name,omitempty"` // Display name of the sender when the message was sent, filled in by the server
+	Contact           *ContactEvent           `protobuf:"bytes,18,opt,name=contact,proto3" json:"contact,omitempty"`                                                // Set on contact events
+	SentAt            int64                   `protobuf:"varint,19,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`                                   // Unix time the server received the message, zero on messages stored before it was added
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *ChatMessage) Reset() {
@@ -230,143 +477,142 @@ func (x *ChatMessage) GetTimestamp() string {
 	return ""
 }
 
-type ActiveUsersRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ChatMessage) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
 }
 
-func (x *ActiveUsersRequest) Reset() {
-	*x = ActiveUsersRequest{}
-	mi := &file_proto_chat_proto_msgTypes[3]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ChatMessage) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
 }
 
-func (x *ActiveUsersRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ChatMessage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
 }
 
-func (*ActiveUsersRequest) ProtoMessage() {}
-
-func (x *ActiveUsersRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_chat_proto_msgTypes[3]
+func (x *ChatMessage) GetSequence() int64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Sequence
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use ActiveUsersRequest.ProtoReflect.Descriptor instead.
-func (*ActiveUsersRequest) Descriptor() ([]byte, []int) {
-	return file_proto_chat_proto_rawDescGZIP(), []int{3}
+func (x *ChatMessage) GetType() ChatMessage_MessageType {
+	if x != nil {
+		return x.Type
+	}
+	return ChatMessage_CHAT
 }
 
-func (x *ActiveUsersRequest) GetUsername() string {
+func (x *ChatMessage) GetEdited() bool {
 	if x != nil {
-		return x.Username
+		return x.Edited
 	}
-	return ""
+	return false
 }
 
-type ActiveUsersUpdate struct {
-	state         protoimpl.MessageState       `protogen:"open.v1"`
-	UpdateType    ActiveUsersUpdate_UpdateType `protobuf:"varint,1,opt,name=update_type,json=updateType,proto3,enum=chat.ActiveUsersUpdate_UpdateType" json:"update_type,omitempty"`
-	Username      string                       `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
-	Users         []string                     `protobuf:"bytes,3,rep,name=users,proto3" json:"users,omitempty"`
-	UserStatuses  map[string]string            `protobuf:"bytes,4,rep,name=user_statuses,json=userStatuses,proto3" json:"user_statuses,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Map username to status
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ChatMessage) GetReactions() []*Reaction {
+	if x != nil {
+		return x.Reactions
+	}
+	return nil
 }
 
-func (x *ActiveUsersUpdate) Reset() {
-	*x = ActiveUsersUpdate{}
-	mi := &file_proto_chat_proto_msgTypes[4]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ChatMessage) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
 }
 
-func (x *ActiveUsersUpdate) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ChatMessage) GetReplyCount() int32 {
+	if x != nil {
+		return x.ReplyCount
+	}
+	return 0
 }
 
-func (*ActiveUsersUpdate) ProtoMessage() {}
+func (x *ChatMessage) GetMentions() []string {
+	if x != nil {
+		return x.Mentions
+	}
+	return nil
+}
 
-func (x *ActiveUsersUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_chat_proto_msgTypes[4]
+func (x *ChatMessage) GetReceipt() *MessageReceipt {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Receipt
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use ActiveUsersUpdate.ProtoReflect.Descriptor instead.
-func (*ActiveUsersUpdate) Descriptor() ([]byte, []int) {
-	return file_proto_chat_proto_rawDescGZIP(), []int{4}
+func (x *ChatMessage) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
 }
 
-func (x *ActiveUsersUpdate) GetUpdateType() ActiveUsersUpdate_UpdateType {
+func (x *ChatMessage) GetTyping() bool {
 	if x != nil {
-		return x.UpdateType
+		return x.Typing
 	}
-	return ActiveUsersUpdate_FULL_LIST
+	return false
 }
 
-func (x *ActiveUsersUpdate) GetUsername() string {
+func (x *ChatMessage) GetSenderDisplayName() string {
 	if x != nil {
-		return x.Username
+		return x.SenderDisplayName
 	}
 	return ""
 }
 
-func (x *ActiveUsersUpdate) GetUsers() []string {
+func (x *ChatMessage) GetContact() *ContactEvent {
 	if x != nil {
-		return x.Users
+		return x.Contact
 	}
 	return nil
 }
 
-func (x *ActiveUsersUpdate) GetUserStatuses() map[string]string {
+func (x *ChatMessage) GetSentAt() int64 {
 	if x != nil {
-		return x.UserStatuses
+		return x.SentAt
 	}
-	return nil
+	return 0
 }
 
-// New message types for status updates
-type StatusUpdate struct {
+type Reaction struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // "typing" or "online"
-	Timestamp     string                 `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Emoji         string                 `protobuf:"bytes,1,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Users         []string               `protobuf:"bytes,3,rep,name=users,proto3" json:"users,omitempty"` // Users who reacted with this emoji
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StatusUpdate) Reset() {
-	*x = StatusUpdate{}
-	mi := &file_proto_chat_proto_msgTypes[5]
+func (x *Reaction) Reset() {
+	*x = Reaction{}
+	mi := &file_proto_chat_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatusUpdate) String() string {
+func (x *Reaction) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusUpdate) ProtoMessage() {}
+func (*Reaction) ProtoMessage() {}
 
-func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_chat_proto_msgTypes[5]
+func (x *Reaction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -377,55 +623,57 @@ func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusUpdate.ProtoReflect.Descriptor instead.
-func (*StatusUpdate) Descriptor() ([]byte, []int) {
-	return file_proto_chat_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use Reaction.ProtoReflect.Descriptor instead.
+func (*Reaction) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *StatusUpdate) GetUsername() string {
+func (x *Reaction) GetEmoji() string {
 	if x != nil {
-		return x.Username
+		return x.Emoji
 	}
 	return ""
 }
 
-func (x *StatusUpdate) GetStatus() string {
+func (x *Reaction) GetCount() int32 {
 	if x != nil {
-		return x.Status
+		return x.Count
 	}
-	return ""
+	return 0
 }
 
-func (x *StatusUpdate) GetTimestamp() string {
+func (x *Reaction) GetUsers() []string {
 	if x != nil {
-		return x.Timestamp
+		return x.Users
 	}
-	return ""
+	return nil
 }
 
-type StatusResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+type ActiveUsersRequest struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	Username      string                   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Room          string                   `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"` // Only report members of this room, empty for everyone
+	Scope         ActiveUsersRequest_Scope `protobuf:"varint,3,opt,name=scope,proto3,enum=chat.ActiveUsersRequest_Scope" json:"scope,omitempty"`
+	Usernames     []string                 `protobuf:"bytes,4,rep,name=usernames,proto3" json:"usernames,omitempty"` // Users to report with the LIST scope
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StatusResponse) Reset() {
-	*x = StatusResponse{}
-	mi := &file_proto_chat_proto_msgTypes[6]
+func (x *ActiveUsersRequest) Reset() {
+	*x = ActiveUsersRequest{}
+	mi := &file_proto_chat_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatusResponse) String() string {
+func (x *ActiveUsersRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusResponse) ProtoMessage() {}
+func (*ActiveUsersRequest) ProtoMessage() {}
 
-func (x *StatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_chat_proto_msgTypes[6]
+func (x *ActiveUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -436,21 +684,2654 @@ func (x *StatusResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
-func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return file_proto_chat_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use ActiveUsersRequest.ProtoReflect.Descriptor instead.
+func (*ActiveUsersRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *StatusResponse) GetSuccess() bool {
+func (x *ActiveUsersRequest) GetUsername() string {
 	if x != nil {
-		return x.Success
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ActiveUsersRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ActiveUsersRequest) GetScope() ActiveUsersRequest_Scope {
+	if x != nil {
+		return x.Scope
+	}
+	return ActiveUsersRequest_ALL
+}
+
+func (x *ActiveUsersRequest) GetUsernames() []string {
+	if x != nil {
+		return x.Usernames
+	}
+	return nil
+}
+
+type ActiveUsersUpdate struct {
+	state           protoimpl.MessageState       `protogen:"open.v1"`
+	UpdateType      ActiveUsersUpdate_UpdateType `protobuf:"varint,1,opt,name=update_type,json=updateType,proto3,enum=chat.ActiveUsersUpdate_UpdateType" json:"update_type,omitempty"`
+	Username        string                       `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Users           []string                     `protobuf:"bytes,3,rep,name=users,proto3" json:"users,omitempty"`
+	UserStatuses    map[string]string            `protobuf:"bytes,4,rep,name=user_statuses,json=userStatuses,proto3" json:"user_statuses,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`         // Map username to status
+	Room            string                       `protobuf:"bytes,5,opt,name=room,proto3" json:"room,omitempty"`                                                                                                                       // Set when the update is scoped to a single room
+	CustomStatuses  map[string]*CustomStatus     `protobuf:"bytes,6,rep,name=custom_statuses,json=customStatuses,proto3" json:"custom_statuses,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`   // Custom statuses of the users that have one
+	Sessions        map[string]*UserSessions     `protobuf:"bytes,7,rep,name=sessions,proto3" json:"sessions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`                                     // Open sessions of the users in the update
+	Version         int64                        `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`                                                                                                                // Presence version after this update, FULL_LIST carries the version it reflects
+	PreviousVersion int64                        `protobuf:"varint,9,opt,name=previous_version,json=previousVersion,proto3" json:"previous_version,omitempty"`                                                                         // Version of the previous update on this stream, newer than the last one seen means deltas were missed
+	DisplayNames    map[string]string            `protobuf:"bytes,10,rep,name=display_names,json=displayNames,proto3" json:"display_names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`        // Display names of the users in the update
+	AvatarVersions  map[string]int64             `protobuf:"bytes,11,rep,name=avatar_versions,json=avatarVersions,proto3" json:"avatar_versions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Unix time the avatar of each user in the update changed, users without one are left out
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ActiveUsersUpdate) Reset() {
+	*x = ActiveUsersUpdate{}
+	mi := &file_proto_chat_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActiveUsersUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActiveUsersUpdate) ProtoMessage() {}
+
+func (x *ActiveUsersUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActiveUsersUpdate.ProtoReflect.Descriptor instead.
+func (*ActiveUsersUpdate) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ActiveUsersUpdate) GetUpdateType() ActiveUsersUpdate_UpdateType {
+	if x != nil {
+		return x.UpdateType
+	}
+	return ActiveUsersUpdate_FULL_LIST
+}
+
+func (x *ActiveUsersUpdate) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ActiveUsersUpdate) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *ActiveUsersUpdate) GetUserStatuses() map[string]string {
+	if x != nil {
+		return x.UserStatuses
+	}
+	return nil
+}
+
+func (x *ActiveUsersUpdate) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ActiveUsersUpdate) GetCustomStatuses() map[string]*CustomStatus {
+	if x != nil {
+		return x.CustomStatuses
+	}
+	return nil
+}
+
+func (x *ActiveUsersUpdate) GetSessions() map[string]*UserSessions {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+func (x *ActiveUsersUpdate) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ActiveUsersUpdate) GetPreviousVersion() int64 {
+	if x != nil {
+		return x.PreviousVersion
+	}
+	return 0
+}
+
+func (x *ActiveUsersUpdate) GetDisplayNames() map[string]string {
+	if x != nil {
+		return x.DisplayNames
+	}
+	return nil
+}
+
+func (x *ActiveUsersUpdate) GetAvatarVersions() map[string]int64 {
+	if x != nil {
+		return x.AvatarVersions
+	}
+	return nil
+}
+
+type UserSessions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Devices       []string               `protobuf:"bytes,2,rep,name=devices,proto3" json:"devices,omitempty"` // One entry per session, oldest first
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserSessions) Reset() {
+	*x = UserSessions{}
+	mi := &file_proto_chat_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserSessions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserSessions) ProtoMessage() {}
+
+func (x *UserSessions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserSessions.ProtoReflect.Descriptor instead.
+func (*UserSessions) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UserSessions) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *UserSessions) GetDevices() []string {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+// New message types for status updates
+type StatusUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // "online", "away", "busy", "dnd" or "invisible"
+	Timestamp     string                 `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Custom        *CustomStatus          `protobuf:"bytes,4,opt,name=custom,proto3" json:"custom,omitempty"` // Replaces the custom status when set, an empty one clears it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusUpdate) Reset() {
+	*x = StatusUpdate{}
+	mi := &file_proto_chat_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusUpdate) ProtoMessage() {}
+
+func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusUpdate.ProtoReflect.Descriptor instead.
+func (*StatusUpdate) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StatusUpdate) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *StatusUpdate) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *StatusUpdate) GetTimestamp() string {
+	if x != nil {
+		return x.Timestamp
+	}
+	return ""
+}
+
+func (x *StatusUpdate) GetCustom() *CustomStatus {
+	if x != nil {
+		return x.Custom
+	}
+	return nil
+}
+
+type CustomStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Emoji         string                 `protobuf:"bytes,2,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	ExpiresAt     int64                  `protobuf:"varint,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // Unix time when the custom status is cleared, zero keeps it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CustomStatus) Reset() {
+	*x = CustomStatus{}
+	mi := &file_proto_chat_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CustomStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CustomStatus) ProtoMessage() {}
+
+func (x *CustomStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CustomStatus.ProtoReflect.Descriptor instead.
+func (*CustomStatus) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CustomStatus) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *CustomStatus) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *CustomStatus) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type StatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_proto_chat_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StatusResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *StatusResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Room message types
+type RoomInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	CreatedBy     string                 `protobuf:"bytes,3,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"`
+	Members       []string               `protobuf:"bytes,4,rep,name=members,proto3" json:"members,omitempty"`
+	Retention     *RetentionPolicy       `protobuf:"bytes,5,opt,name=retention,proto3" json:"retention,omitempty"` // Set when the room has its own policy, otherwise the server's applies
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomInfo) Reset() {
+	*x = RoomInfo{}
+	mi := &file_proto_chat_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomInfo) ProtoMessage() {}
+
+func (x *RoomInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomInfo.ProtoReflect.Descriptor instead.
+func (*RoomInfo) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RoomInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RoomInfo) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *RoomInfo) GetCreatedBy() string {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return ""
+}
+
+func (x *RoomInfo) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *RoomInfo) GetRetention() *RetentionPolicy {
+	if x != nil {
+		return x.Retention
+	}
+	return nil
+}
+
+// How long the messages of a room are kept, the server's maximum age applies to every room when it has one
+type RetentionPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MaxAgeSeconds int64                  `protobuf:"varint,1,opt,name=max_age_seconds,json=maxAgeSeconds,proto3" json:"max_age_seconds,omitempty"` // Messages older than this are deleted, zero leaves only the server's limit
+	MaxMessages   int32                  `protobuf:"varint,2,opt,name=max_messages,json=maxMessages,proto3" json:"max_messages,omitempty"`         // Only the newest messages are kept, zero keeps any number
+	KeepForever   bool                   `protobuf:"varint,3,opt,name=keep_forever,json=keepForever,proto3" json:"keep_forever,omitempty"`         // Never delete messages, the other fields must be zero
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetentionPolicy) Reset() {
+	*x = RetentionPolicy{}
+	mi := &file_proto_chat_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetentionPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetentionPolicy) ProtoMessage() {}
+
+func (x *RetentionPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetentionPolicy.ProtoReflect.Descriptor instead.
+func (*RetentionPolicy) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RetentionPolicy) GetMaxAgeSeconds() int64 {
+	if x != nil {
+		return x.MaxAgeSeconds
+	}
+	return 0
+}
+
+func (x *RetentionPolicy) GetMaxMessages() int32 {
+	if x != nil {
+		return x.MaxMessages
+	}
+	return 0
+}
+
+func (x *RetentionPolicy) GetKeepForever() bool {
+	if x != nil {
+		return x.KeepForever
+	}
+	return false
+}
+
+type SetRoomRetentionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Retention     *RetentionPolicy       `protobuf:"bytes,3,opt,name=retention,proto3" json:"retention,omitempty"` // Unset goes back to the server's policy
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetRoomRetentionRequest) Reset() {
+	*x = SetRoomRetentionRequest{}
+	mi := &file_proto_chat_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetRoomRetentionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetRoomRetentionRequest) ProtoMessage() {}
+
+func (x *SetRoomRetentionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetRoomRetentionRequest.ProtoReflect.Descriptor instead.
+func (*SetRoomRetentionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *SetRoomRetentionRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *SetRoomRetentionRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *SetRoomRetentionRequest) GetRetention() *RetentionPolicy {
+	if x != nil {
+		return x.Retention
+	}
+	return nil
+}
+
+type CreateRoomRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRoomRequest) Reset() {
+	*x = CreateRoomRequest{}
+	mi := &file_proto_chat_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRoomRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRoomRequest) ProtoMessage() {}
+
+func (x *CreateRoomRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRoomRequest.ProtoReflect.Descriptor instead.
+func (*CreateRoomRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CreateRoomRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *CreateRoomRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *CreateRoomRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type RoomRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomRequest) Reset() {
+	*x = RoomRequest{}
+	mi := &file_proto_chat_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomRequest) ProtoMessage() {}
+
+func (x *RoomRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomRequest.ProtoReflect.Descriptor instead.
+func (*RoomRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RoomRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *RoomRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+type RoomResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Room          *RoomInfo              `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomResponse) Reset() {
+	*x = RoomResponse{}
+	mi := &file_proto_chat_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomResponse) ProtoMessage() {}
+
+func (x *RoomResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomResponse.ProtoReflect.Descriptor instead.
+func (*RoomResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RoomResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RoomResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RoomResponse) GetRoom() *RoomInfo {
+	if x != nil {
+		return x.Room
+	}
+	return nil
+}
+
+type ListRoomsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"` // When set, only rooms this user has joined
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRoomsRequest) Reset() {
+	*x = ListRoomsRequest{}
+	mi := &file_proto_chat_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRoomsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoomsRequest) ProtoMessage() {}
+
+func (x *ListRoomsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoomsRequest.ProtoReflect.Descriptor instead.
+func (*ListRoomsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListRoomsRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ListRoomsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rooms         []*RoomInfo            `protobuf:"bytes,1,rep,name=rooms,proto3" json:"rooms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRoomsResponse) Reset() {
+	*x = ListRoomsResponse{}
+	mi := &file_proto_chat_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRoomsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoomsResponse) ProtoMessage() {}
+
+func (x *ListRoomsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoomsResponse.ProtoReflect.Descriptor instead.
+func (*ListRoomsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ListRoomsResponse) GetRooms() []*RoomInfo {
+	if x != nil {
+		return x.Rooms
+	}
+	return nil
+}
+
+// History message types
+type GetHistoryRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Username       string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Room           string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	BeforeSequence int64                  `protobuf:"varint,3,opt,name=before_sequence,json=beforeSequence,proto3" json:"before_sequence,omitempty"` // Only messages older than this sequence
+	AfterSequence  int64                  `protobuf:"varint,4,opt,name=after_sequence,json=afterSequence,proto3" json:"after_sequence,omitempty"`    // Only messages newer than this sequence
+	Limit          int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`                                         // Page size, defaults to 50
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetHistoryRequest) Reset() {
+	*x = GetHistoryRequest{}
+	mi := &file_proto_chat_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryRequest) ProtoMessage() {}
+
+func (x *GetHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GetHistoryRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *GetHistoryRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *GetHistoryRequest) GetBeforeSequence() int64 {
+	if x != nil {
+		return x.BeforeSequence
+	}
+	return 0
+}
+
+func (x *GetHistoryRequest) GetAfterSequence() int64 {
+	if x != nil {
+		return x.AfterSequence
+	}
+	return 0
+}
+
+func (x *GetHistoryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*ChatMessage         `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`               // Oldest first
+	HasMore       bool                   `protobuf:"varint,2,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"` // More messages exist past this page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHistoryResponse) Reset() {
+	*x = GetHistoryResponse{}
+	mi := &file_proto_chat_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryResponse) ProtoMessage() {}
+
+func (x *GetHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetHistoryResponse) GetMessages() []*ChatMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *GetHistoryResponse) GetHasMore() bool {
+	if x != nil {
+		return x.HasMore
+	}
+	return false
+}
+
+type SearchMessagesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`                    // User searching, only rooms they have joined are searched
+	Query         string                 `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`                          // Words that must all appear, case-insensitive
+	Sender        string                 `protobuf:"bytes,3,opt,name=sender,proto3" json:"sender,omitempty"`                        // Only messages from this user
+	Room          string                 `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`                            // Only this room, empty searches every room the user has joined
+	Since         int64                  `protobuf:"varint,5,opt,name=since,proto3" json:"since,omitempty"`                         // Unix time, only messages sent at or after it
+	Until         int64                  `protobuf:"varint,6,opt,name=until,proto3" json:"until,omitempty"`                         // Unix time, only messages sent before it
+	Limit         int32                  `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`                         // Page size, defaults to 50
+	PageToken     string                 `protobuf:"bytes,8,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"` // next_page_token of the previous page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchMessagesRequest) Reset() {
+	*x = SearchMessagesRequest{}
+	mi := &file_proto_chat_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchMessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchMessagesRequest) ProtoMessage() {}
+
+func (x *SearchMessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchMessagesRequest.ProtoReflect.Descriptor instead.
+func (*SearchMessagesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SearchMessagesRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *SearchMessagesRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchMessagesRequest) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *SearchMessagesRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *SearchMessagesRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *SearchMessagesRequest) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+func (x *SearchMessagesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchMessagesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type SearchMessagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*ChatMessage         `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`                                  // Newest first
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchMessagesResponse) Reset() {
+	*x = SearchMessagesResponse{}
+	mi := &file_proto_chat_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchMessagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchMessagesResponse) ProtoMessage() {}
+
+func (x *SearchMessagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchMessagesResponse.ProtoReflect.Descriptor instead.
+func (*SearchMessagesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *SearchMessagesResponse) GetMessages() []*ChatMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *SearchMessagesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// Moderation message types
+type EditMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"` // User performing the edit
+	MessageId     string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"` // New message text
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EditMessageRequest) Reset() {
+	*x = EditMessageRequest{}
+	mi := &file_proto_chat_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EditMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditMessageRequest) ProtoMessage() {}
+
+func (x *EditMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditMessageRequest.ProtoReflect.Descriptor instead.
+func (*EditMessageRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *EditMessageRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *EditMessageRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *EditMessageRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type DeleteMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"` // User performing the delete
+	MessageId     string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMessageRequest) Reset() {
+	*x = DeleteMessageRequest{}
+	mi := &file_proto_chat_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMessageRequest) ProtoMessage() {}
+
+func (x *DeleteMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMessageRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMessageRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *DeleteMessageRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *DeleteMessageRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+type MessageActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	ChatMessage   *ChatMessage           `protobuf:"bytes,3,opt,name=chat_message,json=chatMessage,proto3" json:"chat_message,omitempty"` // The message as it is after the action
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageActionResponse) Reset() {
+	*x = MessageActionResponse{}
+	mi := &file_proto_chat_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageActionResponse) ProtoMessage() {}
+
+func (x *MessageActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageActionResponse.ProtoReflect.Descriptor instead.
+func (*MessageActionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *MessageActionResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *MessageActionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *MessageActionResponse) GetChatMessage() *ChatMessage {
+	if x != nil {
+		return x.ChatMessage
+	}
+	return nil
+}
+
+// Reaction message types
+type ReactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	MessageId     string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Emoji         string                 `protobuf:"bytes,3,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReactionRequest) Reset() {
+	*x = ReactionRequest{}
+	mi := &file_proto_chat_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReactionRequest) ProtoMessage() {}
+
+func (x *ReactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReactionRequest.ProtoReflect.Descriptor instead.
+func (*ReactionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ReactionRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ReactionRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *ReactionRequest) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+// Thread message types
+type GetThreadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	MessageId     string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"` // ID of the thread root, or of any reply in it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetThreadRequest) Reset() {
+	*x = GetThreadRequest{}
+	mi := &file_proto_chat_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetThreadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetThreadRequest) ProtoMessage() {}
+
+func (x *GetThreadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetThreadRequest.ProtoReflect.Descriptor instead.
+func (*GetThreadRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *GetThreadRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *GetThreadRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+type GetThreadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Root          *ChatMessage           `protobuf:"bytes,1,opt,name=root,proto3" json:"root,omitempty"`       // Without its text when the user blocked its author
+	Replies       []*ChatMessage         `protobuf:"bytes,2,rep,name=replies,proto3" json:"replies,omitempty"` // Oldest first, replies from blocked users are left out
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetThreadResponse) Reset() {
+	*x = GetThreadResponse{}
+	mi := &file_proto_chat_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetThreadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetThreadResponse) ProtoMessage() {}
+
+func (x *GetThreadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetThreadResponse.ProtoReflect.Descriptor instead.
+func (*GetThreadResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *GetThreadResponse) GetRoot() *ChatMessage {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *GetThreadResponse) GetReplies() []*ChatMessage {
+	if x != nil {
+		return x.Replies
+	}
+	return nil
+}
+
+// Receipt message types
+type AckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	MessageId     string                 `protobuf:"bytes,3,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"` // Acknowledge up to and including this message
+	Sequence      int64                  `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`                   // Or up to and including this sequence when message_id is empty
+	Type          AckRequest_AckType     `protobuf:"varint,5,opt,name=type,proto3,enum=chat.AckRequest_AckType" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AckRequest) Reset() {
+	*x = AckRequest{}
+	mi := &file_proto_chat_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AckRequest) ProtoMessage() {}
+
+func (x *AckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AckRequest.ProtoReflect.Descriptor instead.
+func (*AckRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *AckRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *AckRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *AckRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *AckRequest) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *AckRequest) GetType() AckRequest_AckType {
+	if x != nil {
+		return x.Type
+	}
+	return AckRequest_DELIVERED
+}
+
+type AckResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Success           bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message           string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	DeliveredSequence int64                  `protobuf:"varint,3,opt,name=delivered_sequence,json=deliveredSequence,proto3" json:"delivered_sequence,omitempty"` // The user's positions in the room after the ack
+	ReadSequence      int64                  `protobuf:"varint,4,opt,name=read_sequence,json=readSequence,proto3" json:"read_sequence,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *AckResponse) Reset() {
+	*x = AckResponse{}
+	mi := &file_proto_chat_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AckResponse) ProtoMessage() {}
+
+func (x *AckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AckResponse.ProtoReflect.Descriptor instead.
+func (*AckResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *AckResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *AckResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *AckResponse) GetDeliveredSequence() int64 {
+	if x != nil {
+		return x.DeliveredSequence
+	}
+	return 0
+}
+
+func (x *AckResponse) GetReadSequence() int64 {
+	if x != nil {
+		return x.ReadSequence
+	}
+	return 0
+}
+
+type MessageReceipt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Sequence      int64                  `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	DeliveredTo   []string               `protobuf:"bytes,4,rep,name=delivered_to,json=deliveredTo,proto3" json:"delivered_to,omitempty"` // Users other than the author that received the message
+	ReadBy        []string               `protobuf:"bytes,5,rep,name=read_by,json=readBy,proto3" json:"read_by,omitempty"`                // Users other than the author that read the message
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageReceipt) Reset() {
+	*x = MessageReceipt{}
+	mi := &file_proto_chat_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageReceipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageReceipt) ProtoMessage() {}
+
+func (x *MessageReceipt) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageReceipt.ProtoReflect.Descriptor instead.
+func (*MessageReceipt) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *MessageReceipt) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageReceipt) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *MessageReceipt) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *MessageReceipt) GetDeliveredTo() []string {
+	if x != nil {
+		return x.DeliveredTo
+	}
+	return nil
+}
+
+func (x *MessageReceipt) GetReadBy() []string {
+	if x != nil {
+		return x.ReadBy
+	}
+	return nil
+}
+
+type GetReceiptsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	MessageIds    []string               `protobuf:"bytes,2,rep,name=message_ids,json=messageIds,proto3" json:"message_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReceiptsRequest) Reset() {
+	*x = GetReceiptsRequest{}
+	mi := &file_proto_chat_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReceiptsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReceiptsRequest) ProtoMessage() {}
+
+func (x *GetReceiptsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReceiptsRequest.ProtoReflect.Descriptor instead.
+func (*GetReceiptsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *GetReceiptsRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *GetReceiptsRequest) GetMessageIds() []string {
+	if x != nil {
+		return x.MessageIds
+	}
+	return nil
+}
+
+type GetReceiptsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Receipts      []*MessageReceipt      `protobuf:"bytes,1,rep,name=receipts,proto3" json:"receipts,omitempty"` // Messages that were deleted or aren't stored are left out
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReceiptsResponse) Reset() {
+	*x = GetReceiptsResponse{}
+	mi := &file_proto_chat_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReceiptsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReceiptsResponse) ProtoMessage() {}
+
+func (x *GetReceiptsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReceiptsResponse.ProtoReflect.Descriptor instead.
+func (*GetReceiptsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *GetReceiptsResponse) GetReceipts() []*MessageReceipt {
+	if x != nil {
+		return x.Receipts
+	}
+	return nil
+}
+
+// User message types
+type GetUserProfileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"` // User asking
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`     // User to look up
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserProfileRequest) Reset() {
+	*x = GetUserProfileRequest{}
+	mi := &file_proto_chat_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserProfileRequest) ProtoMessage() {}
+
+func (x *GetUserProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserProfileRequest.ProtoReflect.Descriptor instead.
+func (*GetUserProfileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *GetUserProfileRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *GetUserProfileRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type UserProfile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Online        bool                   `protobuf:"varint,2,opt,name=online,proto3" json:"online,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"` // Presence shown to the asking user, "offline" when not connected
+	CustomStatus  *CustomStatus          `protobuf:"bytes,4,opt,name=custom_status,json=customStatus,proto3" json:"custom_status,omitempty"`
+	JoinedAt      int64                  `protobuf:"varint,5,opt,name=joined_at,json=joinedAt,proto3" json:"joined_at,omitempty"`             // Unix time the user's latest session joined
+	LastSeen      int64                  `protobuf:"varint,6,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`             // Unix time the user was last connected, now while online
+	MessageCount  int64                  `protobuf:"varint,7,opt,name=message_count,json=messageCount,proto3" json:"message_count,omitempty"` // Messages sent, kept across restarts when the store keeps users
+	Profile       *Profile               `protobuf:"bytes,8,opt,name=profile,proto3" json:"profile,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserProfile) Reset() {
+	*x = UserProfile{}
+	mi := &file_proto_chat_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserProfile) ProtoMessage() {}
+
+func (x *UserProfile) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserProfile.ProtoReflect.Descriptor instead.
+func (*UserProfile) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *UserProfile) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *UserProfile) GetOnline() bool {
+	if x != nil {
+		return x.Online
+	}
+	return false
+}
+
+func (x *UserProfile) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *UserProfile) GetCustomStatus() *CustomStatus {
+	if x != nil {
+		return x.CustomStatus
+	}
+	return nil
+}
+
+func (x *UserProfile) GetJoinedAt() int64 {
+	if x != nil {
+		return x.JoinedAt
+	}
+	return 0
+}
+
+func (x *UserProfile) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+func (x *UserProfile) GetMessageCount() int64 {
+	if x != nil {
+		return x.MessageCount
+	}
+	return 0
+}
+
+func (x *UserProfile) GetProfile() *Profile {
+	if x != nil {
+		return x.Profile
+	}
+	return nil
+}
+
+// Profile message types
+type GetProfileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProfileRequest) Reset() {
+	*x = GetProfileRequest{}
+	mi := &file_proto_chat_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProfileRequest) ProtoMessage() {}
+
+func (x *GetProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProfileRequest.ProtoReflect.Descriptor instead.
+func (*GetProfileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *GetProfileRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type Profile struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Username        string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	DisplayName     string                 `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"` // Falls back to the username when not set
+	Bio             string                 `protobuf:"bytes,3,opt,name=bio,proto3" json:"bio,omitempty"`
+	AvatarType      string                 `protobuf:"bytes,4,opt,name=avatar_type,json=avatarType,proto3" json:"avatar_type,omitempty"`                   // Content type of the avatar, empty without one
+	AvatarUpdatedAt int64                  `protobuf:"varint,5,opt,name=avatar_updated_at,json=avatarUpdatedAt,proto3" json:"avatar_updated_at,omitempty"` // Unix time the avatar was uploaded
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Profile) Reset() {
+	*x = Profile{}
+	mi := &file_proto_chat_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Profile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Profile) ProtoMessage() {}
+
+func (x *Profile) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Profile.ProtoReflect.Descriptor instead.
+func (*Profile) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *Profile) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Profile) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *Profile) GetBio() string {
+	if x != nil {
+		return x.Bio
+	}
+	return ""
+}
+
+func (x *Profile) GetAvatarType() string {
+	if x != nil {
+		return x.AvatarType
+	}
+	return ""
+}
+
+func (x *Profile) GetAvatarUpdatedAt() int64 {
+	if x != nil {
+		return x.AvatarUpdatedAt
+	}
+	return 0
+}
+
+type UpdateProfileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	DisplayName   *string                `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3,oneof" json:"display_name,omitempty"` // Left unchanged when not set, empty resets it to the username
+	Bio           *string                `protobuf:"bytes,3,opt,name=bio,proto3,oneof" json:"bio,omitempty"`                                    // Left unchanged when not set, empty clears it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateProfileRequest) Reset() {
+	*x = UpdateProfileRequest{}
+	mi := &file_proto_chat_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateProfileRequest) ProtoMessage() {}
+
+func (x *UpdateProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateProfileRequest.ProtoReflect.Descriptor instead.
+func (*UpdateProfileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *UpdateProfileRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *UpdateProfileRequest) GetDisplayName() string {
+	if x != nil && x.DisplayName != nil {
+		return *x.DisplayName
+	}
+	return ""
+}
+
+func (x *UpdateProfileRequest) GetBio() string {
+	if x != nil && x.Bio != nil {
+		return *x.Bio
+	}
+	return ""
+}
+
+type AvatarChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`                          // Set on the first chunk of an upload
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"` // Set on the first chunk of a download, uploads are sniffed instead
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AvatarChunk) Reset() {
+	*x = AvatarChunk{}
+	mi := &file_proto_chat_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AvatarChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AvatarChunk) ProtoMessage() {}
+
+func (x *AvatarChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AvatarChunk.ProtoReflect.Descriptor instead.
+func (*AvatarChunk) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *AvatarChunk) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *AvatarChunk) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *AvatarChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// Contact message types
+type ContactActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"` // User taking the action
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`     // Other user
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContactActionRequest) Reset() {
+	*x = ContactActionRequest{}
+	mi := &file_proto_chat_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContactActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactActionRequest) ProtoMessage() {}
+
+func (x *ContactActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactActionRequest.ProtoReflect.Descriptor instead.
+func (*ContactActionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *ContactActionRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ContactActionRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type ContactActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Contacts      bool                   `protobuf:"varint,3,opt,name=contacts,proto3" json:"contacts,omitempty"` // Whether the users are contacts after the action
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContactActionResponse) Reset() {
+	*x = ContactActionResponse{}
+	mi := &file_proto_chat_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContactActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactActionResponse) ProtoMessage() {}
+
+func (x *ContactActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactActionResponse.ProtoReflect.Descriptor instead.
+func (*ContactActionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *ContactActionResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ContactActionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ContactActionResponse) GetContacts() bool {
+	if x != nil {
+		return x.Contacts
+	}
+	return false
+}
+
+type ListContactsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContactsRequest) Reset() {
+	*x = ListContactsRequest{}
+	mi := &file_proto_chat_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContactsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContactsRequest) ProtoMessage() {}
+
+func (x *ListContactsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContactsRequest.ProtoReflect.Descriptor instead.
+func (*ListContactsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ListContactsRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type Contact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	DisplayName   string                 `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	Since         int64                  `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`   // Unix time the users became contacts
+	Online        bool                   `protobuf:"varint,4,opt,name=online,proto3" json:"online,omitempty"` // Invisible contacts are shown as offline
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Contact) Reset() {
+	*x = Contact{}
+	mi := &file_proto_chat_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Contact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Contact) ProtoMessage() {}
+
+func (x *Contact) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Contact.ProtoReflect.Descriptor instead.
+func (*Contact) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *Contact) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Contact) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *Contact) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *Contact) GetOnline() bool {
+	if x != nil {
+		return x.Online
 	}
 	return false
 }
 
-func (x *StatusResponse) GetMessage() string {
+type PendingContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"` // The other user
+	DisplayName   string                 `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	SentAt        int64                  `protobuf:"varint,3,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"` // Unix time the request was sent
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PendingContactRequest) Reset() {
+	*x = PendingContactRequest{}
+	mi := &file_proto_chat_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PendingContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PendingContactRequest) ProtoMessage() {}
+
+func (x *PendingContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[43]
 	if x != nil {
-		return x.Message
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PendingContactRequest.ProtoReflect.Descriptor instead.
+func (*PendingContactRequest) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *PendingContactRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *PendingContactRequest) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *PendingContactRequest) GetSentAt() int64 {
+	if x != nil {
+		return x.SentAt
+	}
+	return 0
+}
+
+type ListContactsResponse struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	Contacts      []*Contact               `protobuf:"bytes,1,rep,name=contacts,proto3" json:"contacts,omitempty"`
+	Incoming      []*PendingContactRequest `protobuf:"bytes,2,rep,name=incoming,proto3" json:"incoming,omitempty"` // Requests waiting for this user to answer
+	Outgoing      []*PendingContactRequest `protobuf:"bytes,3,rep,name=outgoing,proto3" json:"outgoing,omitempty"` // Requests this user sent that are still open
+	Blocked       []*BlockedUser           `protobuf:"bytes,4,rep,name=blocked,proto3" json:"blocked,omitempty"`   // Users this user blocked
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContactsResponse) Reset() {
+	*x = ListContactsResponse{}
+	mi := &file_proto_chat_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContactsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContactsResponse) ProtoMessage() {}
+
+func (x *ListContactsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContactsResponse.ProtoReflect.Descriptor instead.
+func (*ListContactsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ListContactsResponse) GetContacts() []*Contact {
+	if x != nil {
+		return x.Contacts
+	}
+	return nil
+}
+
+func (x *ListContactsResponse) GetIncoming() []*PendingContactRequest {
+	if x != nil {
+		return x.Incoming
+	}
+	return nil
+}
+
+func (x *ListContactsResponse) GetOutgoing() []*PendingContactRequest {
+	if x != nil {
+		return x.Outgoing
+	}
+	return nil
+}
+
+func (x *ListContactsResponse) GetBlocked() []*BlockedUser {
+	if x != nil {
+		return x.Blocked
+	}
+	return nil
+}
+
+type BlockedUser struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	DisplayName   string                 `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	Since         int64                  `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"` // Unix time the user was blocked
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockedUser) Reset() {
+	*x = BlockedUser{}
+	mi := &file_proto_chat_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockedUser) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockedUser) ProtoMessage() {}
+
+func (x *BlockedUser) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockedUser.ProtoReflect.Descriptor instead.
+func (*BlockedUser) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *BlockedUser) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *BlockedUser) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *BlockedUser) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+type ContactEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          ContactEvent_Kind      `protobuf:"varint,1,opt,name=kind,proto3,enum=chat.ContactEvent_Kind" json:"kind,omitempty"`
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContactEvent) Reset() {
+	*x = ContactEvent{}
+	mi := &file_proto_chat_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContactEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactEvent) ProtoMessage() {}
+
+func (x *ContactEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_chat_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactEvent.ProtoReflect.Descriptor instead.
+func (*ContactEvent) Descriptor() ([]byte, []int) {
+	return file_proto_chat_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ContactEvent) GetKind() ContactEvent_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return ContactEvent_REQUESTED
+}
+
+func (x *ContactEvent) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ContactEvent) GetTo() string {
+	if x != nil {
+		return x.To
 	}
 	return ""
 }
@@ -464,41 +3345,320 @@ const file_proto_chat_proto_rawDesc = "" +
 	"\busername\x18\x01 \x01(\tR\busername\"E\n" +
 	"\rLoginResponse\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"]\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\x8f\x06\n" +
 	"\vChatMessage\x12\x16\n" +
 	"\x06sender\x18\x01 \x01(\tR\x06sender\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1c\n" +
-	"\ttimestamp\x18\x03 \x01(\tR\ttimestamp\"0\n" +
+	"\ttimestamp\x18\x03 \x01(\tR\ttimestamp\x12\x12\n" +
+	"\x04room\x18\x04 \x01(\tR\x04room\x12\x1c\n" +
+	"\trecipient\x18\x05 \x01(\tR\trecipient\x12\x0e\n" +
+	"\x02id\x18\x06 \x01(\tR\x02id\x12\x1a\n" +
+	"\bsequence\x18\a \x01(\x03R\bsequence\x121\n" +
+	"\x04type\x18\b \x01(\x0e2\x1d.chat.ChatMessage.MessageTypeR\x04type\x12\x16\n" +
+	"\x06edited\x18\t \x01(\bR\x06edited\x12,\n" +
+	"\treactions\x18\n" +
+	" \x03(\v2\x0e.chat.ReactionR\treactions\x12\x1b\n" +
+	"\tparent_id\x18\v \x01(\tR\bparentId\x12\x1f\n" +
+	"\vreply_count\x18\f \x01(\x05R\n" +
+	"replyCount\x12\x1a\n" +
+	"\bmentions\x18\r \x03(\tR\bmentions\x12.\n" +
+	"\areceipt\x18\x0e \x01(\v2\x14.chat.MessageReceiptR\areceipt\x12\x16\n" +
+	"\x06device\x18\x0f \x01(\tR\x06device\x12\x16\n" +
+	"\x06typing\x18\x10 \x01(\bR\x06typing\x12.\n" +
+	"\x13sender_display_name\x18\x11 \x01(\tR\x11senderDisplayName\x12,\n" +
+	"\acontact\x18\x12 \x01(\v2\x12.chat.ContactEventR\acontact\x12\x17\n" +
+	"\asent_at\x18\x13 \x01(\x03R\x06sentAt\"\xa7\x01\n" +
+	"\vMessageType\x12\b\n" +
+	"\x04CHAT\x10\x00\x12\x13\n" +
+	"\x0fHISTORY_REQUEST\x10\x01\x12\x0f\n" +
+	"\vHISTORY_GAP\x10\x02\x12\b\n" +
+	"\x04EDIT\x10\x03\x12\n" +
+	"\n" +
+	"\x06DELETE\x10\x04\x12\f\n" +
+	"\bREACTION\x10\x05\x12\x11\n" +
+	"\rTHREAD_UPDATE\x10\x06\x12\v\n" +
+	"\aMENTION\x10\a\x12\v\n" +
+	"\aRECEIPT\x10\b\x12\n" +
+	"\n" +
+	"\x06TYPING\x10\t\x12\v\n" +
+	"\aCONTACT\x10\n" +
+	"\"L\n" +
+	"\bReaction\x12\x14\n" +
+	"\x05emoji\x18\x01 \x01(\tR\x05emoji\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x14\n" +
+	"\x05users\x18\x03 \x03(\tR\x05users\"\xcc\x01\n" +
 	"\x12ActiveUsersRequest\x12\x1a\n" +
-	"\busername\x18\x01 \x01(\tR\busername\"\xe0\x02\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x124\n" +
+	"\x05scope\x18\x03 \x01(\x0e2\x1e.chat.ActiveUsersRequest.ScopeR\x05scope\x12\x1c\n" +
+	"\tusernames\x18\x04 \x03(\tR\tusernames\"2\n" +
+	"\x05Scope\x12\a\n" +
+	"\x03ALL\x10\x00\x12\b\n" +
+	"\x04ROOM\x10\x01\x12\f\n" +
+	"\bCONTACTS\x10\x02\x12\b\n" +
+	"\x04LIST\x10\x03\"\xcc\b\n" +
 	"\x11ActiveUsersUpdate\x12C\n" +
 	"\vupdate_type\x18\x01 \x01(\x0e2\".chat.ActiveUsersUpdate.UpdateTypeR\n" +
 	"updateType\x12\x1a\n" +
 	"\busername\x18\x02 \x01(\tR\busername\x12\x14\n" +
 	"\x05users\x18\x03 \x03(\tR\x05users\x12N\n" +
-	"\ruser_statuses\x18\x04 \x03(\v2).chat.ActiveUsersUpdate.UserStatusesEntryR\fuserStatuses\x1a?\n" +
+	"\ruser_statuses\x18\x04 \x03(\v2).chat.ActiveUsersUpdate.UserStatusesEntryR\fuserStatuses\x12\x12\n" +
+	"\x04room\x18\x05 \x01(\tR\x04room\x12T\n" +
+	"\x0fcustom_statuses\x18\x06 \x03(\v2+.chat.ActiveUsersUpdate.CustomStatusesEntryR\x0ecustomStatuses\x12A\n" +
+	"\bsessions\x18\a \x03(\v2%.chat.ActiveUsersUpdate.SessionsEntryR\bsessions\x12\x18\n" +
+	"\aversion\x18\b \x01(\x03R\aversion\x12)\n" +
+	"\x10previous_version\x18\t \x01(\x03R\x0fpreviousVersion\x12N\n" +
+	"\rdisplay_names\x18\n" +
+	" \x03(\v2).chat.ActiveUsersUpdate.DisplayNamesEntryR\fdisplayNames\x12T\n" +
+	"\x0favatar_versions\x18\v \x03(\v2+.chat.ActiveUsersUpdate.AvatarVersionsEntryR\x0eavatarVersions\x1a?\n" +
 	"\x11UserStatusesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"C\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aU\n" +
+	"\x13CustomStatusesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.chat.CustomStatusR\x05value:\x028\x01\x1aO\n" +
+	"\rSessionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.chat.UserSessionsR\x05value:\x028\x01\x1a?\n" +
+	"\x11DisplayNamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aA\n" +
+	"\x13AvatarVersionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"k\n" +
 	"\n" +
 	"UpdateType\x12\r\n" +
 	"\tFULL_LIST\x10\x00\x12\b\n" +
 	"\x04JOIN\x10\x01\x12\t\n" +
 	"\x05LEAVE\x10\x02\x12\x11\n" +
-	"\rSTATUS_CHANGE\x10\x03\"`\n" +
+	"\rSTATUS_CHANGE\x10\x03\x12\x12\n" +
+	"\x0eSESSION_CHANGE\x10\x04\x12\x12\n" +
+	"\x0ePROFILE_CHANGE\x10\x05\">\n" +
+	"\fUserSessions\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x05R\x05count\x12\x18\n" +
+	"\adevices\x18\x02 \x03(\tR\adevices\"\x8c\x01\n" +
 	"\fStatusUpdate\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1c\n" +
-	"\ttimestamp\x18\x03 \x01(\tR\ttimestamp\"D\n" +
+	"\ttimestamp\x18\x03 \x01(\tR\ttimestamp\x12*\n" +
+	"\x06custom\x18\x04 \x01(\v2\x12.chat.CustomStatusR\x06custom\"W\n" +
+	"\fCustomStatus\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x14\n" +
+	"\x05emoji\x18\x02 \x01(\tR\x05emoji\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\x03R\texpiresAt\"D\n" +
 	"\x0eStatusResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2\xfd\x01\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xae\x01\n" +
+	"\bRoomInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"created_by\x18\x03 \x01(\tR\tcreatedBy\x12\x18\n" +
+	"\amembers\x18\x04 \x03(\tR\amembers\x123\n" +
+	"\tretention\x18\x05 \x01(\v2\x15.chat.RetentionPolicyR\tretention\"\x7f\n" +
+	"\x0fRetentionPolicy\x12&\n" +
+	"\x0fmax_age_seconds\x18\x01 \x01(\x03R\rmaxAgeSeconds\x12!\n" +
+	"\fmax_messages\x18\x02 \x01(\x05R\vmaxMessages\x12!\n" +
+	"\fkeep_forever\x18\x03 \x01(\bR\vkeepForever\"~\n" +
+	"\x17SetRoomRetentionRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x123\n" +
+	"\tretention\x18\x03 \x01(\v2\x15.chat.RetentionPolicyR\tretention\"e\n" +
+	"\x11CreateRoomRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\"=\n" +
+	"\vRoomRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\"f\n" +
+	"\fRoomResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\"\n" +
+	"\x04room\x18\x03 \x01(\v2\x0e.chat.RoomInfoR\x04room\".\n" +
+	"\x10ListRoomsRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\"9\n" +
+	"\x11ListRoomsResponse\x12$\n" +
+	"\x05rooms\x18\x01 \x03(\v2\x0e.chat.RoomInfoR\x05rooms\"\xa9\x01\n" +
+	"\x11GetHistoryRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12'\n" +
+	"\x0fbefore_sequence\x18\x03 \x01(\x03R\x0ebeforeSequence\x12%\n" +
+	"\x0eafter_sequence\x18\x04 \x01(\x03R\rafterSequence\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"^\n" +
+	"\x12GetHistoryResponse\x12-\n" +
+	"\bmessages\x18\x01 \x03(\v2\x11.chat.ChatMessageR\bmessages\x12\x19\n" +
+	"\bhas_more\x18\x02 \x01(\bR\ahasMore\"\xd6\x01\n" +
+	"\x15SearchMessagesRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x14\n" +
+	"\x05query\x18\x02 \x01(\tR\x05query\x12\x16\n" +
+	"\x06sender\x18\x03 \x01(\tR\x06sender\x12\x12\n" +
+	"\x04room\x18\x04 \x01(\tR\x04room\x12\x14\n" +
+	"\x05since\x18\x05 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05until\x18\x06 \x01(\x03R\x05until\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\b \x01(\tR\tpageToken\"o\n" +
+	"\x16SearchMessagesResponse\x12-\n" +
+	"\bmessages\x18\x01 \x03(\v2\x11.chat.ChatMessageR\bmessages\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"i\n" +
+	"\x12EditMessageRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"Q\n" +
+	"\x14DeleteMessageRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\"\x81\x01\n" +
+	"\x15MessageActionResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x124\n" +
+	"\fchat_message\x18\x03 \x01(\v2\x11.chat.ChatMessageR\vchatMessage\"b\n" +
+	"\x0fReactionRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\x12\x14\n" +
+	"\x05emoji\x18\x03 \x01(\tR\x05emoji\"M\n" +
+	"\x10GetThreadRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\"g\n" +
+	"\x11GetThreadResponse\x12%\n" +
+	"\x04root\x18\x01 \x01(\v2\x11.chat.ChatMessageR\x04root\x12+\n" +
+	"\areplies\x18\x02 \x03(\v2\x11.chat.ChatMessageR\areplies\"\xc9\x01\n" +
+	"\n" +
+	"AckRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x03 \x01(\tR\tmessageId\x12\x1a\n" +
+	"\bsequence\x18\x04 \x01(\x03R\bsequence\x12,\n" +
+	"\x04type\x18\x05 \x01(\x0e2\x18.chat.AckRequest.AckTypeR\x04type\"\"\n" +
+	"\aAckType\x12\r\n" +
+	"\tDELIVERED\x10\x00\x12\b\n" +
+	"\x04READ\x10\x01\"\x95\x01\n" +
+	"\vAckResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12-\n" +
+	"\x12delivered_sequence\x18\x03 \x01(\x03R\x11deliveredSequence\x12#\n" +
+	"\rread_sequence\x18\x04 \x01(\x03R\freadSequence\"\x9b\x01\n" +
+	"\x0eMessageReceipt\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12\x1a\n" +
+	"\bsequence\x18\x03 \x01(\x03R\bsequence\x12!\n" +
+	"\fdelivered_to\x18\x04 \x03(\tR\vdeliveredTo\x12\x17\n" +
+	"\aread_by\x18\x05 \x03(\tR\x06readBy\"Q\n" +
+	"\x12GetReceiptsRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1f\n" +
+	"\vmessage_ids\x18\x02 \x03(\tR\n" +
+	"messageIds\"G\n" +
+	"\x13GetReceiptsResponse\x120\n" +
+	"\breceipts\x18\x01 \x03(\v2\x14.chat.MessageReceiptR\breceipts\"K\n" +
+	"\x15GetUserProfileRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\"\x9a\x02\n" +
+	"\vUserProfile\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x16\n" +
+	"\x06online\x18\x02 \x01(\bR\x06online\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x127\n" +
+	"\rcustom_status\x18\x04 \x01(\v2\x12.chat.CustomStatusR\fcustomStatus\x12\x1b\n" +
+	"\tjoined_at\x18\x05 \x01(\x03R\bjoinedAt\x12\x1b\n" +
+	"\tlast_seen\x18\x06 \x01(\x03R\blastSeen\x12#\n" +
+	"\rmessage_count\x18\a \x01(\x03R\fmessageCount\x12'\n" +
+	"\aprofile\x18\b \x01(\v2\r.chat.ProfileR\aprofile\"/\n" +
+	"\x11GetProfileRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\"\xa7\x01\n" +
+	"\aProfile\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12!\n" +
+	"\fdisplay_name\x18\x02 \x01(\tR\vdisplayName\x12\x10\n" +
+	"\x03bio\x18\x03 \x01(\tR\x03bio\x12\x1f\n" +
+	"\vavatar_type\x18\x04 \x01(\tR\n" +
+	"avatarType\x12*\n" +
+	"\x11avatar_updated_at\x18\x05 \x01(\x03R\x0favatarUpdatedAt\"\x8a\x01\n" +
+	"\x14UpdateProfileRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12&\n" +
+	"\fdisplay_name\x18\x02 \x01(\tH\x00R\vdisplayName\x88\x01\x01\x12\x15\n" +
+	"\x03bio\x18\x03 \x01(\tH\x01R\x03bio\x88\x01\x01B\x0f\n" +
+	"\r_display_nameB\x06\n" +
+	"\x04_bio\"`\n" +
+	"\vAvatarChunk\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"J\n" +
+	"\x14ContactActionRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\"g\n" +
+	"\x15ContactActionResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1a\n" +
+	"\bcontacts\x18\x03 \x01(\bR\bcontacts\"1\n" +
+	"\x13ListContactsRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\"v\n" +
+	"\aContact\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12!\n" +
+	"\fdisplay_name\x18\x02 \x01(\tR\vdisplayName\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x03R\x05since\x12\x16\n" +
+	"\x06online\x18\x04 \x01(\bR\x06online\"o\n" +
+	"\x15PendingContactRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12!\n" +
+	"\fdisplay_name\x18\x02 \x01(\tR\vdisplayName\x12\x17\n" +
+	"\asent_at\x18\x03 \x01(\x03R\x06sentAt\"\xe0\x01\n" +
+	"\x14ListContactsResponse\x12)\n" +
+	"\bcontacts\x18\x01 \x03(\v2\r.chat.ContactR\bcontacts\x127\n" +
+	"\bincoming\x18\x02 \x03(\v2\x1b.chat.PendingContactRequestR\bincoming\x127\n" +
+	"\boutgoing\x18\x03 \x03(\v2\x1b.chat.PendingContactRequestR\boutgoing\x12+\n" +
+	"\ablocked\x18\x04 \x03(\v2\x11.chat.BlockedUserR\ablocked\"b\n" +
+	"\vBlockedUser\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12!\n" +
+	"\fdisplay_name\x18\x02 \x01(\tR\vdisplayName\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x03R\x05since\"\x9f\x01\n" +
+	"\fContactEvent\x12+\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x17.chat.ContactEvent.KindR\x04kind\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\">\n" +
+	"\x04Kind\x12\r\n" +
+	"\tREQUESTED\x10\x00\x12\f\n" +
+	"\bACCEPTED\x10\x01\x12\f\n" +
+	"\bDECLINED\x10\x02\x12\v\n" +
+	"\aREMOVED\x10\x032\xfa\x0f\n" +
 	"\vChatService\x120\n" +
 	"\x05Login\x12\x12.chat.LoginRequest\x1a\x13.chat.LoginResponse\x126\n" +
 	"\n" +
 	"ChatStream\x12\x11.chat.ChatMessage\x1a\x11.chat.ChatMessage(\x010\x01\x12H\n" +
-	"\x11ActiveUsersStream\x12\x18.chat.ActiveUsersRequest\x1a\x17.chat.ActiveUsersUpdate0\x01\x12:\n" +
-	"\fUpdateStatus\x12\x12.chat.StatusUpdate\x1a\x14.chat.StatusResponse(\x01B\x03Z\x01.b\x06proto3"
+	"\x11ActiveUsersStream\x12\x18.chat.ActiveUsersRequest\x1a\x17.chat.ActiveUsersUpdate0\x01\x12C\n" +
+	"\x0eGetActiveUsers\x12\x18.chat.ActiveUsersRequest\x1a\x17.chat.ActiveUsersUpdate\x12:\n" +
+	"\fUpdateStatus\x12\x12.chat.StatusUpdate\x1a\x14.chat.StatusResponse(\x01\x129\n" +
+	"\n" +
+	"CreateRoom\x12\x17.chat.CreateRoomRequest\x1a\x12.chat.RoomResponse\x121\n" +
+	"\bJoinRoom\x12\x11.chat.RoomRequest\x1a\x12.chat.RoomResponse\x122\n" +
+	"\tLeaveRoom\x12\x11.chat.RoomRequest\x1a\x12.chat.RoomResponse\x12<\n" +
+	"\tListRooms\x12\x16.chat.ListRoomsRequest\x1a\x17.chat.ListRoomsResponse\x12E\n" +
+	"\x10SetRoomRetention\x12\x1d.chat.SetRoomRetentionRequest\x1a\x12.chat.RoomResponse\x12?\n" +
+	"\n" +
+	"GetHistory\x12\x17.chat.GetHistoryRequest\x1a\x18.chat.GetHistoryResponse\x12K\n" +
+	"\x0eSearchMessages\x12\x1b.chat.SearchMessagesRequest\x1a\x1c.chat.SearchMessagesResponse\x12D\n" +
+	"\vEditMessage\x12\x18.chat.EditMessageRequest\x1a\x1b.chat.MessageActionResponse\x12H\n" +
+	"\rDeleteMessage\x12\x1a.chat.DeleteMessageRequest\x1a\x1b.chat.MessageActionResponse\x12A\n" +
+	"\vAddReaction\x12\x15.chat.ReactionRequest\x1a\x1b.chat.MessageActionResponse\x12D\n" +
+	"\x0eRemoveReaction\x12\x15.chat.ReactionRequest\x1a\x1b.chat.MessageActionResponse\x12<\n" +
+	"\tGetThread\x12\x16.chat.GetThreadRequest\x1a\x17.chat.GetThreadResponse\x122\n" +
+	"\vAckMessages\x12\x10.chat.AckRequest\x1a\x11.chat.AckResponse\x12B\n" +
+	"\vGetReceipts\x12\x18.chat.GetReceiptsRequest\x1a\x19.chat.GetReceiptsResponse\x12@\n" +
+	"\x0eGetUserProfile\x12\x1b.chat.GetUserProfileRequest\x1a\x11.chat.UserProfile\x124\n" +
+	"\n" +
+	"GetProfile\x12\x17.chat.GetProfileRequest\x1a\r.chat.Profile\x12:\n" +
+	"\rUpdateProfile\x12\x1a.chat.UpdateProfileRequest\x1a\r.chat.Profile\x122\n" +
+	"\fUploadAvatar\x12\x11.chat.AvatarChunk\x1a\r.chat.Profile(\x01\x129\n" +
+	"\tGetAvatar\x12\x17.chat.GetProfileRequest\x1a\x11.chat.AvatarChunk0\x01\x12M\n" +
+	"\x12SendContactRequest\x12\x1a.chat.ContactActionRequest\x1a\x1b.chat.ContactActionResponse\x12O\n" +
+	"\x14AcceptContactRequest\x12\x1a.chat.ContactActionRequest\x1a\x1b.chat.ContactActionResponse\x12P\n" +
+	"\x15DeclineContactRequest\x12\x1a.chat.ContactActionRequest\x1a\x1b.chat.ContactActionResponse\x12H\n" +
+	"\rRemoveContact\x12\x1a.chat.ContactActionRequest\x1a\x1b.chat.ContactActionResponse\x12E\n" +
+	"\fListContacts\x12\x19.chat.ListContactsRequest\x1a\x1a.chat.ListContactsResponse\x12D\n" +
+	"\tBlockUser\x12\x1a.chat.ContactActionRequest\x1a\x1b.chat.ContactActionResponse\x12F\n" +
+	"\vUnblockUser\x12\x1a.chat.ContactActionRequest\x1a\x1b.chat.ContactActionResponseB\x03Z\x01.b\x06proto3"
 
 var (
 	file_proto_chat_proto_rawDescOnce sync.Once
@@ -512,35 +3672,167 @@ func file_proto_chat_proto_rawDescGZIP() []byte {
 	return file_proto_chat_proto_rawDescData
 }
 
-var file_proto_chat_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_chat_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_proto_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 52)
 var file_proto_chat_proto_goTypes = []any{
-	(ActiveUsersUpdate_UpdateType)(0), // 0: chat.ActiveUsersUpdate.UpdateType
-	(*LoginRequest)(nil),              // 1: chat.LoginRequest
-	(*LoginResponse)(nil),             // 2: chat.LoginResponse
-	(*ChatMessage)(nil),               // 3: chat.ChatMessage
-	(*ActiveUsersRequest)(nil),        // 4: chat.ActiveUsersRequest
-	(*ActiveUsersUpdate)(nil),         // 5: chat.ActiveUsersUpdate
-	(*StatusUpdate)(nil),              // 6: chat.StatusUpdate
-	(*StatusResponse)(nil),            // 7: chat.StatusResponse
-	nil,                               // 8: chat.ActiveUsersUpdate.UserStatusesEntry
+	(ChatMessage_MessageType)(0),      // 0: chat.ChatMessage.MessageType
+	(ActiveUsersRequest_Scope)(0),     // 1: chat.ActiveUsersRequest.Scope
+	(ActiveUsersUpdate_UpdateType)(0), // 2: chat.ActiveUsersUpdate.UpdateType
+	(AckRequest_AckType)(0),           // 3: chat.AckRequest.AckType
+	(ContactEvent_Kind)(0),            // 4: chat.ContactEvent.Kind
+	(*LoginRequest)(nil),              // 5: chat.LoginRequest
+	(*LoginResponse)(nil),             // 6: chat.LoginResponse
+	(*ChatMessage)(nil),               // 7: chat.ChatMessage
+	(*Reaction)(nil),                  // 8: chat.Reaction
+	(*ActiveUsersRequest)(nil),        // 9: chat.ActiveUsersRequest
+	(*ActiveUsersUpdate)(nil),         // 10: chat.ActiveUsersUpdate
+	(*UserSessions)(nil),              // 11: chat.UserSessions
+	(*StatusUpdate)(nil),              // 12: chat.StatusUpdate
+	(*CustomStatus)(nil),              // 13: chat.CustomStatus
+	(*StatusResponse)(nil),            // 14: chat.StatusResponse
+	(*RoomInfo)(nil),                  // 15: chat.RoomInfo
+	(*RetentionPolicy)(nil),           // 16: chat.RetentionPolicy
+	(*SetRoomRetentionRequest)(nil),   // 17: chat.SetRoomRetentionRequest
+	(*CreateRoomRequest)(nil),         // 18: chat.CreateRoomRequest
+	(*RoomRequest)(nil),               // 19: chat.RoomRequest
+	(*RoomResponse)(nil),              // 20: chat.RoomResponse
+	(*ListRoomsRequest)(nil),          // 21: chat.ListRoomsRequest
+	(*ListRoomsResponse)(nil),         // 22: chat.ListRoomsResponse
+	(*GetHistoryRequest)(nil),         // 23: chat.GetHistoryRequest
+	(*GetHistoryResponse)(nil),        // 24: chat.GetHistoryResponse
+	(*SearchMessagesRequest)(nil),     // 25: chat.SearchMessagesRequest
+	(*SearchMessagesResponse)(nil),    // 26: chat.SearchMessagesResponse
+	(*EditMessageRequest)(nil),        // 27: chat.EditMessageRequest
+	(*DeleteMessageRequest)(nil),      // 28: chat.DeleteMessageRequest
+	(*MessageActionResponse)(nil),     // 29: chat.MessageActionResponse
+	(*ReactionRequest)(nil),           // 30: chat.ReactionRequest
+	(*GetThreadRequest)(nil),          // 31: chat.GetThreadRequest
+	(*GetThreadResponse)(nil),         // 32: chat.GetThreadResponse
+	(*AckRequest)(nil),                // 33: chat.AckRequest
+	(*AckResponse)(nil),               // 34: chat.AckResponse
+	(*MessageReceipt)(nil),            // 35: chat.MessageReceipt
+	(*GetReceiptsRequest)(nil),        // 36: chat.GetReceiptsRequest
+	(*GetReceiptsResponse)(nil),       // 37: chat.GetReceiptsResponse
+	(*GetUserProfileRequest)(nil),     // 38: chat.GetUserProfileRequest
+	(*UserProfile)(nil),               // 39: chat.UserProfile
+	(*GetProfileRequest)(nil),         // 40: chat.GetProfileRequest
+	(*Profile)(nil),                   // 41: chat.Profile
+	(*UpdateProfileRequest)(nil),      // 42: chat.UpdateProfileRequest
+	(*AvatarChunk)(nil),               // 43: chat.AvatarChunk
+	(*ContactActionRequest)(nil),      // 44: chat.ContactActionRequest
+	(*ContactActionResponse)(nil),     // 45: chat.ContactActionResponse
+	(*ListContactsRequest)(nil),       // 46: chat.ListContactsRequest
+	(*Contact)(nil),                   // 47: chat.Contact
+	(*PendingContactRequest)(nil),     // 48: chat.PendingContactRequest
+	(*ListContactsResponse)(nil),      // 49: chat.ListContactsResponse
+	(*BlockedUser)(nil),               // 50: chat.BlockedUser
+	(*ContactEvent)(nil),              // 51: chat.ContactEvent
+	nil,                               // 52: chat.ActiveUsersUpdate.UserStatusesEntry
+	nil,                               // 53: chat.ActiveUsersUpdate.CustomStatusesEntry
+	nil,                               // 54: chat.ActiveUsersUpdate.SessionsEntry
+	nil,                               // 55: chat.ActiveUsersUpdate.DisplayNamesEntry
+	nil,                               // 56: chat.ActiveUsersUpdate.AvatarVersionsEntry
 }
 var file_proto_chat_proto_depIdxs = []int32{
-	0, // 0: chat.ActiveUsersUpdate.update_type:type_name -> chat.ActiveUsersUpdate.UpdateType
-	8, // 1: chat.ActiveUsersUpdate.user_statuses:type_name -> chat.ActiveUsersUpdate.UserStatusesEntry
-	1, // 2: chat.ChatService.Login:input_type -> chat.LoginRequest
-	3, // 3: chat.ChatService.ChatStream:input_type -> chat.ChatMessage
-	4, // 4: chat.ChatService.ActiveUsersStream:input_type -> chat.ActiveUsersRequest
-	6, // 5: chat.ChatService.UpdateStatus:input_type -> chat.StatusUpdate
-	2, // 6: chat.ChatService.Login:output_type -> chat.LoginResponse
-	3, // 7: chat.ChatService.ChatStream:output_type -> chat.ChatMessage
-	5, // 8: chat.ChatService.ActiveUsersStream:output_type -> chat.ActiveUsersUpdate
-	7, // 9: chat.ChatService.UpdateStatus:output_type -> chat.StatusResponse
-	6, // [6:10] is the sub-list for method output_type
-	2, // [2:6] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: chat.ChatMessage.type:type_name -> chat.ChatMessage.MessageType
+	8,  // 1: chat.ChatMessage.reactions:type_name -> chat.Reaction
+	35, // 2: chat.ChatMessage.receipt:type_name -> chat.MessageReceipt
+	51, // 3: chat.ChatMessage.contact:type_name -> chat.ContactEvent
+	1,  // 4: chat.ActiveUsersRequest.scope:type_name -> chat.ActiveUsersRequest.Scope
+	2,  // 5: chat.ActiveUsersUpdate.update_type:type_name -> chat.ActiveUsersUpdate.UpdateType
+	52, // 6: chat.ActiveUsersUpdate.user_statuses:type_name -> chat.ActiveUsersUpdate.UserStatusesEntry
+	53, // 7: chat.ActiveUsersUpdate.custom_statuses:type_name -> chat.ActiveUsersUpdate.CustomStatusesEntry
+	54, // 8: chat.ActiveUsersUpdate.sessions:type_name -> chat.ActiveUsersUpdate.SessionsEntry
+	55, // 9: chat.ActiveUsersUpdate.display_names:type_name -> chat.ActiveUsersUpdate.DisplayNamesEntry
+	56, // 10: chat.ActiveUsersUpdate.avatar_versions:type_name -> chat.ActiveUsersUpdate.AvatarVersionsEntry
+	13, // 11: chat.StatusUpdate.custom:type_name -> chat.CustomStatus
+	16, // 12: chat.RoomInfo.retention:type_name -> chat.RetentionPolicy
+	16, // 13: chat.SetRoomRetentionRequest.retention:type_name -> chat.RetentionPolicy
+	15, // 14: chat.RoomResponse.room:type_name -> chat.RoomInfo
+	15, // 15: chat.ListRoomsResponse.rooms:type_name -> chat.RoomInfo
+	7,  // 16: chat.GetHistoryResponse.messages:type_name -> chat.ChatMessage
+	7,  // 17: chat.SearchMessagesResponse.messages:type_name -> chat.ChatMessage
+	7,  // 18: chat.MessageActionResponse.chat_message:type_name -> chat.ChatMessage
+	7,  // 19: chat.GetThreadResponse.root:type_name -> chat.ChatMessage
+	7,  // 20: chat.GetThreadResponse.replies:type_name -> chat.ChatMessage
+	3,  // 21: chat.AckRequest.type:type_name -> chat.AckRequest.AckType
+	35, // 22: chat.GetReceiptsResponse.receipts:type_name -> chat.MessageReceipt
+	13, // 23: chat.UserProfile.custom_status:type_name -> chat.CustomStatus
+	41, // 24: chat.UserProfile.profile:type_name -> chat.Profile
+	47, // 25: chat.ListContactsResponse.contacts:type_name -> chat.Contact
+	48, // 26: chat.ListContactsResponse.incoming:type_name -> chat.PendingContactRequest
+	48, // 27: chat.ListContactsResponse.outgoing:type_name -> chat.PendingContactRequest
+	50, // 28: chat.ListContactsResponse.blocked:type_name -> chat.BlockedUser
+	4,  // 29: chat.ContactEvent.kind:type_name -> chat.ContactEvent.Kind
+	13, // 30: chat.ActiveUsersUpdate.CustomStatusesEntry.value:type_name -> chat.CustomStatus
+	11, // 31: chat.ActiveUsersUpdate.SessionsEntry.value:type_name -> chat.UserSessions
+	5,  // 32: chat.ChatService.Login:input_type -> chat.LoginRequest
+	7,  // 33: chat.ChatService.ChatStream:input_type -> chat.ChatMessage
+	9,  // 34: chat.ChatService.ActiveUsersStream:input_type -> chat.ActiveUsersRequest
+	9,  // 35: chat.ChatService.GetActiveUsers:input_type -> chat.ActiveUsersRequest
+	12, // 36: chat.ChatService.UpdateStatus:input_type -> chat.StatusUpdate
+	18, // 37: chat.ChatService.CreateRoom:input_type -> chat.CreateRoomRequest
+	19, // 38: chat.ChatService.JoinRoom:input_type -> chat.RoomRequest
+	19, // 39: chat.ChatService.LeaveRoom:input_type -> chat.RoomRequest
+	21, // 40: chat.ChatService.ListRooms:input_type -> chat.ListRoomsRequest
+	17, // 41: chat.ChatService.SetRoomRetention:input_type -> chat.SetRoomRetentionRequest
+	23, // 42: chat.ChatService.GetHistory:input_type -> chat.GetHistoryRequest
+	25, // 43: chat.ChatService.SearchMessages:input_type -> chat.SearchMessagesRequest
+	27, // 44: chat.ChatService.EditMessage:input_type -> chat.EditMessageRequest
+	28, // 45: chat.ChatService.DeleteMessage:input_type -> chat.DeleteMessageRequest
+	30, // 46: chat.ChatService.AddReaction:input_type -> chat.ReactionRequest
+	30, // 47: chat.ChatService.RemoveReaction:input_type -> chat.ReactionRequest
+	31, // 48: chat.ChatService.GetThread:input_type -> chat.GetThreadRequest
+	33, // 49: chat.ChatService.AckMessages:input_type -> chat.AckRequest
+	36, // 50: chat.ChatService.GetReceipts:input_type -> chat.GetReceiptsRequest
+	38, // 51: chat.ChatService.GetUserProfile:input_type -> chat.GetUserProfileRequest
+	40, // 52: chat.ChatService.GetProfile:input_type -> chat.GetProfileRequest
+	42, // 53: chat.ChatService.UpdateProfile:input_type -> chat.UpdateProfileRequest
+	43, // 54: chat.ChatService.UploadAvatar:input_type -> chat.AvatarChunk
+	40, // 55: chat.ChatService.GetAvatar:input_type -> chat.GetProfileRequest
+	44, // 56: chat.ChatService.SendContactRequest:input_type -> chat.ContactActionRequest
+	44, // 57: chat.ChatService.AcceptContactRequest:input_type -> chat.ContactActionRequest
+	44, // 58: chat.ChatService.DeclineContactRequest:input_type -> chat.ContactActionRequest
+	44, // 59: chat.ChatService.RemoveContact:input_type -> chat.ContactActionRequest
+	46, // 60: chat.ChatService.ListContacts:input_type -> chat.ListContactsRequest
+	44, // 61: chat.ChatService.BlockUser:input_type -> chat.ContactActionRequest
+	44, // 62: chat.ChatService.UnblockUser:input_type -> chat.ContactActionRequest
+	6,  // 63: chat.ChatService.Login:output_type -> chat.LoginResponse
+	7,  // 64: chat.ChatService.ChatStream:output_type -> chat.ChatMessage
+	10, // 65: chat.ChatService.ActiveUsersStream:output_type -> chat.ActiveUsersUpdate
+	10, // 66: chat.ChatService.GetActiveUsers:output_type -> chat.ActiveUsersUpdate
+	14, // 67: chat.ChatService.UpdateStatus:output_type -> chat.StatusResponse
+	20, // 68: chat.ChatService.CreateRoom:output_type -> chat.RoomResponse
+	20, // 69: chat.ChatService.JoinRoom:output_type -> chat.RoomResponse
+	20, // 70: chat.ChatService.LeaveRoom:output_type -> chat.RoomResponse
+	22, // 71: chat.ChatService.ListRooms:output_type -> chat.ListRoomsResponse
+	20, // 72: chat.ChatService.SetRoomRetention:output_type -> chat.RoomResponse
+	24, // 73: chat.ChatService.GetHistory:output_type -> chat.GetHistoryResponse
+	26, // 74: chat.ChatService.SearchMessages:output_type -> chat.SearchMessagesResponse
+	29, // 75: chat.ChatService.EditMessage:output_type -> chat.MessageActionResponse
+	29, // 76: chat.ChatService.DeleteMessage:output_type -> chat.MessageActionResponse
+	29, // 77: chat.ChatService.AddReaction:output_type -> chat.MessageActionResponse
+	29, // 78: chat.ChatService.RemoveReaction:output_type -> chat.MessageActionResponse
+	32, // 79: chat.ChatService.GetThread:output_type -> chat.GetThreadResponse
+	34, // 80: chat.ChatService.AckMessages:output_type -> chat.AckResponse
+	37, // 81: chat.ChatService.GetReceipts:output_type -> chat.GetReceiptsResponse
+	39, // 82: chat.ChatService.GetUserProfile:output_type -> chat.UserProfile
+	41, // 83: chat.ChatService.GetProfile:output_type -> chat.Profile
+	41, // 84: chat.ChatService.UpdateProfile:output_type -> chat.Profile
+	41, // 85: chat.ChatService.UploadAvatar:output_type -> chat.Profile
+	43, // 86: chat.ChatService.GetAvatar:output_type -> chat.AvatarChunk
+	45, // 87: chat.ChatService.SendContactRequest:output_type -> chat.ContactActionResponse
+	45, // 88: chat.ChatService.AcceptContactRequest:output_type -> chat.ContactActionResponse
+	45, // 89: chat.ChatService.DeclineContactRequest:output_type -> chat.ContactActionResponse
+	45, // 90: chat.ChatService.RemoveContact:output_type -> chat.ContactActionResponse
+	49, // 91: chat.ChatService.ListContacts:output_type -> chat.ListContactsResponse
+	45, // 92: chat.ChatService.BlockUser:output_type -> chat.ContactActionResponse
+	45, // 93: chat.ChatService.UnblockUser:output_type -> chat.ContactActionResponse
+	63, // [63:94] is the sub-list for method output_type
+	32, // [32:63] is the sub-list for method input_type
+	32, // [32:32] is the sub-list for extension type_name
+	32, // [32:32] is the sub-list for extension extendee
+	0,  // [0:32] is the sub-list for field type_name
 }
 
 func init() { file_proto_chat_proto_init() }
@@ -548,13 +3840,14 @@ func file_proto_chat_proto_init() {
 	if File_proto_chat_proto != nil {
 		return
 	}
+	file_proto_chat_proto_msgTypes[37].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_chat_proto_rawDesc), len(file_proto_chat_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   8,
+			NumEnums:      5,
+			NumMessages:   52,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Check whether blocker blocked a user
+func (s *server) hasBlocked(blocker, username string) bool {
+	s.contactsMutex.RLock()
+	defer s.contactsMutex.RUnlock()
+
+	_, ok := s.contacts.Blocked[blocker][username]
+	return ok
+}
+
+// Check whether either of two users blocked the other
+func (s *server) blockedBetween(a, b string) bool {
+	s.contactsMutex.RLock()
+	defer s.contactsMutex.RUnlock()
+
+	_, ab := s.contacts.Blocked[a][b]
+	_, ba := s.contacts.Blocked[b][a]
+	return ab || ba
+}
+
+// Drop the users who blocked the sender from a list of recipients
+func (s *server) withoutBlockers(users []string, sender string) []string {
+	s.contactsMutex.RLock()
+	defer s.contactsMutex.RUnlock()
+
+	kept := make([]string, 0, len(users))
+	for _, user := range users {
+		if _, ok := s.contacts.Blocked[user][sender]; !ok {
+			kept = append(kept, user)
+		}
+	}
+	return kept
+}
+
+// Drop the messages of users the reader blocked
+func (s *server) visibleMessages(reader string, messages []*pb.ChatMessage) []*pb.ChatMessage {
+	s.contactsMutex.RLock()
+	defer s.contactsMutex.RUnlock()
+
+	blocked := s.contacts.Blocked[reader]
+	if len(blocked) == 0 {
+		return messages
+	}
+	kept := make([]*pb.ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if _, ok := blocked[msg.Sender]; !ok {
+			kept = append(kept, msg)
+		}
+	}
+	return kept
+}
+
+// BlockUser blocks the target, ending any contact or open request between the two users
+func (s *server) BlockUser(ctx context.Context, req *pb.ContactActionRequest) (*pb.ContactActionResponse, error) {
+	if req.Username != "" && req.Username == req.Target {
+		return nil, status.Error(codes.InvalidArgument, "you can't block yourself")
+	}
+	if err := s.validateContactAction(req); err != nil {
+		return nil, err
+	}
+
+	s.contactsMutex.Lock()
+	if _, ok := s.contacts.Blocked[req.Username][req.Target]; ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.AlreadyExists, "%s is already blocked", req.Target)
+	}
+	_, wasContact := s.contacts.Contacts[req.Username][req.Target]
+	_, asked := s.contacts.Requests[req.Target][req.Username]
+	_, askedBy := s.contacts.Requests[req.Username][req.Target]
+
+	setNested(s.contacts.Blocked, req.Username, req.Target, time.Now().Unix())
+	deleteNested(s.contacts.Contacts, req.Username, req.Target)
+	deleteNested(s.contacts.Contacts, req.Target, req.Username)
+	deleteNested(s.contacts.Requests, req.Target, req.Username)
+	deleteNested(s.contacts.Requests, req.Username, req.Target)
+	err := s.commitContacts()
+	s.contactsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s blocked %s", req.Username, req.Target)
+	if wasContact {
+		s.sendContactEvent(pb.ContactEvent_REMOVED, req.Username, req.Target)
+	}
+	if asked {
+		s.sendContactEvent(pb.ContactEvent_DECLINED, req.Username, req.Target)
+	}
+	if askedBy {
+		s.sendContactEvent(pb.ContactEvent_DECLINED, req.Target, req.Username)
+	}
+
+	s.refreshPresenceBetween(req.Username, req.Target, nil)
+
+	return &pb.ContactActionResponse{
+		Success: true,
+		Message: "User blocked",
+	}, nil
+}
+
+// UnblockUser lifts a block, the users have to send a new request to become contacts again
+func (s *server) UnblockUser(ctx context.Context, req *pb.ContactActionRequest) (*pb.ContactActionResponse, error) {
+	if req.Username != "" && req.Username == req.Target {
+		return nil, status.Error(codes.InvalidArgument, "you can't unblock yourself")
+	}
+	if err := s.validateContactAction(req); err != nil {
+		return nil, err
+	}
+
+	s.contactsMutex.Lock()
+	if _, ok := s.contacts.Blocked[req.Username][req.Target]; !ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.NotFound, "%s is not blocked", req.Target)
+	}
+	deleteNested(s.contacts.Blocked, req.Username, req.Target)
+	err := s.commitContacts()
+	s.contactsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s unblocked %s", req.Username, req.Target)
+
+	s.refreshPresenceBetween(req.Username, req.Target, nil)
+
+	return &pb.ContactActionResponse{
+		Success: true,
+		Message: "User unblocked",
+	}, nil
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const contactsFile = "contacts.json"
+
+// The social graph, saved to the data directory.
+// Contacts are stored in both directions, requests are keyed by recipient then requester
+// and blocks by the user who blocked.
+type contactBook struct {
+	Contacts map[string]map[string]int64 `json:"contacts"` // Unix time the users became contacts
+	Requests map[string]map[string]int64 `json:"requests"` // Unix time the request was sent
+	Blocked  map[string]map[string]int64 `json:"blocked"`  // Unix time the user was blocked
+}
+
+// Read the saved contacts, a missing file means nobody has contacts yet
+func (s *server) loadContacts() error {
+	s.contactsMutex.Lock()
+	defer s.contactsMutex.Unlock()
+
+	s.contacts = contactBook{
+		Contacts: make(map[string]map[string]int64),
+		Requests: make(map[string]map[string]int64),
+		Blocked:  make(map[string]map[string]int64),
+	}
+	data, err := os.ReadFile(filepath.Join(s.dataDir, contactsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &s.contacts); err != nil {
+		return err
+	}
+	// A file with no entries may hold null maps
+	if s.contacts.Contacts == nil {
+		s.contacts.Contacts = make(map[string]map[string]int64)
+	}
+	if s.contacts.Requests == nil {
+		s.contacts.Requests = make(map[string]map[string]int64)
+	}
+	if s.contacts.Blocked == nil {
+		s.contacts.Blocked = make(map[string]map[string]int64)
+	}
+	return nil
+}
+
+// Write the contacts to disk, caller must hold contactsMutex
+func (s *server) saveContacts() error {
+	data, err := json.MarshalIndent(s.contacts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dataDir, contactsFile), data)
+}
+
+// Set a value in a nested map, creating the inner map if needed
+func setNested(m map[string]map[string]int64, outer, inner string, value int64) {
+	if m[outer] == nil {
+		m[outer] = make(map[string]int64)
+	}
+	m[outer][inner] = value
+}
+
+// Remove a value from a nested map, dropping the inner map once empty
+func deleteNested(m map[string]map[string]int64, outer, inner string) {
+	delete(m[outer], inner)
+	if len(m[outer]) == 0 {
+		delete(m, outer)
+	}
+}
+
+// Check whether two users are contacts
+func (s *server) areContacts(a, b string) bool {
+	s.contactsMutex.RLock()
+	defer s.contactsMutex.RUnlock()
+
+	_, ok := s.contacts.Contacts[a][b]
+	return ok
+}
+
+// Get the usernames of a user's contacts
+func (s *server) contactsOf(username string) []string {
+	s.contactsMutex.RLock()
+	defer s.contactsMutex.RUnlock()
+
+	contacts := make([]string, 0, len(s.contacts.Contacts[username]))
+	for contact := range s.contacts.Contacts[username] {
+		contacts = append(contacts, contact)
+	}
+	sort.Strings(contacts)
+	return contacts
+}
+
+// Only feeds scoped to contacts change when two users become or stop being contacts
+func contactFeeds(sub *activeUsersSubscriber) bool {
+	return sub.scope == pb.ActiveUsersRequest_CONTACTS
+}
+
+// Check the users named in a contact action
+func (s *server) validateContactAction(req *pb.ContactActionRequest) error {
+	if req.Username == "" || req.Target == "" {
+		return status.Error(codes.InvalidArgument, "username and target are required")
+	}
+	if req.Username == req.Target {
+		return status.Error(codes.InvalidArgument, "you can't be your own contact")
+	}
+	if !s.hasProfile(req.Target) {
+		return status.Errorf(codes.NotFound, "user %s has never joined", req.Target)
+	}
+	return nil
+}
+
+// Save the contacts after a change, caller must hold contactsMutex
+func (s *server) commitContacts() error {
+	if err := s.saveContacts(); err != nil {
+		log.Printf("Error saving contacts: %v", err)
+		return status.Error(codes.Internal, "failed to save contacts")
+	}
+	return nil
+}
+
+// Tell both users about a change to their contact request or contact
+func (s *server) sendContactEvent(kind pb.ContactEvent_Kind, from, to string) {
+	event := &pb.ChatMessage{
+		Sender:    "System",
+		Timestamp: time.Now().Format("15:04:05"),
+		Type:      pb.ChatMessage_CONTACT,
+		Contact: &pb.ContactEvent{
+			Kind: kind,
+			From: from,
+			To:   to,
+		},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendToMembers([]string{from, to}, event)
+}
+
+// SendContactRequest asks another user to become a contact, answering their open request accepts it
+func (s *server) SendContactRequest(ctx context.Context, req *pb.ContactActionRequest) (*pb.ContactActionResponse, error) {
+	if err := s.validateContactAction(req); err != nil {
+		return nil, err
+	}
+
+	s.contactsMutex.Lock()
+	if _, ok := s.contacts.Blocked[req.Username][req.Target]; ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.FailedPrecondition, "unblock %s first", req.Target)
+	}
+	if _, ok := s.contacts.Blocked[req.Target][req.Username]; ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.FailedPrecondition, "%s is not accepting contact requests", req.Target)
+	}
+	if _, ok := s.contacts.Contacts[req.Username][req.Target]; ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.AlreadyExists, "%s is already a contact", req.Target)
+	}
+	if _, ok := s.contacts.Requests[req.Target][req.Username]; ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.AlreadyExists, "a request to %s is already waiting", req.Target)
+	}
+
+	// Both users asked, so they agree
+	if _, ok := s.contacts.Requests[req.Username][req.Target]; ok {
+		s.acceptContact(req.Username, req.Target)
+		err := s.commitContacts()
+		s.contactsMutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("User %s and %s are now contacts", req.Username, req.Target)
+		s.sendContactEvent(pb.ContactEvent_ACCEPTED, req.Target, req.Username)
+		s.refreshPresenceBetween(req.Username, req.Target, contactFeeds)
+		return &pb.ContactActionResponse{
+			Success:  true,
+			Message:  "Accepted the request of " + req.Target,
+			Contacts: true,
+		}, nil
+	}
+
+	setNested(s.contacts.Requests, req.Target, req.Username, time.Now().Unix())
+	err := s.commitContacts()
+	s.contactsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s sent a contact request to %s", req.Username, req.Target)
+	s.sendContactEvent(pb.ContactEvent_REQUESTED, req.Username, req.Target)
+	return &pb.ContactActionResponse{
+		Success: true,
+		Message: "Contact request sent",
+	}, nil
+}
+
+// Turn the request of requester to recipient into a contact, caller must hold contactsMutex
+func (s *server) acceptContact(recipient, requester string) {
+	deleteNested(s.contacts.Requests, recipient, requester)
+	// Both may have asked at the same time
+	deleteNested(s.contacts.Requests, requester, recipient)
+
+	now := time.Now().Unix()
+	setNested(s.contacts.Contacts, recipient, requester, now)
+	setNested(s.contacts.Contacts, requester, recipient, now)
+}
+
+// AcceptContactRequest accepts an open request from the target
+func (s *server) AcceptContactRequest(ctx context.Context, req *pb.ContactActionRequest) (*pb.ContactActionResponse, error) {
+	if err := s.validateContactAction(req); err != nil {
+		return nil, err
+	}
+
+	s.contactsMutex.Lock()
+	if _, ok := s.contacts.Requests[req.Username][req.Target]; !ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.NotFound, "no contact request from %s", req.Target)
+	}
+	s.acceptContact(req.Username, req.Target)
+	err := s.commitContacts()
+	s.contactsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s and %s are now contacts", req.Username, req.Target)
+	s.sendContactEvent(pb.ContactEvent_ACCEPTED, req.Target, req.Username)
+	s.refreshPresenceBetween(req.Username, req.Target, contactFeeds)
+	return &pb.ContactActionResponse{
+		Success:  true,
+		Message:  "Contact request accepted",
+		Contacts: true,
+	}, nil
+}
+
+// DeclineContactRequest declines an open request from the target, or withdraws one sent to them
+func (s *server) DeclineContactRequest(ctx context.Context, req *pb.ContactActionRequest) (*pb.ContactActionResponse, error) {
+	if err := s.validateContactAction(req); err != nil {
+		return nil, err
+	}
+
+	var from, to, message string
+	s.contactsMutex.Lock()
+	if _, ok := s.contacts.Requests[req.Username][req.Target]; ok {
+		from, to, message = req.Target, req.Username, "Contact request declined"
+	} else if _, ok := s.contacts.Requests[req.Target][req.Username]; ok {
+		from, to, message = req.Username, req.Target, "Contact request withdrawn"
+	} else {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.NotFound, "no contact request between you and %s", req.Target)
+	}
+	deleteNested(s.contacts.Requests, to, from)
+	err := s.commitContacts()
+	s.contactsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Contact request from %s to %s closed by %s", from, to, req.Username)
+	s.sendContactEvent(pb.ContactEvent_DECLINED, from, to)
+	return &pb.ContactActionResponse{
+		Success: true,
+		Message: message,
+	}, nil
+}
+
+// RemoveContact removes the target from the contacts of the user and the other way around
+func (s *server) RemoveContact(ctx context.Context, req *pb.ContactActionRequest) (*pb.ContactActionResponse, error) {
+	if err := s.validateContactAction(req); err != nil {
+		return nil, err
+	}
+
+	s.contactsMutex.Lock()
+	if _, ok := s.contacts.Contacts[req.Username][req.Target]; !ok {
+		s.contactsMutex.Unlock()
+		return nil, status.Errorf(codes.NotFound, "%s is not a contact", req.Target)
+	}
+	deleteNested(s.contacts.Contacts, req.Username, req.Target)
+	deleteNested(s.contacts.Contacts, req.Target, req.Username)
+	err := s.commitContacts()
+	s.contactsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s removed contact %s", req.Username, req.Target)
+	s.sendContactEvent(pb.ContactEvent_REMOVED, req.Username, req.Target)
+	s.refreshPresenceBetween(req.Username, req.Target, contactFeeds)
+	return &pb.ContactActionResponse{
+		Success: true,
+		Message: "Contact removed",
+	}, nil
+}
+
+// ListContacts returns the contacts of a user, their open requests and the users they blocked
+func (s *server) ListContacts(ctx context.Context, req *pb.ListContactsRequest) (*pb.ListContactsResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	resp := &pb.ListContactsResponse{}
+	s.contactsMutex.RLock()
+	for contact, since := range s.contacts.Contacts[req.Username] {
+		resp.Contacts = append(resp.Contacts, &pb.Contact{Username: contact, Since: since})
+	}
+	for requester, sentAt := range s.contacts.Requests[req.Username] {
+		resp.Incoming = append(resp.Incoming, &pb.PendingContactRequest{Username: requester, SentAt: sentAt})
+	}
+	for recipient, requests := range s.contacts.Requests {
+		if sentAt, ok := requests[req.Username]; ok {
+			resp.Outgoing = append(resp.Outgoing, &pb.PendingContactRequest{Username: recipient, SentAt: sentAt})
+		}
+	}
+	for blocked, since := range s.contacts.Blocked[req.Username] {
+		resp.Blocked = append(resp.Blocked, &pb.BlockedUser{Username: blocked, Since: since})
+	}
+	s.contactsMutex.RUnlock()
+
+	// Names and presence are filled in after releasing contactsMutex
+	for _, contact := range resp.Contacts {
+		contact.DisplayName = s.displayName(contact.Username)
+		contact.Online = s.isActive(contact.Username) && !s.isInvisible(contact.Username)
+	}
+	for _, pending := range resp.Incoming {
+		pending.DisplayName = s.displayName(pending.Username)
+	}
+	for _, pending := range resp.Outgoing {
+		pending.DisplayName = s.displayName(pending.Username)
+	}
+	for _, blocked := range resp.Blocked {
+		blocked.DisplayName = s.displayName(blocked.Username)
+	}
+	sort.Slice(resp.Contacts, func(i, j int) bool { return resp.Contacts[i].Username < resp.Contacts[j].Username })
+	sort.Slice(resp.Incoming, func(i, j int) bool { return resp.Incoming[i].SentAt < resp.Incoming[j].SentAt })
+	sort.Slice(resp.Outgoing, func(i, j int) bool { return resp.Outgoing[i].SentAt < resp.Outgoing[j].SentAt })
+	sort.Slice(resp.Blocked, func(i, j int) bool { return resp.Blocked[i].Username < resp.Blocked[j].Username })
+
+	return resp, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Find a stored message by ID, failures come back as gRPC errors
+func (s *server) findMessage(id string) (*pb.ChatMessage, error) {
+	msg, err := s.messages.Get(id)
+	if err != nil {
+		return nil, storeStatus(err, id)
+	}
+	return msg, nil
+}
+
+// Check whether a user may edit or delete a message: its author or a moderator
+func (s *server) canModify(username string, msg *pb.ChatMessage) bool {
+	return username == msg.Sender || s.moderators[username]
+}
+
+// EditMessage replaces the text of a message and notifies the room
+func (s *server) EditMessage(ctx context.Context, req *pb.EditMessageRequest) (*pb.MessageActionResponse, error) {
+	text := strings.TrimSpace(req.Message)
+	if req.Username == "" || req.MessageId == "" || text == "" {
+		return nil, status.Error(codes.InvalidArgument, "username, message_id and message are required")
+	}
+
+	// Parsed before locking since it looks up users under other mutexes
+	mentions := s.parseMentions(text)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	original, err := s.findMessage(req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	room := original.Room
+	if !s.canModify(req.Username, original) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %s may not edit message %s", req.Username, req.MessageId)
+	}
+
+	// Replace rather than mutate, history pages may still be sending the old message
+	edited := proto.Clone(original).(*pb.ChatMessage)
+	edited.Message = text
+	edited.Edited = true
+	edited.Mentions = mentions
+	if err := s.messages.Update(edited); err != nil {
+		return nil, storeStatus(err, req.MessageId)
+	}
+	s.search.add(edited)
+
+	event := proto.Clone(edited).(*pb.ChatMessage)
+	event.Type = pb.ChatMessage_EDIT
+	sent := s.sendToMembers(s.withoutBlockers(s.roomMembers(room), edited.Sender), event)
+
+	// Only users added by the edit hear about it as a mention
+	s.sendMentions(edited, newMentions(original.Mentions, mentions))
+
+	log.Printf("Message %s in %s edited by %s, notified %d clients", req.MessageId, room, req.Username, sent)
+
+	return &pb.MessageActionResponse{
+		Success:     true,
+		Message:     "Message edited",
+		ChatMessage: edited,
+	}, nil
+}
+
+// DeleteMessage removes a message from the room and notifies its members
+func (s *server) DeleteMessage(ctx context.Context, req *pb.DeleteMessageRequest) (*pb.MessageActionResponse, error) {
+	if req.Username == "" || req.MessageId == "" {
+		return nil, status.Error(codes.InvalidArgument, "username and message_id are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted, err := s.findMessage(req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	room := deleted.Room
+	if !s.canModify(req.Username, deleted) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %s may not delete message %s", req.Username, req.MessageId)
+	}
+	event, sent, err := s.removeMessage(deleted, false)
+	if err != nil {
+		return nil, storeStatus(err, req.MessageId)
+	}
+
+	log.Printf("Message %s in %s deleted by %s, notified %d clients", req.MessageId, room, req.Username, sent)
+
+	return &pb.MessageActionResponse{
+		Success:     true,
+		Message:     "Message deleted",
+		ChatMessage: event,
+	}, nil
+}
+
+// Delete a stored message, or purge it for retention, and tell the room. Returns the delete event and
+// how many clients got it, caller must hold s.mu.
+func (s *server) removeMessage(deleted *pb.ChatMessage, purge bool) (*pb.ChatMessage, int, error) {
+	remove := s.messages.Delete
+	if purge {
+		// Clients resuming from before a purged message are told what they missed is gone
+		remove = s.messages.Purge
+	}
+	if _, err := remove(deleted.Id); err != nil {
+		return nil, 0, err
+	}
+	s.search.remove(deleted.Id)
+
+	// The event only identifies the message, its content is gone
+	event := &pb.ChatMessage{
+		Id:        deleted.Id,
+		Sequence:  deleted.Sequence,
+		Room:      deleted.Room,
+		Sender:    deleted.Sender,
+		Timestamp: deleted.Timestamp,
+		Type:      pb.ChatMessage_DELETE,
+	}
+	members := s.roomMembers(deleted.Room)
+	sent := s.sendToMembers(s.withoutBlockers(members, deleted.Sender), event)
+
+	// Deleting a reply shrinks its thread
+	if deleted.ParentId != "" {
+		s.updateReplyCount(deleted.Room, deleted.ParentId, -1, members)
+	}
+	return event, sent, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Presence deltas waiting to be sent on a single ActiveUsersStream, a subscriber that falls
+	// further behind loses deltas and has to fetch a snapshot
+	presenceQueueSize = 256
+	maxWatchedUsers   = 500 // Users a subscriber may name with the list scope
+)
+
+// Check the scope of a presence request and build the subscriber it describes
+func (s *server) newPresenceSubscriber(req *pb.ActiveUsersRequest) (*activeUsersSubscriber, error) {
+	sub := &activeUsersSubscriber{username: req.Username, scope: req.Scope}
+	// Older clients only set the room
+	if sub.scope == pb.ActiveUsersRequest_ALL && req.Room != "" {
+		sub.scope = pb.ActiveUsersRequest_ROOM
+	}
+	if sub.scope != pb.ActiveUsersRequest_ROOM && req.Room != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "room can't be combined with the %s scope", sub.scope)
+	}
+	if sub.scope != pb.ActiveUsersRequest_LIST && len(req.Usernames) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "usernames can't be combined with the %s scope", sub.scope)
+	}
+
+	switch sub.scope {
+	case pb.ActiveUsersRequest_ALL:
+	case pb.ActiveUsersRequest_ROOM:
+		if req.Room == "" {
+			return nil, status.Error(codes.InvalidArgument, "room is required for the room scope")
+		}
+		sub.room = normalizeRoom(req.Room)
+		if s.roomInfo(sub.room) == nil {
+			return nil, status.Errorf(codes.NotFound, "room %s does not exist", sub.room)
+		}
+	case pb.ActiveUsersRequest_CONTACTS:
+		if req.Username == "" {
+			return nil, status.Error(codes.InvalidArgument, "username is required for the contacts scope")
+		}
+	case pb.ActiveUsersRequest_LIST:
+		if len(req.Usernames) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "usernames are required for the list scope")
+		}
+		if len(req.Usernames) > maxWatchedUsers {
+			return nil, status.Errorf(codes.InvalidArgument, "at most %d usernames can be watched", maxWatchedUsers)
+		}
+		sub.watched = make(map[string]bool, len(req.Usernames))
+		for _, username := range req.Usernames {
+			if username = strings.TrimSpace(username); username != "" {
+				sub.watched[username] = true
+			}
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown scope %d", sub.scope)
+	}
+	return sub, nil
+}
+
+// Check whether a user is in the chat
+func (s *server) isActive(username string) bool {
+	s.activeUsersMutex.RLock()
+	defer s.activeUsersMutex.RUnlock()
+
+	return s.activeUsers[username]
+}
+
+// Advance the presence version, caller must hold s.mu
+func (s *server) nextPresenceVersion() int64 {
+	s.presenceVersion++
+	return s.presenceVersion
+}
+
+// Build the full list of active users a subscriber sees at the current version, caller must hold s.mu.
+// Holding s.mu keeps the snapshot consistent with the deltas queued before and after it.
+func (s *server) presenceSnapshot(sub *activeUsersSubscriber) *pb.ActiveUsersUpdate {
+	s.activeUsersMutex.RLock()
+	users := make([]string, 0, len(s.activeUsers))
+	for user := range s.activeUsers {
+		users = append(users, user)
+	}
+	s.activeUsersMutex.RUnlock()
+
+	users = s.visibleUsers(sub, users)
+	statuses, customs := s.statusSnapshot(users)
+	names, avatars := s.profileSnapshot(users)
+	return &pb.ActiveUsersUpdate{
+		UpdateType:     pb.ActiveUsersUpdate_FULL_LIST,
+		Users:          users,
+		UserStatuses:   statuses,
+		Room:           sub.room,
+		CustomStatuses: customs,
+		Sessions:       s.sessionsSnapshot(users),
+		Version:        s.presenceVersion,
+		DisplayNames:   names,
+		AvatarVersions: avatars,
+	}
+}
+
+// Build a delta carrying the current status, sessions and profile of a single user, caller must hold s.mu
+func (s *server) userDelta(updateType pb.ActiveUsersUpdate_UpdateType, username string) *pb.ActiveUsersUpdate {
+	statuses, customs := s.statusSnapshot([]string{username})
+	names, avatars := s.profileSnapshot([]string{username})
+	return &pb.ActiveUsersUpdate{
+		UpdateType:     updateType,
+		Username:       username,
+		UserStatuses:   statuses,
+		CustomStatuses: customs,
+		Sessions:       s.sessionsSnapshot([]string{username}),
+		DisplayNames:   names,
+		AvatarVersions: avatars,
+	}
+}
+
+// Queue a versioned delta for a subscriber without blocking, caller must hold s.mu.
+// A full queue drops the delta, the next one still points back at it through previous_version
+// so the subscriber notices the gap.
+func (s *server) deliverPresence(id string, sub *activeUsersSubscriber, update *pb.ActiveUsersUpdate) {
+	update = proto.Clone(update).(*pb.ActiveUsersUpdate)
+	update.Room = sub.room
+	update.PreviousVersion = sub.lastVersion
+	sub.lastVersion = update.Version
+
+	select {
+	case sub.updates <- update:
+	default:
+		// Only log the first drop, a stuck stream would otherwise log every delta
+		if !sub.dropped.Swap(true) {
+			log.Printf("Presence queue of stream %s is full, dropping updates from version %d until it catches up",
+				id, update.Version)
+		}
+	}
+}
+
+// Queue a snapshot for a subscriber that lost deltas, so it recovers even if no further delta
+// arrives to reveal the gap. Keeps the dropped flag set if the queue filled up again.
+func (s *server) resyncPresence(id string, sub *activeUsersSubscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.presenceSnapshot(sub)
+	snapshot.PreviousVersion = sub.lastVersion
+
+	select {
+	case sub.updates <- snapshot:
+		sub.dropped.Store(false)
+		sub.lastVersion = snapshot.Version
+		log.Printf("Queued presence snapshot at version %d for stream %s after dropped updates", snapshot.Version, id)
+	default:
+	}
+}
+
+// Stamp a delta with the next version and queue it for every subscriber that sees the user, caller must hold s.mu
+func (s *server) publishPresence(update *pb.ActiveUsersUpdate) {
+	update.Version = s.nextPresenceVersion()
+	for id, sub := range s.userUpdateStreams {
+		if s.subscriberSees(sub, update.Username) {
+			s.deliverPresence(id, sub, update)
+		}
+	}
+}
+
+// Re-announce two users to each other's presence feeds after something changed whether they see
+// each other, like a block or a new contact. Every affected feed of one user gets a join or leave of
+// the other, a nil affected means all of them.
+func (s *server) refreshPresenceBetween(a, b string, affected func(*activeUsersSubscriber) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		viewer, username := pair[0], pair[1]
+		if !s.isActive(username) {
+			continue
+		}
+
+		join := s.userDelta(pb.ActiveUsersUpdate_JOIN, username)
+		join.Version = s.nextPresenceVersion()
+		leave := &pb.ActiveUsersUpdate{
+			UpdateType: pb.ActiveUsersUpdate_LEAVE,
+			Username:   username,
+			Version:    join.Version,
+		}
+
+		for id, sub := range s.userUpdateStreams {
+			if sub.username != viewer || (affected != nil && !affected(sub)) {
+				continue
+			}
+			if s.subscriberSees(sub, username) {
+				s.deliverPresence(id, sub, join)
+			} else {
+				s.deliverPresence(id, sub, leave)
+			}
+		}
+	}
+}
+
+// GetActiveUsers returns a snapshot of the active users in the requested scope
+func (s *server) GetActiveUsers(ctx context.Context, req *pb.ActiveUsersRequest) (*pb.ActiveUsersUpdate, error) {
+	sub, err := s.newPresenceSubscriber(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.presenceSnapshot(sub), nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const messagesFile = "messages.log"
+
+// A line of the message log
+type fileRecord struct {
+	Op      string          `json:"op"` // append, update, delete, purge or last
+	Message json.RawMessage `json:"message,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Room    string          `json:"room,omitempty"`
+	Last    int64           `json:"last,omitempty"`    // Highest sequence of the room, compacted logs no longer have it
+	Trimmed int64           `json:"trimmed,omitempty"` // Highest sequence purged from the room, likewise
+}
+
+// fileStore keeps every message in memory and appends each change to a log that is replayed on startup
+type fileStore struct {
+	*memoryStore
+	mu      sync.Mutex // Serializes writes so the log has changes in the order they were applied
+	path    string
+	file    *os.File
+	size    int64 // Bytes of complete records in the log
+	failed  error // Set when a failed write couldn't be undone, no more records are accepted
+	deleted int   // Delete records applied since the log was last compacted
+}
+
+// Open a message log, creating it if needed, and load the messages in it
+func openFileStore(path string) (*fileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &fileStore{memoryStore: newMemoryStore(0), path: path, file: file}
+	if err := store.replay(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Apply every record in the log. A record without its newline was cut short by a crash and is dropped.
+func (f *fileStore) replay() error {
+	reader := bufio.NewReader(f.file)
+	var offset int64
+	records := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Dropping %d bytes of an unfinished record at the end of the message log", len(line))
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := f.apply(line); err != nil {
+			return fmt.Errorf("record at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+		records++
+	}
+
+	if err := f.file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := f.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	f.size = offset
+	log.Printf("Loaded %d records from the message log", records)
+	return nil
+}
+
+// Apply a single record of the log to the messages in memory
+func (f *fileStore) apply(line []byte) error {
+	var record fileRecord
+	if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+		return err
+	}
+
+	switch record.Op {
+	case "append", "update":
+		msg := &pb.ChatMessage{}
+		if err := protojson.Unmarshal(record.Message, msg); err != nil {
+			return err
+		}
+		if record.Op == "append" {
+			return f.memoryStore.Append(msg)
+		}
+		return f.memoryStore.Update(msg)
+	case "delete", "purge":
+		remove := f.memoryStore.Delete
+		if record.Op == "purge" {
+			remove = f.memoryStore.Purge
+		}
+		if _, err := remove(record.ID); err != nil {
+			return err
+		}
+		f.deleted++
+		return nil
+	case "last":
+		f.memoryStore.trim(record.Room, record.Trimmed)
+		f.memoryStore.mu.Lock()
+		defer f.memoryStore.mu.Unlock()
+		bounds := &f.memoryStore.room(record.Room).bounds
+		if record.Last > bounds.Last {
+			bounds.Last = record.Last
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", record.Op)
+}
+
+// Encode a record as a line of the log
+func encodeFileRecord(record fileRecord, msg *pb.ChatMessage) ([]byte, error) {
+	if msg != nil {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		record.Message = data
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// Write a record to the end of the log and flush it to disk, caller must hold f.mu
+func (f *fileStore) write(record fileRecord, msg *pb.ChatMessage) error {
+	if f.failed != nil {
+		return fmt.Errorf("message log stopped after an earlier failure: %w", f.failed)
+	}
+	line, err := encodeFileRecord(record, msg)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Write(line); err != nil {
+		return f.discard(err, false)
+	}
+	if err := f.file.Sync(); err != nil {
+		// After a failed sync the kernel may have dropped earlier pages as well, a retry could wrongly succeed
+		return f.discard(err, true)
+	}
+	f.size += int64(len(line))
+	return nil
+}
+
+// Cut a record that failed to be written off the log, otherwise the next one would continue its line
+// and the log couldn't be replayed, caller must hold f.mu. When that fails too, or stop is set,
+// the log can't be trusted anymore and later writes are refused.
+func (f *fileStore) discard(err error, stop bool) error {
+	if terr := f.file.Truncate(f.size); terr != nil {
+		log.Printf("Failed to undo a partial record in the message log: %v", terr)
+		stop = true
+	} else if _, serr := f.file.Seek(f.size, io.SeekStart); serr != nil {
+		log.Printf("Failed to undo a partial record in the message log: %v", serr)
+		stop = true
+	}
+	if stop {
+		f.failed = err
+		log.Printf("Message log stopped accepting records: %v", err)
+	}
+	return err
+}
+
+// Rewrite the log with only the messages still stored once some were deleted, the log otherwise keeps them.
+// The new log is written next to the old one and renamed over it, so a crash leaves one or the other.
+func (f *fileStore) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.deleted == 0 || f.failed != nil {
+		return nil
+	}
+	tmp := f.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	size, err := f.writeMessages(file)
+	if err == nil {
+		err = os.Rename(tmp, f.path)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// The new log is open at its end and takes over from the old one
+	f.file.Close()
+	f.file = file
+	f.size = size
+	if err := syncDir(filepath.Dir(f.path)); err != nil {
+		return err
+	}
+
+	log.Printf("Compacted the message log after %d deletes", f.deleted)
+	f.deleted = 0
+	return nil
+}
+
+// Write an append record for every stored message and the highest sequence of each room,
+// returns how many bytes were written, caller must hold f.mu
+func (f *fileStore) writeMessages(file *os.File) (int64, error) {
+	f.memoryStore.mu.RLock()
+	defer f.memoryStore.mu.RUnlock()
+
+	writer := bufio.NewWriter(file)
+	var size int64
+	for name, room := range f.memoryStore.rooms {
+		for _, msg := range room.messages {
+			line, err := encodeFileRecord(fileRecord{Op: "append"}, msg)
+			if err != nil {
+				return 0, err
+			}
+			writer.Write(line)
+			size += int64(len(line))
+		}
+		// Deleted messages may have had higher sequences than the ones left
+		line, err := encodeFileRecord(fileRecord{Op: "last", Room: name, Last: room.bounds.Last, Trimmed: room.bounds.Trimmed}, nil)
+		if err != nil {
+			return 0, err
+		}
+		writer.Write(line)
+		size += int64(len(line))
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+	return size, file.Sync()
+}
+
+func (f *fileStore) Append(msg *pb.ChatMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.write(fileRecord{Op: "append"}, msg); err != nil {
+		return err
+	}
+	return f.memoryStore.Append(msg)
+}
+
+func (f *fileStore) Update(msg *pb.ChatMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.memoryStore.Get(msg.Id); err != nil {
+		return err
+	}
+	if err := f.write(fileRecord{Op: "update"}, msg); err != nil {
+		return err
+	}
+	return f.memoryStore.Update(msg)
+}
+
+func (f *fileStore) Delete(id string) (*pb.ChatMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.memoryStore.Get(id); err != nil {
+		return nil, err
+	}
+	if err := f.write(fileRecord{Op: "delete", ID: id}, nil); err != nil {
+		return nil, err
+	}
+	f.deleted++
+	return f.memoryStore.Delete(id)
+}
+
+func (f *fileStore) Purge(id string) (*pb.ChatMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.memoryStore.Get(id); err != nil {
+		return nil, err
+	}
+	if err := f.write(fileRecord{Op: "purge", ID: id}, nil); err != nil {
+		return nil, err
+	}
+	f.deleted++
+	return f.memoryStore.Purge(id)
+}
+
+func (f *fileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
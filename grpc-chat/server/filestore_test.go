@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "grpc-chat/proto"
+)
+
+func appendFileMessages(t *testing.T, f *fileStore, first, last int64) {
+	t.Helper()
+	for seq := first; seq <= last; seq++ {
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", Message: fmt.Sprintf("message %d", seq)}
+		if err := f.Append(msg); err != nil {
+			t.Fatalf("append %d: %v", seq, err)
+		}
+	}
+}
+
+func TestFileStoreUndoesFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), messagesFile)
+	f, err := openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFileMessages(t, f, 1, 2)
+
+	// A write that stopped halfway, the next record must start on a line of its own
+	f.mu.Lock()
+	f.file.Write([]byte(`{"op":"append","mess`))
+	err = f.discard(os.ErrDeadlineExceeded, false)
+	f.mu.Unlock()
+	if err == nil || f.failed != nil {
+		t.Fatalf("discard returned %v, failed is %v", err, f.failed)
+	}
+	appendFileMessages(t, f, 3, 3)
+	f.Close()
+
+	f, err = openFileStore(path)
+	if err != nil {
+		t.Fatalf("replaying after an undone write: %v", err)
+	}
+	defer f.Close()
+	messages, _, err := f.Range(MessageRange{Room: "general"})
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("got %d messages, %v", len(messages), err)
+	}
+}
+
+func TestFileStoreStopsAfterUndoFails(t *testing.T) {
+	f, err := openFileStore(filepath.Join(t.TempDir(), messagesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFileMessages(t, f, 1, 1)
+
+	// Neither the write nor the truncate can go through a closed file
+	f.file.Close()
+	msg := &pb.ChatMessage{Id: "m2", Sequence: 2, Room: "general", Sender: "alice"}
+	if err := f.Append(msg); err == nil {
+		t.Fatal("append to a closed log succeeded")
+	}
+	if f.failed == nil {
+		t.Fatal("store wasn't stopped after the undo failed")
+	}
+	if _, err := f.Get("m2"); err != errMessageNotFound {
+		t.Fatalf("failed message was stored: %v", err)
+	}
+}
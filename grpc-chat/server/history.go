@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxHistoryLimit     = 100 // Largest page a history request gets
+	defaultHistoryLimit = 50  // Page size when a request doesn't set one
+)
+
+// Clamp a requested page size to the largest page
+func historyLimit(limit int32) int {
+	if limit <= 0 {
+		return defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		return maxHistoryLimit
+	}
+	return int(limit)
+}
+
+// GetHistory returns a page of recent messages from a room the user has joined
+func (s *server) GetHistory(ctx context.Context, req *pb.GetHistoryRequest) (*pb.GetHistoryResponse, error) {
+	room := normalizeRoom(req.Room)
+	if !s.isRoomMember(room, req.Username) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %s is not in room %s", req.Username, room)
+	}
+	if req.BeforeSequence > 0 && req.AfterSequence > 0 && req.AfterSequence >= req.BeforeSequence-1 {
+		return &pb.GetHistoryResponse{}, nil
+	}
+
+	messages, hasMore, err := s.messages.Range(MessageRange{
+		Room:   room,
+		After:  req.AfterSequence,
+		Before: req.BeforeSequence,
+		Limit:  historyLimit(req.Limit),
+	})
+	if err != nil {
+		return nil, storeStatus(err, "")
+	}
+	messages = s.visibleMessages(req.Username, messages)
+
+	log.Printf("History for %s in %s: %d messages (before %d, after %d)", req.Username, room, len(messages), req.BeforeSequence, req.AfterSequence)
+
+	return &pb.GetHistoryResponse{
+		Messages: messages,
+		HasMore:  hasMore,
+	}, nil
+}
+
+// Replay stored messages of a room to a single chat stream.
+// With a sequence what came after it is replayed so a reconnecting client can resume, up to the
+// largest history page, otherwise only the most recent backlog. The caller must hold s.mu, which
+// keeps live broadcasts from interleaving with the replay.
+func (s *server) replayHistory(streamID string, req *pb.ChatMessage) {
+	clientStream, ok := s.streams[streamID]
+	if !ok {
+		return
+	}
+
+	room := normalizeRoom(req.Room)
+	if !s.isRoomMember(room, req.Sender) {
+		notice := &pb.ChatMessage{
+			Sender:    "System",
+			Message:   fmt.Sprintf("You are not a member of room %s", room),
+			Timestamp: time.Now().Format("15:04:05"),
+			Room:      room,
+		}
+		if err := clientStream.Send(notice); err != nil {
+			log.Printf("Error sending to stream %s: %v", streamID, err)
+		}
+		return
+	}
+
+	// Without a sequence only the recent backlog is sent
+	window := MessageRange{Room: room, Limit: defaultHistoryLimit}
+	gap := false
+	if req.Sequence > 0 {
+		bounds, err := s.messages.Bounds(room)
+		if err != nil {
+			log.Printf("Error replaying history of %s to stream %s: %v", room, streamID, err)
+			return
+		}
+
+		// Tell the client explicitly when part of what it missed is gone
+		window = MessageRange{Room: room, After: req.Sequence, Limit: maxHistoryLimit}
+		if req.Sequence > s.roomSequence(room) {
+			// The room's sequence was reset, e.g. by a restart with the memory store, so replay the latest messages
+			gap = true
+			window = MessageRange{Room: room, Limit: maxHistoryLimit}
+		} else if req.Sequence < bounds.Trimmed {
+			gap = true
+		}
+	}
+
+	messages, hasMore, err := s.messages.Range(window)
+	if err != nil {
+		log.Printf("Error replaying history of %s to stream %s: %v", room, streamID, err)
+		return
+	}
+
+	// Far behind only the newest messages are replayed, the client pages through the rest with GetHistory
+	if hasMore && window.After > 0 {
+		messages, _, err = s.messages.Range(MessageRange{Room: room, Limit: maxHistoryLimit})
+		if err != nil {
+			log.Printf("Error replaying history of %s to stream %s: %v", room, streamID, err)
+			return
+		}
+		notice := &pb.ChatMessage{
+			Sender:    "System",
+			Message:   fmt.Sprintf("Only the latest %d messages in %s were replayed, load older ones from the history", len(messages), room),
+			Timestamp: time.Now().Format("15:04:05"),
+			Room:      room,
+			Type:      pb.ChatMessage_HISTORY_GAP,
+			Sequence:  messages[0].Sequence,
+		}
+		if err := clientStream.Send(notice); err != nil {
+			log.Printf("Error sending history gap to stream %s: %v", streamID, err)
+			return
+		}
+		log.Printf("History for %s in %s after %d is too long to replay, replaying from %d", req.Sender, room, req.Sequence, messages[0].Sequence)
+		gap = false
+	}
+
+	if gap {
+		firstAvailable := s.roomSequence(room) + 1
+		if len(messages) > 0 {
+			firstAvailable = messages[0].Sequence
+		}
+		notice := &pb.ChatMessage{
+			Sender:    "System",
+			Message:   fmt.Sprintf("Messages after #%d in %s are no longer available", req.Sequence, room),
+			Timestamp: time.Now().Format("15:04:05"),
+			Room:      room,
+			Type:      pb.ChatMessage_HISTORY_GAP,
+			Sequence:  firstAvailable,
+		}
+		if err := clientStream.Send(notice); err != nil {
+			log.Printf("Error sending history gap to stream %s: %v", streamID, err)
+			return
+		}
+		log.Printf("History gap for %s in %s: requested after %d, first available %d", req.Sender, room, req.Sequence, firstAvailable)
+	}
+
+	for _, msg := range s.visibleMessages(req.Sender, messages) {
+		if err := clientStream.Send(msg); err != nil {
+			log.Printf("Error replaying history to stream %s: %v", streamID, err)
+			return
+		}
+	}
+
+	log.Printf("Replayed %d messages from %s to %s", len(messages), room, req.Sender)
+}
+
+// Send the newest direct messages of a user to a session that just opened, they belong to no room's
+// history. The caller must hold s.mu. The gateway drops the ones its client already has by ID.
+func (s *server) replayDirectMessages(streamID, username string) {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return
+	}
+	clientStream, ok := s.streams[streamID]
+	if !ok {
+		return
+	}
+
+	messages, err := meta.LoadDirectMessages(username, defaultHistoryLimit)
+	if err != nil {
+		log.Printf("Error replaying direct messages of %s to stream %s: %v", username, streamID, err)
+		return
+	}
+	replayed := 0
+	for _, msg := range messages {
+		if msg.Sender != username && s.hasBlocked(username, msg.Sender) {
+			continue
+		}
+		if err := clientStream.Send(msg); err != nil {
+			log.Printf("Error replaying direct messages to stream %s: %v", streamID, err)
+			return
+		}
+		replayed++
+	}
+	if replayed > 0 {
+		log.Printf("Replayed %d direct messages to %s", replayed, username)
+	}
+}
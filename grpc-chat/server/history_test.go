@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc"
+)
+
+// A chat stream that keeps what the server sends on it
+type recordingStream struct {
+	grpc.ServerStream
+	sent []*pb.ChatMessage
+}
+
+func (r *recordingStream) Send(msg *pb.ChatMessage) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func (r *recordingStream) Recv() (*pb.ChatMessage, error) {
+	return nil, io.EOF
+}
+
+// Resume room general after a sequence on a new stream and return what was sent
+func resumeRoom(s *server, after int64) []*pb.ChatMessage {
+	stream := &recordingStream{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.streams["resume"] = stream
+	defer delete(s.streams, "resume")
+	s.replayHistory("resume", &pb.ChatMessage{Sender: "bob", Room: "general", Sequence: after, Type: pb.ChatMessage_HISTORY_REQUEST})
+	return stream.sent
+}
+
+func TestResumeAfterPurgeReportsGap(t *testing.T) {
+	for _, kind := range []string{"memory", "file", "sqlite", "wal"} {
+		t.Run(kind, func(t *testing.T) {
+			dir := t.TempDir()
+			messages, err := openStore(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for seq := int64(1); seq <= 5; seq++ {
+				msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", Message: fmt.Sprintf("message %d", seq), SentAt: time.Now().Unix()}
+				if err := messages.Append(msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := &server{
+				messages:  messages,
+				search:    newSearchIndex(),
+				rooms:     make(map[string]*chatRoom),
+				streams:   make(map[string]pb.ChatService_ChatStreamServer),
+				retention: &pb.RetentionPolicy{MaxMessages: 2},
+			}
+			s.ensureRoom("general", "", "alice")
+			s.addRoomMember("general", "bob")
+			s.compact()
+
+			// The purge has to survive a restart, the memory store has nothing to reopen
+			if kind != "memory" {
+				messages.Close()
+				if s.messages, err = openStore(kind, dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer s.messages.Close()
+			bounds, err := s.messages.Bounds("general")
+			if err != nil || bounds.Trimmed != 3 || bounds.Last != 5 {
+				t.Fatalf("bounds after the purge: %+v, %v", bounds, err)
+			}
+
+			// Resuming from before the purged messages tells the client they are gone
+			sent := resumeRoom(s, 1)
+			if len(sent) != 3 || sent[0].Type != pb.ChatMessage_HISTORY_GAP || sent[0].Sequence != 4 {
+				t.Fatalf("resume after 1 sent %v", sent)
+			}
+			if sent[1].Sequence != 4 || sent[2].Sequence != 5 {
+				t.Fatalf("resume after 1 replayed %d and %d", sent[1].Sequence, sent[2].Sequence)
+			}
+
+			// Nothing was missed by a client that saw the last purged message
+			sent = resumeRoom(s, 3)
+			if len(sent) != 2 || sent[0].Type == pb.ChatMessage_HISTORY_GAP {
+				t.Fatalf("resume after 3 sent %v", sent)
+			}
+		})
+	}
+}
+
+func TestResumeFarBehindReplaysNewestPage(t *testing.T) {
+	s := &server{
+		messages: newMemoryStore(0),
+		rooms:    make(map[string]*chatRoom),
+		streams:  make(map[string]pb.ChatService_ChatStreamServer),
+	}
+	s.ensureRoom("general", "", "alice")
+	s.addRoomMember("general", "bob")
+	total := int64(maxHistoryLimit + 50)
+	for seq := int64(1); seq <= total; seq++ {
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice"}
+		if err := s.messages.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The replay stops at one page of the newest messages and says where the rest starts
+	sent := resumeRoom(s, 10)
+	if len(sent) != maxHistoryLimit+1 {
+		t.Fatalf("sent %d messages, want %d", len(sent), maxHistoryLimit+1)
+	}
+	first := total - maxHistoryLimit + 1
+	if sent[0].Type != pb.ChatMessage_HISTORY_GAP || sent[0].Sequence != first {
+		t.Fatalf("first message sent is %v", sent[0])
+	}
+	if sent[1].Sequence != first || sent[len(sent)-1].Sequence != total {
+		t.Fatalf("replayed %d to %d", sent[1].Sequence, sent[len(sent)-1].Sequence)
+	}
+
+	// Close enough to the end everything missed is replayed
+	sent = resumeRoom(s, total-5)
+	if len(sent) != 5 || sent[0].Sequence != total-4 {
+		t.Fatalf("resume after %d sent %d messages starting at %d", total-5, len(sent), sent[0].Sequence)
+	}
+}
+
+func TestNewSessionGetsDirectMessagesAndReadPositions(t *testing.T) {
+	dir := t.TempDir()
+	messages, err := openStore("sqlite", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{
+		messages: messages,
+		receipts: make(map[string]map[string]*readPosition),
+		contacts: contactBook{Blocked: map[string]map[string]int64{"bob": {"carol": 1}}},
+	}
+	s.saveDirectMessage(&pb.ChatMessage{Id: "d1", Sender: "alice", Recipient: "bob", Message: "hi bob"})
+	s.saveDirectMessage(&pb.ChatMessage{Id: "d2", Sender: "carol", Recipient: "bob", Message: "blocked"})
+	s.saveReadPosition("general", "bob", &readPosition{delivered: 4, read: 2})
+	messages.Close()
+
+	// After a restart the read position is back and a new session of bob gets his direct messages
+	if s.messages, err = openStore("sqlite", dir); err != nil {
+		t.Fatal(err)
+	}
+	defer s.messages.Close()
+	s.rooms = make(map[string]*chatRoom)
+	s.receipts = make(map[string]map[string]*readPosition)
+	if err := s.loadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if pos := s.receipts["general"]["bob"]; pos == nil || pos.delivered != 4 || pos.read != 2 {
+		t.Fatalf("read position of bob %+v", pos)
+	}
+
+	stream := &recordingStream{}
+	s.streams = map[string]pb.ChatService_ChatStreamServer{"bob": stream}
+	s.replayDirectMessages("bob", "bob")
+	if len(stream.sent) != 1 || stream.sent[0].Id != "d1" {
+		t.Fatalf("replayed %v", stream.sent)
+	}
+}
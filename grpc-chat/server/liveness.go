@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	defaultIdleTimeout    = 5 * time.Minute // Connected users without activity are shown as away
+	defaultGhostTimeout   = time.Minute     // Active users without a chat stream are removed
+	presenceCheckInterval = 5 * time.Second
+)
+
+// Record that a user did something, bringing them back from automatic away
+func (s *server) touchUser(username string) {
+	s.activeUsersMutex.Lock()
+	s.lastActivity[username] = time.Now()
+	s.activeUsersMutex.Unlock()
+
+	if s.setAutoAway(username, false) {
+		log.Printf("User %s is active again", username)
+		s.broadcastStatusUpdate(username)
+	}
+}
+
+// Switch a user between online and automatic away, returns whether the presence changed.
+// Presences the user chose themselves are left alone.
+func (s *server) setAutoAway(username string, away bool) bool {
+	s.userStatusMutex.Lock()
+	defer s.userStatusMutex.Unlock()
+
+	p, ok := s.userStatus[username]
+	if !away {
+		if !ok || !p.autoAway {
+			return false
+		}
+		p.presence = presenceOnline
+		p.autoAway = false
+		return true
+	}
+
+	if !ok {
+		p = &userPresence{presence: presenceOnline}
+		s.userStatus[username] = p
+	}
+	if p.presence != presenceOnline {
+		return false
+	}
+	p.presence = presenceAway
+	p.autoAway = true
+	return true
+}
+
+// Check whether a user has an open chat stream
+func (s *server) hasChatStream(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.userStreams[username]) > 0
+}
+
+// Periodically mark idle users away and evict active users that have no chat stream, zero timeouts disable a check
+func (s *server) trackPresence(idleTimeout, ghostTimeout time.Duration) {
+	ticker := time.NewTicker(presenceCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.checkPresence(idleTimeout, ghostTimeout)
+	}
+}
+
+// Run a single pass of the presence tracker
+func (s *server) checkPresence(idleTimeout, ghostTimeout time.Duration) {
+	now := time.Now()
+
+	// Snapshot how long every active user has been quiet
+	s.activeUsersMutex.RLock()
+	quiet := make(map[string]time.Duration, len(s.activeUsers))
+	for user := range s.activeUsers {
+		quiet[user] = now.Sub(s.lastActivity[user])
+	}
+	s.activeUsersMutex.RUnlock()
+
+	for user, since := range quiet {
+		connected := s.hasChatStream(user)
+
+		switch {
+		case !connected && ghostTimeout > 0 && since >= ghostTimeout:
+			if s.evictGhost(user, ghostTimeout) {
+				log.Printf("Evicted %s: no chat stream and quiet for %v", user, since.Round(time.Second))
+			}
+		case connected && idleTimeout > 0 && since >= idleTimeout:
+			if s.setAutoAway(user, true) {
+				log.Printf("User %s idle for %v, marked away", user, since.Round(time.Second))
+				s.broadcastStatusUpdate(user)
+			}
+		}
+	}
+}
+
+// Remove an active user that has no chat stream, unless they came back since the snapshot
+func (s *server) evictGhost(username string, ghostTimeout time.Duration) bool {
+	if s.hasChatStream(username) {
+		return false
+	}
+
+	s.activeUsersMutex.RLock()
+	stillGhost := s.activeUsers[username] && time.Since(s.lastActivity[username]) >= ghostTimeout
+	s.activeUsersMutex.RUnlock()
+	if !stillGhost {
+		return false
+	}
+
+	s.userLeft(username)
+	return true
+}
@@ -2,126 +2,157 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	pb "grpc-chat/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 )
 
 type server struct {
 	pb.UnimplementedChatServiceServer
-	mu           sync.Mutex
-	streams      map[string]pb.ChatService_ChatStreamServer
-	userStreams  map[string]string // Maps username to stream ID
-	messageCache []*pb.ChatMessage // Cache of recent messages
+	mu          sync.Mutex
+	streams     map[string]pb.ChatService_ChatStreamServer
+	userStreams map[string]map[string]bool          // Maps username to the IDs of their open chat streams
+	sessions    map[string]*chatSession             // Maps stream ID to the session using it
+	messages    MessageStore                        // Messages of every room
+	receipts    map[string]map[string]*readPosition // Maps room to username to how far they have read
 
 	// Add tracking for active users and user streams
-	activeUsers       map[string]bool                                   // Track active users by username
-	activeUsersMutex  sync.RWMutex                                      // Mutex for thread-safe access
-	userUpdateStreams map[string]pb.ChatService_ActiveUsersStreamServer // Maps client ID to active users stream
+	activeUsers       map[string]bool                   // Track active users by username
+	lastActivity      map[string]time.Time              // Last time each active user sent something
+	userRecords       map[string]*userRecord            // Join time, last seen and message count of every user seen
+	activeUsersMutex  sync.RWMutex                      // Mutex for thread-safe access, guards lastActivity and userRecords too
+	userUpdateStreams map[string]*activeUsersSubscriber // Maps client ID to active users stream
+	presenceVersion   int64                             // Version of the last presence delta, guarded by s.mu
 	// User status tracking
-	userStatus      map[string]string // Maps username to status
+	userStatus      map[string]*userPresence // Maps username to presence
 	userStatusMutex sync.RWMutex
+	// Chat rooms by name
+	rooms      map[string]*chatRoom
+	roomsMutex sync.RWMutex
+	// Users allowed to moderate every room, read-only after startup
+	moderators map[string]bool
+	// Typing indicators by user and room or conversation
+	typing      map[string]*typingEntry
+	typingMutex sync.Mutex
+	// Profiles by username, saved with the avatars under dataDir
+	profiles      map[string]*userProfile
+	profilesMutex sync.RWMutex
+	dataDir       string
+	// Contacts and open contact requests, saved under dataDir
+	contacts        contactBook
+	contactsMutex   sync.RWMutex
+	contactsOnlyDMs bool // Read-only after startup
+	// Words of every stored room message, for SearchMessages
+	search *searchIndex
+	// Server-wide retention policy, read-only after startup
+	retention *pb.RetentionPolicy
+	// Serializes saving rooms and users so an older snapshot never overwrites a newer one
+	metadataMutex sync.Mutex
 }
 
-// Login hanya menyimpan username
-func (s *server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
-	log.Printf("User login: %s", req.Username)
-
-	// Add user to active users list
-	s.activeUsersMutex.Lock()
-	// Check if user already exists before adding them
-	isNewUser := !s.activeUsers[req.Username]
-	s.activeUsers[req.Username] = true
-	s.activeUsersMutex.Unlock()
-
-	// Always broadcast the full user list after a new login
-	go func() {
-		// Small delay to ensure all initial setup is complete
-		time.Sleep(200 * time.Millisecond)
-		s.broadcastAllActiveUsers()
-	}()
+// An open ActiveUsersStream together with the users it is scoped to
+type activeUsersSubscriber struct {
+	stream      pb.ChatService_ActiveUsersStreamServer
+	username    string
+	scope       pb.ActiveUsersRequest_Scope
+	room        string                     // Set with the room scope only
+	watched     map[string]bool            // Users named with the list scope
+	updates     chan *pb.ActiveUsersUpdate // Deltas waiting to be sent
+	lastVersion int64                      // Version of the last delta queued or dropped, guarded by s.mu
+	dropped     atomic.Bool                // Set when a delta was dropped, the subscriber gets a snapshot once caught up
+}
 
-	// Additionally broadcast the join event if this is a new user
-	if isNewUser {
-		go s.broadcastUserJoin(req.Username)
+// Check whether a subscriber's scope includes a user, caller must hold s.mu
+func (s *server) subscriberScopeHas(sub *activeUsersSubscriber, username string) bool {
+	if username == sub.username {
+		return true
 	}
-
-	return &pb.LoginResponse{
-		Username: req.Username,
-		Message:  "Login sukses",
-	}, nil
+	switch sub.scope {
+	case pb.ActiveUsersRequest_ROOM:
+		return s.isRoomMember(sub.room, username)
+	case pb.ActiveUsersRequest_CONTACTS:
+		return s.areContacts(sub.username, username)
+	case pb.ActiveUsersRequest_LIST:
+		return sub.watched[username]
+	}
+	return true
 }
 
-// New helper function to broadcast the complete active users list to all clients
-func (s *server) broadcastAllActiveUsers() {
-	// Get a snapshot of current active users with proper locking
-	s.activeUsersMutex.RLock()
-	activeUsersList := make([]string, 0, len(s.activeUsers))
-	for user := range s.activeUsers {
-		activeUsersList = append(activeUsersList, user)
-	}
-	s.activeUsersMutex.RUnlock()
-
-	// Get current statuses
-	s.userStatusMutex.RLock()
-	userStatuses := make(map[string]string)
-	for _, user := range activeUsersList {
-		if status, ok := s.userStatus[user]; ok {
-			userStatuses[user] = status
-		} else {
-			userStatuses[user] = "online" // Default status
-		}
+// Check whether a subscriber should hear about a user, caller must hold s.mu.
+// Invisible users are only shown to themselves, users who blocked each other not at all.
+func (s *server) subscriberSees(sub *activeUsersSubscriber, username string) bool {
+	if username != sub.username && s.isInvisible(username) {
+		return false
 	}
-	s.userStatusMutex.RUnlock()
-
-	log.Printf("Broadcasting full active users list to all clients: %v", activeUsersList)
-
-	// Create the update message with statuses
-	update := &pb.ActiveUsersUpdate{
-		UpdateType:   pb.ActiveUsersUpdate_FULL_LIST,
-		Users:        activeUsersList,
-		UserStatuses: userStatuses,
+	if s.blockedBetween(sub.username, username) {
+		return false
 	}
+	return s.subscriberScopeHas(sub, username)
+}
 
-	// Send to all active streams
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for id, userStream := range s.userUpdateStreams {
-		if err := userStream.Send(update); err != nil {
-			log.Printf("Error sending full users list to stream %s: %v", id, err)
-		} else {
-			log.Printf("Successfully sent active users list to stream %s", id)
+// Filter a list of users down to the ones a subscriber should see
+func (s *server) visibleUsers(sub *activeUsersSubscriber, users []string) []string {
+	visible := make([]string, 0, len(users))
+	for _, user := range users {
+		if s.subscriberSees(sub, user) {
+			visible = append(visible, user)
 		}
 	}
+	return visible
+}
+
+// Login hanya menyimpan username
+func (s *server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	log.Printf("User login: %s", req.Username)
+
+	// Users only become active once their chat stream joins, so a login without a stream leaves no ghost behind
+	return &pb.LoginResponse{
+		Username: req.Username,
+		Message:  "Login sukses",
+	}, nil
 }
 
 // Add a function to handle user leaving
 func (s *server) userLeft(username string) {
-	// Create a message to inform all clients this user has left
-	leaveMsg := &pb.ChatMessage{
-		Sender:    username,
-		Message:   "left the chat",
-		Timestamp: time.Now().Format("15:04:05"),
+	// Inform every room the user is a member of that they have left
+	for _, room := range s.userRooms(username) {
+		leaveMsg := &pb.ChatMessage{
+			Sender:    username,
+			Message:   "left the chat",
+			Timestamp: time.Now().Format("15:04:05"),
+			Room:      room,
+		}
+		s.broadcastMessage(leaveMsg)
 	}
 
-	// Broadcast the leave message to all clients
-	s.broadcastMessage(leaveMsg)
+	s.clearTyping(username)
 
 	// Remove from active users
 	s.activeUsersMutex.Lock()
 	delete(s.activeUsers, username)
+	delete(s.lastActivity, username)
 	s.activeUsersMutex.Unlock()
+	s.recordLeft(username)
 
 	// Broadcast that user has left
-	go s.broadcastUserLeave(username)
+	s.broadcastUserLeave(username)
 
 	log.Printf("User %s left the chat", username)
 }
@@ -137,25 +168,22 @@ func (s *server) ChatStream(stream pb.ChatService_ChatStreamServer) error {
 	s.streams[streamID] = stream
 	s.mu.Unlock()
 
-	var currentUser string // Track the username for this stream
-
 	// Clean up on disconnect
 	defer func() {
 		s.mu.Lock()
-		// Find and remove username mapping to this stream
-		for username, sid := range s.userStreams {
-			if sid == streamID {
-				currentUser = username
-				delete(s.userStreams, username)
-				log.Printf("Removed user %s mapping to stream %s", username, streamID)
-			}
-		}
+		// Remove the session using this stream
+		currentUser, remaining, attached := s.detachSession(streamID)
 		delete(s.streams, streamID)
 		s.mu.Unlock()
 
-		// If we have a username, inform other clients this user has left
-		if currentUser != "" {
-			s.userLeft(currentUser)
+		// Only the user's last session leaving makes them leave
+		if attached {
+			log.Printf("Removed user %s session on stream %s, %d sessions left", currentUser, streamID, remaining)
+			if remaining == 0 {
+				s.userLeft(currentUser)
+			} else {
+				s.broadcastSessionChange(currentUser)
+			}
 		}
 
 		log.Printf("Stream disconnected: %s", streamID)
@@ -171,58 +199,237 @@ func (s *server) ChatStream(stream pb.ChatService_ChatStreamServer) error {
 			return err // Some other error
 		}
 
-		// Log received message
-		log.Printf("Received message from %s: %s", msg.Sender, msg.Message)
+		// Messages without a room go to the default room, direct messages have no room
+		if msg.Recipient == "" {
+			msg.Room = normalizeRoom(msg.Room)
+			log.Printf("Received message from %s in %s: %s", msg.Sender, msg.Room, msg.Message)
+		} else {
+			msg.Room = ""
+			log.Printf("Received direct message from %s to %s", msg.Sender, msg.Recipient)
+		}
 
-		// Associate this username with the stream ID
+		// Associate this username with the stream ID, users may have several sessions open
 		s.mu.Lock()
-		// If this username already has a different stream, use the new one
-		if existingStreamID, ok := s.userStreams[msg.Sender]; ok && existingStreamID != streamID {
-			log.Printf("User %s reconnected. Old stream: %s, New stream: %s",
-				msg.Sender, existingStreamID, streamID)
+		if s.attachSession(streamID, msg.Sender, msg.Device) {
+			log.Printf("User %s opened a session on stream %s, %d sessions open",
+				msg.Sender, streamID, len(s.userStreams[msg.Sender]))
+			s.replayDirectMessages(streamID, msg.Sender)
 		}
-		s.userStreams[msg.Sender] = streamID
-
-		// Add message to cache
-		s.messageCache = append(s.messageCache, msg)
-		if len(s.messageCache) > 100 { // Limit cache size
-			s.messageCache = s.messageCache[1:]
+		sessionCount := len(s.userStreams[msg.Sender])
+		// History requests replay stored messages to this stream only. Replaying before s.mu is released
+		// keeps live messages of a newly attached session from overtaking its backlog.
+		if msg.Type == pb.ChatMessage_HISTORY_REQUEST {
+			s.replayHistory(streamID, msg)
 		}
 		s.mu.Unlock()
 
+		// Anything the user sends counts as activity
+		s.touchUser(msg.Sender)
+
+		if msg.Type == pb.ChatMessage_HISTORY_REQUEST {
+			continue
+		}
+
+		// Typing indicators are relayed without touching presence
+		if msg.Type == pb.ChatMessage_TYPING {
+			s.updateTyping(msg)
+			continue
+		}
+
+		// Direct messages only go to the sender and the recipient
+		if msg.Recipient != "" {
+			s.recordMessage(msg.Sender)
+			s.stopTyping(msg)
+			s.sendDirectMessage(streamID, msg)
+			continue
+		}
+
 		// If this is a "joined the chat" message, add user to active users
 		if msg.Message == "joined the chat" {
 			s.activeUsersMutex.Lock()
+			alreadyActive := s.activeUsers[msg.Sender]
 			s.activeUsers[msg.Sender] = true
 			s.activeUsersMutex.Unlock()
 
+			// Another session is already live, only the session list changed
+			if alreadyActive && sessionCount > 1 {
+				s.broadcastSessionChange(msg.Sender)
+				continue
+			}
+			s.recordJoin(msg.Sender)
+
+			// Everyone is a member of the default room
+			if added, _ := s.addRoomMember(defaultRoom, msg.Sender); added {
+				s.saveRoomMember(defaultRoom, msg.Sender)
+				s.broadcastRoomJoin(defaultRoom, msg.Sender)
+			}
+			msg.Room = defaultRoom
+
 			// Broadcast to all active user streams
-			go s.broadcastUserJoin(msg.Sender)
+			s.broadcastUserJoin(msg.Sender)
 		} else if msg.Message == "left the chat" || msg.Message == "left the chat (client shutdown)" {
+			// Closing one of several sessions keeps the user online
+			s.mu.Lock()
+			_, remaining, _ := s.detachSession(streamID)
+			s.mu.Unlock()
+			if remaining > 0 {
+				log.Printf("User %s closed a session, %d sessions left", msg.Sender, remaining)
+				s.broadcastSessionChange(msg.Sender)
+				continue
+			}
+
+			s.clearTyping(msg.Sender)
+
 			// If this is a leave message, remove user from active users
 			s.activeUsersMutex.Lock()
 			delete(s.activeUsers, msg.Sender)
+			delete(s.lastActivity, msg.Sender)
 			s.activeUsersMutex.Unlock()
+			s.recordLeft(msg.Sender)
 
 			// Broadcast to all active user streams
-			go s.broadcastUserLeave(msg.Sender)
+			s.broadcastUserLeave(msg.Sender)
+
+			// Let every room the user is in know they left
+			for _, room := range s.userRooms(msg.Sender) {
+				s.broadcastMessage(&pb.ChatMessage{
+					Sender:    msg.Sender,
+					Message:   msg.Message,
+					Timestamp: msg.Timestamp,
+					Room:      room,
+				})
+			}
+			continue
+		} else if !s.isRoomMember(msg.Room, msg.Sender) {
+			// Only members may post to a room
+			log.Printf("Rejected message from %s to room %s: not a member", msg.Sender, msg.Room)
+			s.sendToStream(streamID, &pb.ChatMessage{
+				Sender:    "System",
+				Message:   fmt.Sprintf("You are not a member of room %s", msg.Room),
+				Timestamp: time.Now().Format("15:04:05"),
+				Room:      msg.Room,
+			})
+			continue
+		} else if msg.ParentId != "" && !s.resolveThreadRoot(msg) {
+			// Replies need a thread root in the same room
+			log.Printf("Rejected reply from %s: thread %s not found in %s", msg.Sender, msg.ParentId, msg.Room)
+			s.sendToStream(streamID, &pb.ChatMessage{
+				Sender:    "System",
+				Message:   fmt.Sprintf("Thread %s not found", msg.ParentId),
+				Timestamp: time.Now().Format("15:04:05"),
+				Room:      msg.Room,
+			})
+			continue
 		}
 
-		// Broadcast to all connected streams
+		// Record who the message mentions before it goes out
+		msg.Mentions = s.parseMentions(msg.Message)
+
+		// Broadcast to all members of the room, join notices don't count as messages
+		if msg.Message != "joined the chat" {
+			s.recordMessage(msg.Sender)
+			s.stopTyping(msg)
+		}
 		s.broadcastMessage(msg)
 	}
 }
 
+// Deliver a direct message to the recipient's stream and echo it to the sender's stream.
+// Direct messages have no room or sequence, a store that keeps metadata saves them once delivered so
+// new sessions of either user get them back.
+func (s *server) sendDirectMessage(senderStreamID string, msg *pb.ChatMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Direct messages get an ID but no sequence since they belong to no room
+	msg.Id = newMessageID()
+	msg.SentAt = time.Now().Unix()
+	msg.SenderDisplayName = s.displayName(msg.Sender)
+
+	// Tell the sender why their message went nowhere
+	notify := func(text string) {
+		senderStream, ok := s.streams[senderStreamID]
+		if !ok {
+			return
+		}
+		notice := &pb.ChatMessage{
+			Sender:    "System",
+			Message:   text,
+			Timestamp: time.Now().Format("15:04:05"),
+			Recipient: msg.Sender,
+		}
+		if err := senderStream.Send(notice); err != nil {
+			log.Printf("Error sending to stream %s: %v", senderStreamID, err)
+		}
+	}
+
+	if s.hasBlocked(msg.Sender, msg.Recipient) {
+		log.Printf("Direct message from %s dropped: they blocked %s", msg.Sender, msg.Recipient)
+		notify(fmt.Sprintf("You blocked %s, unblock them to send direct messages", msg.Recipient))
+		return
+	}
+	if s.hasBlocked(msg.Recipient, msg.Sender) {
+		log.Printf("Direct message from %s dropped: %s blocked them", msg.Sender, msg.Recipient)
+		notify(fmt.Sprintf("User %s is not accepting your direct messages", msg.Recipient))
+		return
+	}
+
+	if s.contactsOnlyDMs && msg.Sender != msg.Recipient && !s.areContacts(msg.Sender, msg.Recipient) {
+		log.Printf("Direct message from %s dropped: %s is not a contact", msg.Sender, msg.Recipient)
+		notify(fmt.Sprintf("You can only send direct messages to your contacts, %s is not one", msg.Recipient))
+		return
+	}
+
+	if len(s.userStreams[msg.Recipient]) == 0 {
+		log.Printf("Direct message from %s dropped: %s is not connected", msg.Sender, msg.Recipient)
+		notify(fmt.Sprintf("User %s is not online", msg.Recipient))
+		return
+	}
+
+	// Every session of the recipient and the sender gets a copy, messages to yourself only once
+	targets := []string{msg.Recipient}
+	if msg.Sender != msg.Recipient {
+		targets = append(targets, msg.Sender)
+	}
+	sent := s.sendToMembers(targets, msg)
+	s.saveDirectMessage(msg)
+
+	log.Printf("Delivered direct message from %s to %s on %d streams", msg.Sender, msg.Recipient, sent)
+}
+
+// Send a message to a single chat stream
+func (s *server) sendToStream(streamID string, msg *pb.ChatMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if clientStream, ok := s.streams[streamID]; ok {
+		if err := clientStream.Send(msg); err != nil {
+			log.Printf("Error sending to stream %s: %v", streamID, err)
+		}
+	}
+}
+
 // New method for streaming active users
 func (s *server) ActiveUsersStream(req *pb.ActiveUsersRequest, stream pb.ChatService_ActiveUsersStreamServer) error {
 	// Generate a unique ID for this stream
 	streamID := fmt.Sprintf("active_%p", stream)
 	log.Printf("New active users stream connected for user %s: %s", req.Username, streamID)
 
-	// Register this stream
+	sub, err := s.newPresenceSubscriber(req)
+	if err != nil {
+		return err
+	}
+	sub.stream = stream
+	sub.updates = make(chan *pb.ActiveUsersUpdate, presenceQueueSize)
+
+	// Register this stream and queue the initial full list, both under s.mu so no delta slips in between
 	s.mu.Lock()
-	s.userUpdateStreams[streamID] = stream
+	snapshot := s.presenceSnapshot(sub)
+	sub.lastVersion = snapshot.Version
+	sub.updates <- snapshot
+	s.userUpdateStreams[streamID] = sub
 	s.mu.Unlock()
+	log.Printf("Sending initial active users list to %s at version %d: %v", req.Username, snapshot.Version, snapshot.Users)
 
 	// Clean up on disconnect
 	defer func() {
@@ -232,86 +439,118 @@ func (s *server) ActiveUsersStream(req *pb.ActiveUsersRequest, stream pb.ChatSer
 		log.Printf("Active users stream disconnected: %s", streamID)
 	}()
 
-	// Get the current list of active users with proper locking
-	s.activeUsersMutex.RLock()
-	activeUsersList := make([]string, 0, len(s.activeUsers))
-	for user := range s.activeUsers {
-		activeUsersList = append(activeUsersList, user)
-	}
-	s.activeUsersMutex.RUnlock()
-
-	log.Printf("Sending initial active users list to %s: %v", req.Username, activeUsersList)
-
-	// Send initial full list
-	err := stream.Send(&pb.ActiveUsersUpdate{
-		UpdateType: pb.ActiveUsersUpdate_FULL_LIST,
-		Users:      activeUsersList,
-	})
-
-	if err != nil {
-		log.Printf("Error sending initial active users list: %v", err)
-		return err
+	// Send queued deltas until the client disconnects
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case update := <-sub.updates:
+			if err := stream.Send(update); err != nil {
+				log.Printf("Error sending active users update to stream %s: %v", streamID, err)
+				return err
+			}
+			if len(sub.updates) == 0 && sub.dropped.Load() {
+				s.resyncPresence(streamID, sub)
+			}
+		}
 	}
-
-	// Keep connection open until client disconnects
-	<-stream.Context().Done()
-	return nil
 }
 
-// Modified to ensure all clients get a full user list after any change
+// Tell subscribers that a user joined the chat
 func (s *server) broadcastUserJoin(username string) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// First send the JOIN notification
-	update := &pb.ActiveUsersUpdate{
-		UpdateType: pb.ActiveUsersUpdate_JOIN,
-		Username:   username,
-	}
-
-	for id, userStream := range s.userUpdateStreams {
-		if err := userStream.Send(update); err != nil {
-			log.Printf("Error sending user join update to stream %s: %v", id, err)
-		}
+	// A leave that overtook this join already told everyone the user is gone
+	if !s.isActive(username) {
+		return
 	}
-	s.mu.Unlock()
-
-	// Then broadcast the full list to ensure all clients are in sync
-	go s.broadcastAllActiveUsers()
+	s.publishPresence(s.userDelta(pb.ActiveUsersUpdate_JOIN, username))
 }
 
-// Modified to ensure all clients get a full user list after any change
+// Tell subscribers that a user left the chat
 func (s *server) broadcastUserLeave(username string) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// First send the LEAVE notification
-	update := &pb.ActiveUsersUpdate{
+	// The user rejoined before this leave went out
+	if s.isActive(username) {
+		return
+	}
+	s.publishPresence(&pb.ActiveUsersUpdate{
 		UpdateType: pb.ActiveUsersUpdate_LEAVE,
 		Username:   username,
-	}
+	})
+}
 
-	for id, userStream := range s.userUpdateStreams {
-		if err := userStream.Send(update); err != nil {
-			log.Printf("Error sending user leave update to stream %s: %v", id, err)
-		}
+// Generate a random unique message ID
+func newMessageID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to the clock if the random source fails
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
 	}
-	s.mu.Unlock()
+	return hex.EncodeToString(b)
+}
 
-	// Then broadcast the full list to ensure all clients are in sync
-	go s.broadcastAllActiveUsers()
+// Assign the ID and next room sequence number to a message, caller must hold s.mu
+func (s *server) stampMessage(msg *pb.ChatMessage) {
+	now := time.Now()
+	msg.Id = newMessageID()
+	msg.Sequence = s.nextSequence(msg.Room)
+	msg.SentAt = now.Unix()
+	if msg.Timestamp == "" {
+		msg.Timestamp = now.Format("15:04:05")
+	}
 }
 
-// Helper function to broadcast message to all streams
+// Helper function to broadcast message to the streams of every member of its room
 func (s *server) broadcastMessage(msg *pb.ChatMessage) {
+	members := s.roomMembers(msg.Room)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	log.Printf("Broadcasting message from %s to %d clients", msg.Sender, len(s.streams))
+	// Stamp while holding s.mu so messages go out in sequence order
+	s.stampMessage(msg)
+	msg.SenderDisplayName = s.displayName(msg.Sender)
+	// A message the store couldn't keep still goes out live
+	if err := s.messages.Append(msg); err != nil {
+		log.Printf("Error storing message %s in room %s: %v", msg.Id, msg.Room, err)
+	} else {
+		s.search.add(msg)
+	}
 
-	for id, clientStream := range s.streams {
-		if err := clientStream.Send(msg); err != nil {
-			log.Printf("Error sending to stream %s: %v", id, err)
+	// Members who blocked the sender never see the message
+	sent := s.sendToMembers(s.withoutBlockers(members, msg.Sender), msg)
+
+	// Replies also update the reply count of their thread root
+	if msg.ParentId != "" {
+		s.updateReplyCount(msg.Room, msg.ParentId, 1, members)
+	}
+
+	s.sendMentions(msg, msg.Mentions)
+
+	log.Printf("Broadcast message %s (#%d) from %s to %d clients in room %s", msg.Id, msg.Sequence, msg.Sender, sent, msg.Room)
+}
+
+// Send a message to every chat stream of the given users, caller must hold s.mu
+func (s *server) sendToMembers(members []string, msg *pb.ChatMessage) int {
+	sent := 0
+	for _, member := range members {
+		for id := range s.userStreams[member] {
+			clientStream, ok := s.streams[id]
+			if !ok {
+				continue
+			}
+			if err := clientStream.Send(msg); err != nil {
+				log.Printf("Error sending to stream %s: %v", id, err)
+				continue
+			}
+			sent++
 		}
 	}
+	return sent
 }
 
 // Implement UpdateStatus method for client streaming
@@ -334,22 +573,26 @@ func (s *server) UpdateStatus(stream pb.ChatService_UpdateStatusServer) error {
 		status := statusUpdate.Status
 		timestamp := statusUpdate.Timestamp
 
+		log.Printf("Status update from %s: %s at %s", username, status, timestamp)
+
+		// Validate and update the user's status
+		changed, err := s.applyStatusUpdate(statusUpdate)
+		if err != nil {
+			log.Printf("Rejected status update from %s: %v", username, err)
+			return err
+		}
+
 		// Store most recent values to use in response
 		lastUsername = username
 		lastStatus = status
 		updated = true
 
-		log.Printf("Status update from %s: %s at %s", username, status, timestamp)
-
-		// Update the user's status
-		s.userStatusMutex.Lock()
-		previousStatus, exists := s.userStatus[username]
-		s.userStatus[username] = status
-		s.userStatusMutex.Unlock()
+		s.touchUser(username)
 
 		// Only broadcast if status changed
-		if !exists || previousStatus != status {
-			go s.broadcastStatusUpdate(username, status)
+		if changed {
+			s.saveUser(username)
+			s.broadcastStatusUpdate(username)
 		}
 	}
 
@@ -367,39 +610,34 @@ func (s *server) UpdateStatus(stream pb.ChatService_UpdateStatusServer) error {
 	})
 }
 
-// Add new function to broadcast status updates
-func (s *server) broadcastStatusUpdate(username string, status string) {
-	// Get current active users and their statuses
-	s.activeUsersMutex.RLock()
-	s.userStatusMutex.RLock()
+// Tell subscribers that a user's status changed
+func (s *server) broadcastStatusUpdate(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	userStatuses := make(map[string]string)
-	for user := range s.activeUsers {
-		if userStatus, ok := s.userStatus[user]; ok {
-			userStatuses[user] = userStatus
-		} else {
-			userStatuses[user] = "online" // Default status
-		}
+	if !s.isActive(username) {
+		return
 	}
 
-	s.userStatusMutex.RUnlock()
-	s.activeUsersMutex.RUnlock()
+	update := s.userDelta(pb.ActiveUsersUpdate_STATUS_CHANGE, username)
+	update.Version = s.nextPresenceVersion()
 
-	// Create status update message
-	update := &pb.ActiveUsersUpdate{
-		UpdateType:   pb.ActiveUsersUpdate_STATUS_CHANGE,
-		Username:     username,
-		UserStatuses: userStatuses,
+	// Going invisible looks like leaving to everyone else
+	leave := &pb.ActiveUsersUpdate{
+		UpdateType: pb.ActiveUsersUpdate_LEAVE,
+		Username:   username,
+		Version:    update.Version,
 	}
 
-	// Send to all streams
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for id, userStream := range s.userUpdateStreams {
-		if err := userStream.Send(update); err != nil {
-			log.Printf("Error sending status update to stream %s: %v", id, err)
+	for id, sub := range s.userUpdateStreams {
+		if !s.subscriberScopeHas(sub, username) || s.blockedBetween(sub.username, username) {
+			continue
+		}
+		if !s.subscriberSees(sub, username) {
+			s.deliverPresence(id, sub, leave)
+			continue
 		}
+		s.deliverPresence(id, sub, update)
 	}
 }
 
@@ -408,11 +646,82 @@ func main() {
 	// Create and configure server
 	s := &server{
 		streams:           make(map[string]pb.ChatService_ChatStreamServer),
-		userStreams:       make(map[string]string),
-		messageCache:      make([]*pb.ChatMessage, 0, 100),
+		userStreams:       make(map[string]map[string]bool),
+		sessions:          make(map[string]*chatSession),
+		typing:            make(map[string]*typingEntry),
 		activeUsers:       make(map[string]bool),
-		userUpdateStreams: make(map[string]pb.ChatService_ActiveUsersStreamServer),
-		userStatus:        make(map[string]string),
+		lastActivity:      make(map[string]time.Time),
+		userRecords:       make(map[string]*userRecord),
+		userUpdateStreams: make(map[string]*activeUsersSubscriber),
+		userStatus:        make(map[string]*userPresence),
+		rooms:             make(map[string]*chatRoom),
+		moderators:        make(map[string]bool),
+		receipts:          make(map[string]map[string]*readPosition),
+		profiles:          make(map[string]*userProfile),
+		search:            newSearchIndex(),
+	}
+
+	// Moderators may edit and delete anyone's messages
+	moderators := flag.String("moderators", "", "Comma separated usernames allowed to edit and delete any message")
+	// Presence tracking, zero disables a check
+	idleTimeout := flag.Duration("idle-timeout", defaultIdleTimeout, "Inactivity after which a connected user is shown as away")
+	ghostTimeout := flag.Duration("ghost-timeout", defaultGhostTimeout, "Time after which an active user without a chat stream is removed")
+	flag.StringVar(&s.dataDir, "data-dir", "data", "Directory where profiles, avatars, contacts and messages are saved")
+	flag.BoolVar(&s.contactsOnlyDMs, "contacts-only-dms", false, "Only allow direct messages between contacts")
+	storeSpec := flag.String("store", "file", "Where messages are kept: memory, or file, sqlite or wal with an optional :path, sqlite and wal keep rooms and users too")
+	// Retention, rooms may set their own policy within the maximum age
+	retentionMaxAge := flag.Duration("retention-max-age", defaultRetentionMaxAge, "Messages older than this are deleted from every room, zero only deletes what room policies ask for")
+	retentionMaxMessages := flag.Int("retention-max-messages", 0, "Messages kept per room without a policy of its own, zero keeps any number")
+	compactInterval := flag.Duration("compact-interval", defaultCompactInterval, "How often retention policies are enforced, zero disables it")
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve metrics on at /debug/vars, empty disables it")
+	flag.Parse()
+	for _, name := range strings.Split(*moderators, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			s.moderators[name] = true
+		}
+	}
+	if *retentionMaxAge < 0 || *retentionMaxMessages < 0 {
+		log.Fatalf("Retention limits can't be negative")
+	}
+	s.retention = &pb.RetentionPolicy{
+		MaxAgeSeconds: int64(*retentionMaxAge / time.Second),
+		MaxMessages:   int32(*retentionMaxMessages),
+	}
+
+	if err := s.loadProfiles(); err != nil {
+		log.Fatalf("Failed to load profiles: %v", err)
+	}
+	if err := s.loadContacts(); err != nil {
+		log.Fatalf("Failed to load contacts: %v", err)
+	}
+	messages, err := openStore(*storeSpec, s.dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open message store: %v", err)
+	}
+	s.messages = messages
+	s.followTrims()
+	if err := s.loadMetadata(); err != nil {
+		log.Fatalf("Failed to load rooms and users: %v", err)
+	}
+	s.ensureRoom(defaultRoom, "Default room for everyone", "System")
+	s.saveRoom(defaultRoom)
+	if err := s.indexStoredMessages(); err != nil {
+		log.Fatalf("Failed to index messages for search: %v", err)
+	}
+
+	go s.trackPresence(*idleTimeout, *ghostTimeout)
+	if *compactInterval > 0 {
+		go s.runCompactor(*compactInterval)
+	}
+
+	// The expvar package registers /debug/vars on the default mux
+	if *metricsAddr != "" {
+		go func() {
+			log.Printf("Serving metrics on http://%s/debug/vars", *metricsAddr)
+			if err := http.ListenAndServe(*metricsAddr, nil); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
 	}
 
 	// Set up gRPC server
@@ -425,6 +734,11 @@ func main() {
 	opts := []grpc.ServerOption{
 		grpc.MaxConcurrentStreams(100),
 		grpc.ConnectionTimeout(30 * time.Second),
+		// Ping idle connections so streams of a crashed gateway are closed instead of lingering
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    30 * time.Second,
+			Timeout: 10 * time.Second,
+		}),
 	}
 	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterChatServiceServer(grpcServer, s)
@@ -432,7 +746,19 @@ func main() {
 	log.Println("gRPC Server running on port 50051")
 	log.Println("Ready to handle chat connections")
 
+	// Stop on Ctrl+C or a termination signal so the message store is closed cleanly
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		log.Println("Shutting down")
+		grpcServer.Stop()
+	}()
+
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
+	if err := s.messages.Close(); err != nil {
+		log.Printf("Error closing message store: %v", err)
+	}
 }
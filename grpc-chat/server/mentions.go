@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Matches @username tokens, the name stops at whitespace and punctuation other than _ . -
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// Check whether a username belongs to a user the server knows about
+func (s *server) isKnownUser(username string) bool {
+	s.activeUsersMutex.RLock()
+	active := s.activeUsers[username]
+	s.activeUsersMutex.RUnlock()
+
+	return active || len(s.userRooms(username)) > 0
+}
+
+// Find the known users mentioned in a message text, each listed once in order of appearance
+func (s *server) parseMentions(text string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Trailing dots and dashes are punctuation, not part of the name
+		name := strings.TrimRight(match[1], ".-")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if s.isKnownUser(name) {
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// Send a mention event for a message to each of the given users except its author, caller must hold s.mu.
+// Users who aren't members of the message's room don't get to read it through a mention.
+func (s *server) sendMentions(msg *pb.ChatMessage, users []string) {
+	targets := make([]string, 0, len(users))
+	for _, user := range s.withoutBlockers(users, msg.Sender) {
+		if user != msg.Sender && s.isRoomMember(msg.Room, user) {
+			targets = append(targets, user)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	event := proto.Clone(msg).(*pb.ChatMessage)
+	event.Type = pb.ChatMessage_MENTION
+	event.Reactions = nil
+
+	// Mentions reach the user even when they are reading another room
+	sent := s.sendToMembers(targets, event)
+	log.Printf("Notified %d of %d users mentioned in message %s", sent, len(targets), msg.Id)
+}
+
+// Get the users in mentions that are not in previous
+func newMentions(previous, mentions []string) []string {
+	known := make(map[string]bool, len(previous))
+	for _, user := range previous {
+		known[user] = true
+	}
+
+	var added []string
+	for _, user := range mentions {
+		if !known[user] {
+			added = append(added, user)
+		}
+	}
+	return added
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Get the message store as a MetadataStore, false when it only keeps messages
+func (s *server) metadataStore() (MetadataStore, bool) {
+	meta, ok := s.messages.(MetadataStore)
+	return meta, ok
+}
+
+// Restore rooms, users and read positions saved by the message store, call before serving
+func (s *server) loadMetadata() error {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return nil
+	}
+
+	rooms, err := meta.LoadRooms()
+	if err != nil {
+		return err
+	}
+	users, err := meta.LoadUsers()
+	if err != nil {
+		return err
+	}
+	positions, err := meta.LoadReadPositions()
+	if err != nil {
+		return err
+	}
+
+	s.roomsMutex.Lock()
+	for _, record := range rooms {
+		room := &chatRoom{
+			name:        record.Name,
+			description: record.Description,
+			createdBy:   record.CreatedBy,
+			createdAt:   record.CreatedAt,
+			members:     make(map[string]bool, len(record.Members)),
+			retention:   record.Retention,
+		}
+		for _, member := range record.Members {
+			room.members[member] = true
+		}
+		s.rooms[record.Name] = room
+	}
+	s.roomsMutex.Unlock()
+
+	now := time.Now().Unix()
+	s.activeUsersMutex.Lock()
+	s.userStatusMutex.Lock()
+	for _, record := range users {
+		s.userRecords[record.Username] = &userRecord{
+			joinedAt:     record.JoinedAt,
+			lastSeen:     record.LastSeen,
+			messageCount: record.MessageCount,
+		}
+
+		custom := record.Custom
+		if custom != nil && custom.ExpiresAt != 0 && custom.ExpiresAt <= now {
+			custom = nil
+		}
+		if record.Presence == "" && custom == nil {
+			continue
+		}
+		p := &userPresence{presence: record.Presence, custom: custom}
+		if !validPresences[p.presence] {
+			p.presence = presenceOnline
+		}
+		s.userStatus[record.Username] = p
+		if custom != nil && custom.ExpiresAt != 0 {
+			s.scheduleCustomStatusExpiry(record.Username, custom)
+		}
+	}
+	s.userStatusMutex.Unlock()
+	s.activeUsersMutex.Unlock()
+
+	s.mu.Lock()
+	for _, record := range positions {
+		pos := s.readPositionFor(record.Room, record.Username)
+		pos.delivered = record.Delivered
+		pos.read = record.Read
+	}
+	s.mu.Unlock()
+
+	log.Printf("Loaded %d rooms, %d users and %d read positions from the message store", len(rooms), len(users), len(positions))
+	return nil
+}
+
+// Save a room's details when the message store keeps rooms, failures are only logged
+func (s *server) saveRoom(name string) {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return
+	}
+	s.metadataMutex.Lock()
+	defer s.metadataMutex.Unlock()
+
+	s.roomsMutex.RLock()
+	room, exists := s.rooms[name]
+	var record RoomRecord
+	if exists {
+		record = RoomRecord{
+			Name:        room.name,
+			Description: room.description,
+			CreatedBy:   room.createdBy,
+			CreatedAt:   room.createdAt,
+			Retention:   room.retention,
+		}
+	}
+	s.roomsMutex.RUnlock()
+
+	if !exists {
+		return
+	}
+	if err := meta.SaveRoom(record); err != nil {
+		log.Printf("Failed to save room %s: %v", name, err)
+	}
+}
+
+// Save whether a user is currently a member of a room, failures are only logged
+func (s *server) saveRoomMember(name, username string) {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return
+	}
+	s.metadataMutex.Lock()
+	defer s.metadataMutex.Unlock()
+
+	member := s.isRoomMember(name, username)
+	if err := meta.SaveRoomMember(name, username, member); err != nil {
+		log.Printf("Failed to save membership of %s in room %s: %v", username, name, err)
+	}
+}
+
+// Save a user's record and chosen presence, failures are only logged
+func (s *server) saveUser(username string) {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return
+	}
+	s.metadataMutex.Lock()
+	defer s.metadataMutex.Unlock()
+
+	record := UserRecord{Username: username}
+	s.activeUsersMutex.RLock()
+	if r, ok := s.userRecords[username]; ok {
+		record.JoinedAt = r.joinedAt
+		record.LastSeen = r.lastSeen
+		record.MessageCount = r.messageCount
+	}
+	s.activeUsersMutex.RUnlock()
+
+	s.userStatusMutex.RLock()
+	if p, ok := s.userStatus[username]; ok {
+		record.Presence = p.presence
+		// Away for inactivity isn't a choice the user made
+		if p.autoAway {
+			record.Presence = presenceOnline
+		}
+		record.Custom = p.custom
+	}
+	s.userStatusMutex.RUnlock()
+
+	if err := meta.SaveUser(record); err != nil {
+		log.Printf("Failed to save user %s: %v", username, err)
+	}
+}
+
+// Save how far a user has received and read a room, failures are only logged.
+// The caller must hold s.mu, so positions are saved in the order they moved.
+func (s *server) saveReadPosition(room, username string, pos *readPosition) {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return
+	}
+	record := ReadPositionRecord{Room: room, Username: username, Delivered: pos.delivered, Read: pos.read}
+	if err := meta.SaveReadPosition(record); err != nil {
+		log.Printf("Failed to save the read position of %s in room %s: %v", username, room, err)
+	}
+}
+
+// Save a delivered direct message when the message store keeps them, failures are only logged
+func (s *server) saveDirectMessage(msg *pb.ChatMessage) {
+	meta, ok := s.metadataStore()
+	if !ok {
+		return
+	}
+	if err := meta.SaveDirectMessage(msg); err != nil {
+		log.Printf("Failed to save direct message %s from %s to %s: %v", msg.Id, msg.Sender, msg.Recipient, err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Presence states a user can choose
+const (
+	presenceOnline       = "online"
+	presenceAway         = "away"
+	presenceBusy         = "busy"
+	presenceDoNotDisturb = "dnd"
+	presenceInvisible    = "invisible" // Shown as offline to everyone else
+)
+
+var validPresences = map[string]bool{
+	presenceOnline:       true,
+	presenceAway:         true,
+	presenceBusy:         true,
+	presenceDoNotDisturb: true,
+	presenceInvisible:    true,
+}
+
+// Longest custom status text accepted
+const maxCustomStatusRunes = 100
+
+// The presence a user chose and their custom status
+type userPresence struct {
+	presence string
+	autoAway bool             // Set while the server shows the user as away for inactivity
+	custom   *pb.CustomStatus // Nil without a custom status
+}
+
+// Check a custom status for an update, nil and empty ones are always valid
+func validateCustomStatus(custom *pb.CustomStatus) error {
+	if custom == nil {
+		return nil
+	}
+	if utf8.RuneCountInString(custom.Text) > maxCustomStatusRunes {
+		return status.Errorf(codes.InvalidArgument, "custom status text is longer than %d characters", maxCustomStatusRunes)
+	}
+	if utf8.RuneCountInString(custom.Emoji) > maxEmojiRunes || strings.ContainsAny(custom.Emoji, " \t\n") {
+		return status.Errorf(codes.InvalidArgument, "invalid custom status emoji %q", custom.Emoji)
+	}
+	if custom.ExpiresAt != 0 && custom.ExpiresAt <= time.Now().Unix() {
+		return status.Error(codes.InvalidArgument, "custom status expiry must be in the future")
+	}
+	return nil
+}
+
+// Apply a validated status update, returns whether what other users see changed
+func (s *server) applyStatusUpdate(update *pb.StatusUpdate) (bool, error) {
+	if update.Username == "" {
+		return false, status.Error(codes.InvalidArgument, "username is required")
+	}
+	if update.Status != "" && !validPresences[update.Status] {
+		return false, status.Errorf(codes.InvalidArgument, "invalid status %q, must be online, away, busy, dnd or invisible", update.Status)
+	}
+	custom := update.Custom
+	if err := validateCustomStatus(custom); err != nil {
+		return false, err
+	}
+	if custom != nil {
+		custom = &pb.CustomStatus{
+			Text:      strings.TrimSpace(custom.Text),
+			Emoji:     strings.TrimSpace(custom.Emoji),
+			ExpiresAt: custom.ExpiresAt,
+		}
+		if custom.Text == "" && custom.Emoji == "" {
+			custom = nil
+		}
+	}
+
+	s.userStatusMutex.Lock()
+	defer s.userStatusMutex.Unlock()
+
+	p, ok := s.userStatus[update.Username]
+	if !ok {
+		p = &userPresence{presence: presenceOnline}
+		s.userStatus[update.Username] = p
+	}
+	previousStatus, previousCustom := p.presence, p.custom
+
+	// An empty status only changes the custom status
+	if update.Status != "" {
+		p.presence = update.Status
+		p.autoAway = false
+	}
+
+	if update.Custom != nil {
+		p.custom = custom
+		if custom != nil && custom.ExpiresAt != 0 {
+			s.scheduleCustomStatusExpiry(update.Username, custom)
+		}
+	}
+
+	return previousStatus != p.presence || !proto.Equal(previousCustom, p.custom), nil
+}
+
+// Clear a custom status when it expires, unless it was replaced in the meantime
+func (s *server) scheduleCustomStatusExpiry(username string, custom *pb.CustomStatus) {
+	time.AfterFunc(time.Until(time.Unix(custom.ExpiresAt, 0)), func() {
+		s.userStatusMutex.Lock()
+		p, ok := s.userStatus[username]
+		expired := ok && p.custom == custom
+		if expired {
+			p.custom = nil
+		}
+		s.userStatusMutex.Unlock()
+
+		if expired {
+			log.Printf("Custom status of %s expired", username)
+			s.broadcastStatusUpdate(username)
+		}
+	})
+}
+
+// Check whether a user chose to appear offline
+func (s *server) isInvisible(username string) bool {
+	s.userStatusMutex.RLock()
+	defer s.userStatusMutex.RUnlock()
+
+	p, ok := s.userStatus[username]
+	return ok && p.presence == presenceInvisible
+}
+
+// Get the displayed and custom statuses of the given users, users without a status are online
+func (s *server) statusSnapshot(users []string) (map[string]string, map[string]*pb.CustomStatus) {
+	s.userStatusMutex.RLock()
+	defer s.userStatusMutex.RUnlock()
+
+	now := time.Now().Unix()
+	statuses := make(map[string]string, len(users))
+	customs := make(map[string]*pb.CustomStatus)
+	for _, user := range users {
+		p, ok := s.userStatus[user]
+		if !ok {
+			statuses[user] = presenceOnline
+			continue
+		}
+		statuses[user] = p.presence
+		if p.custom != nil && (p.custom.ExpiresAt == 0 || p.custom.ExpiresAt > now) {
+			customs[user] = p.custom
+		}
+	}
+	return statuses, customs
+}
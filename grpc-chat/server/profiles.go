@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxDisplayNameRunes = 50
+	maxBioRunes         = 500
+	maxAvatarBytes      = 1 << 20
+	avatarChunkSize     = 32 * 1024
+	profilesFile        = "profiles.json"
+	avatarsDir          = "avatars"
+)
+
+// Avatar formats browsers can show, detected from the uploaded bytes
+var avatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Profile details a user chose, saved to the data directory
+type userProfile struct {
+	DisplayName     string `json:"display_name,omitempty"`
+	Bio             string `json:"bio,omitempty"`
+	AvatarType      string `json:"avatar_type,omitempty"`
+	AvatarUpdatedAt int64  `json:"avatar_updated_at,omitempty"`
+}
+
+// Read the saved profiles, a missing file means no profiles yet
+func (s *server) loadProfiles() error {
+	data, err := os.ReadFile(filepath.Join(s.dataDir, profilesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.profilesMutex.Lock()
+	defer s.profilesMutex.Unlock()
+	return json.Unmarshal(data, &s.profiles)
+}
+
+// Write every profile to disk, caller must hold profilesMutex.
+// The file is replaced in one rename so a crash never leaves it half written.
+func (s *server) saveProfiles() error {
+	data, err := json.MarshalIndent(s.profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dataDir, profilesFile), data)
+}
+
+// Write a file through a temporary file in the same directory and rename it into place
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Usernames are free text, so avatar files are named after their hex encoding
+func (s *server) avatarPath(username string) string {
+	return filepath.Join(s.dataDir, avatarsDir, hex.EncodeToString([]byte(username)))
+}
+
+// Get the display name of a user, falling back to their username
+func (s *server) displayName(username string) string {
+	s.profilesMutex.RLock()
+	defer s.profilesMutex.RUnlock()
+
+	if p, ok := s.profiles[username]; ok && p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return username
+}
+
+// Get the display names and avatar versions of the given users
+func (s *server) profileSnapshot(users []string) (map[string]string, map[string]int64) {
+	s.profilesMutex.RLock()
+	defer s.profilesMutex.RUnlock()
+
+	names := make(map[string]string, len(users))
+	avatars := make(map[string]int64)
+	for _, user := range users {
+		names[user] = user
+		p, ok := s.profiles[user]
+		if !ok {
+			continue
+		}
+		if p.DisplayName != "" {
+			names[user] = p.DisplayName
+		}
+		if p.AvatarType != "" {
+			avatars[user] = p.AvatarUpdatedAt
+		}
+	}
+	return names, avatars
+}
+
+// Convert the profile of a user into its protobuf representation
+func (s *server) profileInfo(username string) *pb.Profile {
+	s.profilesMutex.RLock()
+	defer s.profilesMutex.RUnlock()
+
+	info := &pb.Profile{Username: username, DisplayName: username}
+	if p, ok := s.profiles[username]; ok {
+		if p.DisplayName != "" {
+			info.DisplayName = p.DisplayName
+		}
+		info.Bio = p.Bio
+		info.AvatarType = p.AvatarType
+		info.AvatarUpdatedAt = p.AvatarUpdatedAt
+	}
+	return info
+}
+
+// Check that profile text fits its limit and has no control characters other than the allowed ones
+func validateProfileText(field, text string, maxRunes int, allowNewlines bool) error {
+	if !utf8.ValidString(text) {
+		return status.Errorf(codes.InvalidArgument, "%s must be valid UTF-8", field)
+	}
+	if n := utf8.RuneCountInString(text); n > maxRunes {
+		return status.Errorf(codes.InvalidArgument, "%s is %d characters, the limit is %d", field, n, maxRunes)
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) && !(allowNewlines && r == '\n') {
+			return status.Errorf(codes.InvalidArgument, "%s contains control characters", field)
+		}
+	}
+	return nil
+}
+
+// Check whether the server knows anything about a user
+func (s *server) hasProfile(username string) bool {
+	s.activeUsersMutex.RLock()
+	_, known := s.userRecords[username]
+	s.activeUsersMutex.RUnlock()
+	if known {
+		return true
+	}
+
+	s.profilesMutex.RLock()
+	defer s.profilesMutex.RUnlock()
+	_, known = s.profiles[username]
+	return known
+}
+
+// GetProfile returns the display name, bio and avatar details of a user
+func (s *server) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.Profile, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	if !s.hasProfile(req.Username) {
+		return nil, status.Errorf(codes.NotFound, "user %s has never joined", req.Username)
+	}
+	return s.profileInfo(req.Username), nil
+}
+
+// UpdateProfile changes the display name or bio of a user, fields that aren't set are left alone
+func (s *server) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.Profile, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	var displayName, bio string
+	if req.DisplayName != nil {
+		displayName = strings.TrimSpace(*req.DisplayName)
+		if err := validateProfileText("display name", displayName, maxDisplayNameRunes, false); err != nil {
+			return nil, err
+		}
+	}
+	if req.Bio != nil {
+		bio = strings.TrimSpace(*req.Bio)
+		if err := validateProfileText("bio", bio, maxBioRunes, true); err != nil {
+			return nil, err
+		}
+	}
+
+	s.profilesMutex.Lock()
+	p, ok := s.profiles[req.Username]
+	if !ok {
+		p = &userProfile{}
+		s.profiles[req.Username] = p
+	}
+	nameChanged := req.DisplayName != nil && displayName != p.DisplayName
+	if req.DisplayName != nil {
+		p.DisplayName = displayName
+	}
+	if req.Bio != nil {
+		p.Bio = bio
+	}
+	err := s.saveProfiles()
+	s.profilesMutex.Unlock()
+
+	if err != nil {
+		log.Printf("Error saving profiles: %v", err)
+		return nil, status.Error(codes.Internal, "failed to save profile")
+	}
+
+	log.Printf("User %s updated their profile", req.Username)
+	if nameChanged {
+		s.broadcastProfileChange(req.Username)
+	}
+	return s.profileInfo(req.Username), nil
+}
+
+// UploadAvatar stores the image streamed by a client as the avatar of the user named in the first chunk
+func (s *server) UploadAvatar(stream pb.ChatService_UploadAvatarServer) error {
+	var username string
+	var image bytes.Buffer
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if username == "" {
+			if chunk.Username == "" {
+				return status.Error(codes.InvalidArgument, "the first chunk must name the user")
+			}
+			username = chunk.Username
+		}
+		if image.Len()+len(chunk.Data) > maxAvatarBytes {
+			return status.Errorf(codes.InvalidArgument, "avatar is larger than %d bytes", maxAvatarBytes)
+		}
+		image.Write(chunk.Data)
+	}
+
+	if username == "" || image.Len() == 0 {
+		return status.Error(codes.InvalidArgument, "avatar is empty")
+	}
+
+	// Trust the bytes rather than anything the client claims
+	contentType := http.DetectContentType(image.Bytes())
+	if !avatarTypes[contentType] {
+		return status.Errorf(codes.InvalidArgument, "unsupported avatar type %s", contentType)
+	}
+
+	s.profilesMutex.Lock()
+	err := writeFileAtomic(s.avatarPath(username), image.Bytes())
+	if err == nil {
+		p, ok := s.profiles[username]
+		if !ok {
+			p = &userProfile{}
+			s.profiles[username] = p
+		}
+		p.AvatarType = contentType
+		p.AvatarUpdatedAt = time.Now().Unix()
+		err = s.saveProfiles()
+	}
+	s.profilesMutex.Unlock()
+
+	if err != nil {
+		log.Printf("Error saving avatar of %s: %v", username, err)
+		return status.Error(codes.Internal, "failed to save avatar")
+	}
+
+	log.Printf("User %s uploaded a %d byte %s avatar", username, image.Len(), contentType)
+	s.broadcastProfileChange(username)
+	return stream.SendAndClose(s.profileInfo(username))
+}
+
+// GetAvatar streams the avatar of a user, the first chunk carries its content type
+func (s *server) GetAvatar(req *pb.GetProfileRequest, stream pb.ChatService_GetAvatarServer) error {
+	s.profilesMutex.RLock()
+	var contentType string
+	if p, ok := s.profiles[req.Username]; ok {
+		contentType = p.AvatarType
+	}
+	s.profilesMutex.RUnlock()
+	if contentType == "" {
+		return status.Errorf(codes.NotFound, "user %s has no avatar", req.Username)
+	}
+
+	image, err := os.ReadFile(s.avatarPath(req.Username))
+	if err != nil {
+		log.Printf("Error reading avatar of %s: %v", req.Username, err)
+		return status.Errorf(codes.NotFound, "user %s has no avatar", req.Username)
+	}
+
+	for offset := 0; offset < len(image); offset += avatarChunkSize {
+		end := offset + avatarChunkSize
+		if end > len(image) {
+			end = len(image)
+		}
+		chunk := &pb.AvatarChunk{Data: image[offset:end]}
+		if offset == 0 {
+			chunk.ContentType = contentType
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tell subscribers that a user changed their display name or avatar
+func (s *server) broadcastProfileChange(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isActive(username) {
+		return
+	}
+	names, avatars := s.profileSnapshot([]string{username})
+	s.publishPresence(&pb.ActiveUsersUpdate{
+		UpdateType:     pb.ActiveUsersUpdate_PROFILE_CHANGE,
+		Username:       username,
+		DisplayNames:   names,
+		AvatarVersions: avatars,
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Longest reaction accepted, enough for emoji built from several code points
+const maxEmojiRunes = 8
+
+// Add or remove a user's reaction on a message, returns false if nothing changed
+func applyReaction(msg *pb.ChatMessage, emoji, username string, add bool) bool {
+	for i, reaction := range msg.Reactions {
+		if reaction.Emoji != emoji {
+			continue
+		}
+
+		index := sort.SearchStrings(reaction.Users, username)
+		reacted := index < len(reaction.Users) && reaction.Users[index] == username
+		if add == reacted {
+			return false
+		}
+
+		if add {
+			reaction.Users = append(reaction.Users, "")
+			copy(reaction.Users[index+1:], reaction.Users[index:])
+			reaction.Users[index] = username
+		} else {
+			reaction.Users = append(reaction.Users[:index], reaction.Users[index+1:]...)
+		}
+		reaction.Count = int32(len(reaction.Users))
+
+		// Drop emoji nobody is using anymore
+		if reaction.Count == 0 {
+			msg.Reactions = append(msg.Reactions[:i], msg.Reactions[i+1:]...)
+		}
+		return true
+	}
+
+	if !add {
+		return false
+	}
+	msg.Reactions = append(msg.Reactions, &pb.Reaction{
+		Emoji: emoji,
+		Count: 1,
+		Users: []string{username},
+	})
+	return true
+}
+
+// Shared implementation of AddReaction and RemoveReaction
+func (s *server) changeReaction(req *pb.ReactionRequest, add bool) (*pb.MessageActionResponse, error) {
+	emoji := strings.TrimSpace(req.Emoji)
+	if req.Username == "" || req.MessageId == "" || emoji == "" {
+		return nil, status.Error(codes.InvalidArgument, "username, message_id and emoji are required")
+	}
+	if utf8.RuneCountInString(emoji) > maxEmojiRunes || strings.ContainsAny(emoji, " \t\n") {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid emoji %q", req.Emoji)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	original, err := s.findMessage(req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	room := original.Room
+	if !s.isRoomMember(room, req.Username) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %s is not in room %s", req.Username, room)
+	}
+
+	// Replace rather than mutate, history pages may still be sending the old message
+	updated := proto.Clone(original).(*pb.ChatMessage)
+	if !applyReaction(updated, emoji, req.Username, add) {
+		return &pb.MessageActionResponse{
+			Success:     true,
+			Message:     "Reaction unchanged",
+			ChatMessage: original,
+		}, nil
+	}
+	if err := s.messages.Update(updated); err != nil {
+		return nil, storeStatus(err, req.MessageId)
+	}
+
+	event := proto.Clone(updated).(*pb.ChatMessage)
+	event.Type = pb.ChatMessage_REACTION
+	sent := s.sendToMembers(s.withoutBlockers(s.roomMembers(room), updated.Sender), event)
+
+	log.Printf("Reaction %s on %s by %s (add: %v), notified %d clients", emoji, req.MessageId, req.Username, add, sent)
+
+	return &pb.MessageActionResponse{
+		Success:     true,
+		Message:     "Reaction updated",
+		ChatMessage: updated,
+	}, nil
+}
+
+// AddReaction attaches an emoji from a user to a message
+func (s *server) AddReaction(ctx context.Context, req *pb.ReactionRequest) (*pb.MessageActionResponse, error) {
+	return s.changeReaction(req, true)
+}
+
+// RemoveReaction takes a user's emoji off a message
+func (s *server) RemoveReaction(ctx context.Context, req *pb.ReactionRequest) (*pb.MessageActionResponse, error) {
+	return s.changeReaction(req, false)
+}
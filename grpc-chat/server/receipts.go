@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// How far a user has received and read the messages of a room
+type readPosition struct {
+	delivered int64 // Highest sequence delivered to the user's client
+	read      int64 // Highest sequence the user has read, never above delivered
+}
+
+// Get the read position of a user in a room, creating it if needed, caller must hold s.mu
+func (s *server) readPositionFor(room, username string) *readPosition {
+	positions, ok := s.receipts[room]
+	if !ok {
+		positions = make(map[string]*readPosition)
+		s.receipts[room] = positions
+	}
+	pos, ok := positions[username]
+	if !ok {
+		pos = &readPosition{}
+		positions[username] = pos
+	}
+	return pos
+}
+
+// Aggregate who received and read a message, caller must hold s.mu
+func (s *server) receiptFor(msg *pb.ChatMessage) *pb.MessageReceipt {
+	receipt := &pb.MessageReceipt{
+		MessageId: msg.Id,
+		Room:      msg.Room,
+		Sequence:  msg.Sequence,
+	}
+	for user, pos := range s.receipts[msg.Room] {
+		if user == msg.Sender {
+			continue
+		}
+		if pos.delivered >= msg.Sequence {
+			receipt.DeliveredTo = append(receipt.DeliveredTo, user)
+		}
+		if pos.read >= msg.Sequence {
+			receipt.ReadBy = append(receipt.ReadBy, user)
+		}
+	}
+	sort.Strings(receipt.DeliveredTo)
+	sort.Strings(receipt.ReadBy)
+	return receipt
+}
+
+// AckMessages moves a user's delivered or read position in a room forward and notifies the authors
+func (s *server) AckMessages(ctx context.Context, req *pb.AckRequest) (*pb.AckResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	room := normalizeRoom(req.Room)
+	if !s.isRoomMember(room, req.Username) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %s is not in room %s", req.Username, room)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sequence := req.Sequence
+	if req.MessageId != "" {
+		msg, err := s.messages.Get(req.MessageId)
+		if err != nil && !errors.Is(err, errMessageNotFound) {
+			return nil, storeStatus(err, req.MessageId)
+		}
+		if err != nil || msg.Room != room {
+			return nil, status.Errorf(codes.NotFound, "message %s not found in room %s", req.MessageId, room)
+		}
+		sequence = msg.Sequence
+	}
+	if sequence <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "message_id or a positive sequence is required")
+	}
+	// Nobody can be past the newest message
+	if latest := s.roomSequence(room); sequence > latest {
+		sequence = latest
+	}
+
+	// Positions only move forward, read implies delivered
+	pos := s.readPositionFor(room, req.Username)
+	before := *pos
+	var previous int64
+	if req.Type == pb.AckRequest_READ {
+		previous = pos.read
+		if sequence > pos.read {
+			pos.read = sequence
+		}
+		if pos.read > pos.delivered {
+			pos.delivered = pos.read
+		}
+	} else {
+		previous = pos.delivered
+		if sequence > pos.delivered {
+			pos.delivered = sequence
+		}
+	}
+
+	if *pos != before {
+		s.saveReadPosition(room, req.Username, pos)
+	}
+
+	// Let the authors of the newly acknowledged messages know, newest first and a page at a time
+	notified := 0
+	for before := sequence + 1; before > previous+1; {
+		acked, hasMore, err := s.messages.Range(MessageRange{Room: room, Before: before, Limit: maxHistoryLimit})
+		if err != nil {
+			log.Printf("Error loading messages acked by %s in %s: %v", req.Username, room, err)
+			break
+		}
+		for i := len(acked) - 1; i >= 0; i-- {
+			msg := acked[i]
+			if msg.Sequence <= previous {
+				hasMore = false
+				break
+			}
+			if msg.Sender == req.Username {
+				continue
+			}
+			s.sendToMembers([]string{msg.Sender}, &pb.ChatMessage{
+				Id:        msg.Id,
+				Sequence:  msg.Sequence,
+				Room:      msg.Room,
+				Sender:    msg.Sender,
+				Timestamp: msg.Timestamp,
+				Type:      pb.ChatMessage_RECEIPT,
+				Receipt:   s.receiptFor(msg),
+			})
+			notified++
+		}
+		if !hasMore || len(acked) == 0 {
+			break
+		}
+		before = acked[0].Sequence
+	}
+
+	if notified > 0 {
+		log.Printf("User %s acked %s up to #%d in %s, %d receipts updated", req.Username, req.Type, sequence, room, notified)
+	}
+
+	return &pb.AckResponse{
+		Success:           true,
+		Message:           "Acknowledged",
+		DeliveredSequence: pos.delivered,
+		ReadSequence:      pos.read,
+	}, nil
+}
+
+// GetReceipts returns the receipt state of stored messages in rooms the user has joined
+func (s *server) GetReceipts(ctx context.Context, req *pb.GetReceiptsRequest) (*pb.GetReceiptsResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipts := make([]*pb.MessageReceipt, 0, len(req.MessageIds))
+	for _, id := range req.MessageIds {
+		msg, err := s.messages.Get(id)
+		if errors.Is(err, errMessageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, storeStatus(err, id)
+		}
+		if !s.isRoomMember(msg.Room, req.Username) {
+			return nil, status.Errorf(codes.PermissionDenied, "user %s is not in room %s", req.Username, msg.Room)
+		}
+		receipts = append(receipts, s.receiptFor(msg))
+	}
+
+	return &pb.GetReceiptsResponse{Receipts: receipts}, nil
+}
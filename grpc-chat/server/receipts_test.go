@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "grpc-chat/proto"
+)
+
+func TestAckSendsReceiptForEveryMessage(t *testing.T) {
+	author := &recordingStream{}
+	s := &server{
+		messages:    newMemoryStore(0),
+		rooms:       make(map[string]*chatRoom),
+		receipts:    make(map[string]map[string]*readPosition),
+		streams:     map[string]pb.ChatService_ChatStreamServer{"alice": author},
+		userStreams: map[string]map[string]bool{"alice": {"alice": true}},
+	}
+	s.ensureRoom("general", "", "alice")
+	s.addRoomMember("general", "bob")
+	total := int64(maxHistoryLimit*2 + 5)
+	for seq := int64(1); seq <= total; seq++ {
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice"}
+		if err := s.messages.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One read ack far past several pages still tells the author about each message
+	resp, err := s.AckMessages(context.Background(), &pb.AckRequest{Username: "bob", Room: "general", Sequence: total, Type: pb.AckRequest_READ})
+	if err != nil || resp.ReadSequence != total {
+		t.Fatalf("ack returned %v, %v", resp, err)
+	}
+	if len(author.sent) != int(total) {
+		t.Fatalf("author got %d receipts, want %d", len(author.sent), total)
+	}
+	for i, msg := range author.sent {
+		if msg.Type != pb.ChatMessage_RECEIPT || msg.Sequence != total-int64(i) || len(msg.Receipt.ReadBy) != 1 {
+			t.Fatalf("receipt %d is %v", i, msg)
+		}
+	}
+
+	// A later ack only covers what came after the previous one
+	author.sent = nil
+	for seq := total + 1; seq <= total+3; seq++ {
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice"}
+		if err := s.messages.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.AckMessages(context.Background(), &pb.AckRequest{Username: "bob", Room: "general", Sequence: total + 3, Type: pb.AckRequest_READ}); err != nil {
+		t.Fatal(err)
+	}
+	if len(author.sent) != 3 || author.sent[2].Sequence != total+1 {
+		t.Fatalf("author got %d receipts for the second ack", len(author.sent))
+	}
+}
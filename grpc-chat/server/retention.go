@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultRetentionMaxAge = 90 * 24 * time.Hour // Chat content has to be gone after 90 days
+	defaultCompactInterval = 10 * time.Minute
+)
+
+// Compactor metrics, served at /debug/vars when -metrics-addr is set
+var (
+	compactionRuns        = expvar.NewInt("compaction_runs")
+	compactionErrors      = expvar.NewInt("compaction_errors")
+	compactionLastRun     = expvar.NewInt("compaction_last_run_unix")
+	compactionPurged      = expvar.NewInt("compaction_purged_messages")
+	compactionPurgedRooms = expvar.NewMap("compaction_purged_messages_by_room")
+)
+
+// Describe a retention age, whole days read better than hours
+func formatRetentionAge(age time.Duration) string {
+	const day = 24 * time.Hour
+	if age == 0 {
+		return "unlimited"
+	}
+	if age >= day && age%day == 0 {
+		return fmt.Sprintf("%d days", age/day)
+	}
+	return age.String()
+}
+
+// Work out the limits for a room from its own policy and the server's. The server's maximum age applies
+// to every room, zero limits mean messages are kept.
+func (s *server) retentionLimits(room *pb.RetentionPolicy) (maxAge time.Duration, maxMessages int) {
+	policy := s.retention
+	if room != nil {
+		policy = room
+	}
+	if !policy.KeepForever {
+		maxAge = time.Duration(policy.MaxAgeSeconds) * time.Second
+		maxMessages = int(policy.MaxMessages)
+	}
+
+	limit := time.Duration(s.retention.MaxAgeSeconds) * time.Second
+	if limit > 0 && (maxAge == 0 || maxAge > limit) {
+		maxAge = limit
+	}
+	return maxAge, maxMessages
+}
+
+// Check a room policy against the server's, rooms may only keep messages for less time than the server allows
+func (s *server) validateRetention(policy *pb.RetentionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAgeSeconds < 0 || policy.MaxMessages < 0 {
+		return status.Error(codes.InvalidArgument, "retention limits can't be negative")
+	}
+	if policy.KeepForever && (policy.MaxAgeSeconds != 0 || policy.MaxMessages != 0) {
+		return status.Error(codes.InvalidArgument, "keep_forever can't be combined with limits")
+	}
+
+	limit := time.Duration(s.retention.MaxAgeSeconds) * time.Second
+	if limit > 0 && (policy.KeepForever || time.Duration(policy.MaxAgeSeconds)*time.Second > limit) {
+		return status.Errorf(codes.FailedPrecondition, "messages can't be kept longer than the server's limit of %s", formatRetentionAge(limit))
+	}
+	return nil
+}
+
+// SetRoomRetention changes how long a room keeps its messages, the next compaction applies it
+func (s *server) SetRoomRetention(ctx context.Context, req *pb.SetRoomRetentionRequest) (*pb.RoomResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	if err := s.validateRetention(req.Retention); err != nil {
+		return nil, err
+	}
+
+	name := normalizeRoom(req.Room)
+	s.roomsMutex.Lock()
+	room, ok := s.rooms[name]
+	if !ok {
+		s.roomsMutex.Unlock()
+		return nil, status.Errorf(codes.NotFound, "room %s does not exist", name)
+	}
+	if room.createdBy != req.Username && !s.moderators[req.Username] {
+		s.roomsMutex.Unlock()
+		return nil, status.Errorf(codes.PermissionDenied, "user %s may not change the retention of room %s", req.Username, name)
+	}
+	// Replace rather than mutate, the compactor may be reading the old policy
+	room.retention = nil
+	if req.Retention != nil {
+		room.retention = proto.Clone(req.Retention).(*pb.RetentionPolicy)
+	}
+	info := room.info()
+	s.roomsMutex.Unlock()
+
+	s.saveRoom(name)
+	maxAge, maxMessages := s.retentionLimits(info.Retention)
+	log.Printf("Retention of room %s set by %s: max age %s, max messages %d", name, req.Username, formatRetentionAge(maxAge), maxMessages)
+
+	return &pb.RoomResponse{
+		Success: true,
+		Message: "Retention policy updated",
+		Room:    info,
+	}, nil
+}
+
+// Enforce retention policies right away and then at every interval
+func (s *server) runCompactor(interval time.Duration) {
+	s.compact()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.compact()
+	}
+}
+
+// Run a single pass of the compactor over every room with stored messages
+func (s *server) compact() {
+	started := time.Now()
+	compactionRuns.Add(1)
+	compactionLastRun.Set(started.Unix())
+
+	names, err := s.messages.Rooms()
+	if err != nil {
+		compactionErrors.Add(1)
+		log.Printf("Compaction failed to list rooms: %v", err)
+		return
+	}
+
+	// Rooms the server no longer knows, e.g. after a restart with the file store, follow the server's policy
+	s.roomsMutex.RLock()
+	policies := make(map[string]*pb.RetentionPolicy, len(names))
+	for _, name := range names {
+		if room, ok := s.rooms[name]; ok {
+			policies[name] = room.retention
+		} else {
+			policies[name] = nil
+		}
+	}
+	s.roomsMutex.RUnlock()
+
+	total, rooms := 0, 0
+	for name, policy := range policies {
+		if purged := s.compactRoom(name, policy, started); purged > 0 {
+			total += purged
+			rooms++
+		}
+	}
+	if total > 0 {
+		log.Printf("Compaction purged %d messages from %d rooms in %v", total, rooms, time.Since(started).Round(time.Millisecond))
+	}
+
+	// Some stores only record a delete, purged messages have to be gone from disk too
+	if compacter, ok := s.messages.(Compacter); ok {
+		if err := compacter.Compact(); err != nil {
+			compactionErrors.Add(1)
+			log.Printf("Compaction failed to compact the message store: %v", err)
+		}
+	}
+}
+
+// Merge two lists of messages sorted by sequence, keeping messages in both once
+func mergeBySequence(a, b []*pb.ChatMessage) []*pb.ChatMessage {
+	merged := make([]*pb.ChatMessage, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0].Sequence < b[0].Sequence:
+			merged, a = append(merged, a[0]), a[1:]
+		case b[0].Sequence < a[0].Sequence:
+			merged, b = append(merged, b[0]), b[1:]
+		default:
+			merged, a, b = append(merged, a[0]), a[1:], b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// Delete the messages of a room its policy no longer keeps and tell its members, returns how many were deleted
+func (s *server) compactRoom(name string, policy *pb.RetentionPolicy, now time.Time) int {
+	maxAge, maxMessages := s.retentionLimits(policy)
+	if maxAge == 0 && maxMessages == 0 {
+		return 0
+	}
+
+	// Only what is to be purged is read, the store filters by age and count
+	var expired []*pb.ChatMessage
+	if maxAge > 0 {
+		// Messages stored before sent_at existed have no known age, only the count applies to them
+		old, _, err := s.messages.Range(MessageRange{Room: name, SentBefore: now.Add(-maxAge).Unix()})
+		if err != nil {
+			compactionErrors.Add(1)
+			log.Printf("Compaction failed to read room %s: %v", name, err)
+			return 0
+		}
+		expired = old
+	}
+	if maxMessages > 0 {
+		kept, hasMore, err := s.messages.Range(MessageRange{Room: name, Limit: maxMessages})
+		var excess []*pb.ChatMessage
+		if err == nil && hasMore && len(kept) > 0 {
+			excess, _, err = s.messages.Range(MessageRange{Room: name, Before: kept[0].Sequence})
+		}
+		if err != nil {
+			compactionErrors.Add(1)
+			log.Printf("Compaction failed to read room %s: %v", name, err)
+			return 0
+		}
+		expired = mergeBySequence(expired, excess)
+	}
+
+	purged, notified := 0, 0
+	for _, msg := range expired {
+		s.mu.Lock()
+		_, sent, err := s.removeMessage(msg, true)
+		s.mu.Unlock()
+		if errors.Is(err, errMessageNotFound) {
+			// Deleted by its author in the meantime
+			continue
+		}
+		if err != nil {
+			compactionErrors.Add(1)
+			log.Printf("Compaction failed to delete message %s from %s: %v", msg.Id, name, err)
+			break
+		}
+		purged++
+		notified += sent
+	}
+
+	if purged > 0 {
+		compactionPurged.Add(int64(purged))
+		compactionPurgedRooms.Add(name, int64(purged))
+		log.Printf("Retention purged %d messages from %s (max age %s, max messages %d), notified %d clients", purged, name, formatRetentionAge(maxAge), maxMessages, notified)
+	}
+	return purged
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Fail if any file under dir contains text
+func checkNotOnDisk(t *testing.T, dir, text string) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte(text)) {
+			t.Errorf("%s still contains %q", path, text)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactionRemovesPurgedMessagesFromDisk(t *testing.T) {
+	for _, kind := range []string{"file", "sqlite", "wal"} {
+		t.Run(kind, func(t *testing.T) {
+			dir := t.TempDir()
+			messages, err := openStore(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for seq := int64(1); seq <= 3; seq++ {
+				text := fmt.Sprintf("purged-body-%d", seq)
+				if seq == 3 {
+					text = "kept-body"
+				}
+				msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", Message: text, SentAt: time.Now().Unix()}
+				if err := messages.Append(msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Let the WAL write the messages to a snapshot as well
+			messages.Close()
+			if messages, err = openStore(kind, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			s := &server{
+				messages:  messages,
+				search:    newSearchIndex(),
+				rooms:     make(map[string]*chatRoom),
+				retention: &pb.RetentionPolicy{MaxMessages: 1},
+			}
+			s.compact()
+			checkNotOnDisk(t, dir, "purged-body")
+
+			// What is left still loads, and sequences continue after the purged ones
+			messages.Close()
+			if messages, err = openStore(kind, dir); err != nil {
+				t.Fatal(err)
+			}
+			defer messages.Close()
+			msg, err := messages.Get("m3")
+			if err != nil || msg.Message != "kept-body" {
+				t.Fatalf("kept message: %v, %v", msg, err)
+			}
+			if _, err := messages.Get("m1"); err != errMessageNotFound {
+				t.Fatalf("purged message: %v", err)
+			}
+			bounds, err := messages.Bounds("general")
+			if err != nil || bounds.Last != 3 {
+				t.Fatalf("bounds: %+v, %v", bounds, err)
+			}
+		})
+	}
+}
+
+func TestCompactionByAgeAndCount(t *testing.T) {
+	now := time.Now()
+	s := &server{
+		messages:  newMemoryStore(0),
+		search:    newSearchIndex(),
+		rooms:     make(map[string]*chatRoom),
+		retention: &pb.RetentionPolicy{MaxAgeSeconds: 3600, MaxMessages: 4},
+	}
+	// Message 1 has no known age, 2 and 4 are too old, the count only lets 3 to 6 stay
+	sentAt := []int64{0, now.Add(-2 * time.Hour).Unix(), now.Unix(), now.Add(-3 * time.Hour).Unix(), now.Unix(), now.Unix()}
+	for i, sent := range sentAt {
+		seq := int64(i + 1)
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", SentAt: sent}
+		if err := s.messages.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if purged := s.compactRoom("general", nil, now); purged != 3 {
+		t.Fatalf("purged %d messages, want 3", purged)
+	}
+	messages, _, err := s.messages.Range(MessageRange{Room: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []int64
+	for _, msg := range messages {
+		kept = append(kept, msg.Sequence)
+	}
+	checkSequences(t, kept, 3, 5, 6)
+}
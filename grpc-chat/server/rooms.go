@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Every user is placed in this room when they join the chat
+const defaultRoom = "general"
+
+// Room names are kept short and URL friendly so the gateway can pass them as query parameters
+var roomNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// chatRoom holds the metadata and membership of a single room
+type chatRoom struct {
+	name        string
+	description string
+	createdBy   string
+	createdAt   time.Time
+	members     map[string]bool     // Usernames that have joined the room
+	sequence    int64               // Sequence number of the last message sent to the room
+	retention   *pb.RetentionPolicy // Nil follows the server's policy
+}
+
+// normalizeRoom maps an empty room to the default room and lowercases the name
+func normalizeRoom(room string) string {
+	room = strings.ToLower(strings.TrimSpace(room))
+	if room == "" {
+		return defaultRoom
+	}
+	return room
+}
+
+// Convert a room into its protobuf representation, caller must hold roomsMutex
+func (r *chatRoom) info() *pb.RoomInfo {
+	members := make([]string, 0, len(r.members))
+	for member := range r.members {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+
+	return &pb.RoomInfo{
+		Name:        r.name,
+		Description: r.description,
+		CreatedBy:   r.createdBy,
+		Members:     members,
+		Retention:   r.retention,
+	}
+}
+
+// Create a room with the given name if it doesn't exist yet
+func (s *server) ensureRoom(name, description, createdBy string) *chatRoom {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	if room, ok := s.rooms[name]; ok {
+		return room
+	}
+
+	room := &chatRoom{
+		name:        name,
+		description: description,
+		createdBy:   createdBy,
+		createdAt:   time.Now(),
+		members:     make(map[string]bool),
+	}
+	s.rooms[name] = room
+	return room
+}
+
+// Add a user to a room, returns false if the room doesn't exist
+func (s *server) addRoomMember(roomName, username string) (added bool, exists bool) {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	room, ok := s.rooms[roomName]
+	if !ok {
+		return false, false
+	}
+	if room.members[username] {
+		return false, true
+	}
+	room.members[username] = true
+	return true, true
+}
+
+// Remove a user from a room, returns false if they weren't a member
+func (s *server) removeRoomMember(roomName, username string) bool {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	room, ok := s.rooms[roomName]
+	if !ok || !room.members[username] {
+		return false
+	}
+	delete(room.members, username)
+	return true
+}
+
+// Check whether a user is a member of a room
+func (s *server) isRoomMember(roomName, username string) bool {
+	s.roomsMutex.RLock()
+	defer s.roomsMutex.RUnlock()
+
+	room, ok := s.rooms[roomName]
+	return ok && room.members[username]
+}
+
+// Get a snapshot of the members of a room
+func (s *server) roomMembers(roomName string) []string {
+	s.roomsMutex.RLock()
+	defer s.roomsMutex.RUnlock()
+
+	room, ok := s.rooms[roomName]
+	if !ok {
+		return nil
+	}
+	members := make([]string, 0, len(room.members))
+	for member := range room.members {
+		members = append(members, member)
+	}
+	return members
+}
+
+// Get the names of all rooms a user has joined
+func (s *server) userRooms(username string) []string {
+	s.roomsMutex.RLock()
+	defer s.roomsMutex.RUnlock()
+
+	var names []string
+	for name, room := range s.rooms {
+		if room.members[username] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateRoom creates a new room and makes the creator its first member
+func (s *server) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb.RoomResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	name := normalizeRoom(req.Room)
+	if !roomNamePattern.MatchString(name) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid room name %q", req.Room)
+	}
+
+	s.roomsMutex.Lock()
+	if _, exists := s.rooms[name]; exists {
+		s.roomsMutex.Unlock()
+		return nil, status.Errorf(codes.AlreadyExists, "room %s already exists", name)
+	}
+	room := &chatRoom{
+		name:        name,
+		description: req.Description,
+		createdBy:   req.Username,
+		createdAt:   time.Now(),
+		members:     map[string]bool{req.Username: true},
+	}
+	s.rooms[name] = room
+	info := room.info()
+	s.roomsMutex.Unlock()
+
+	log.Printf("Room %s created by %s", name, req.Username)
+	s.saveRoom(name)
+	s.saveRoomMember(name, req.Username)
+
+	s.broadcastRoomJoin(name, req.Username)
+
+	return &pb.RoomResponse{
+		Success: true,
+		Message: "Room created",
+		Room:    info,
+	}, nil
+}
+
+// JoinRoom adds a user to an existing room
+func (s *server) JoinRoom(ctx context.Context, req *pb.RoomRequest) (*pb.RoomResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	name := normalizeRoom(req.Room)
+	added, exists := s.addRoomMember(name, req.Username)
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "room %s does not exist", name)
+	}
+
+	if added {
+		log.Printf("User %s joined room %s", req.Username, name)
+		s.saveRoomMember(name, req.Username)
+
+		s.broadcastMessage(&pb.ChatMessage{
+			Sender:    req.Username,
+			Message:   "joined the room",
+			Timestamp: time.Now().Format("15:04:05"),
+			Room:      name,
+		})
+		s.broadcastRoomJoin(name, req.Username)
+	}
+
+	return &pb.RoomResponse{
+		Success: true,
+		Message: "Joined room",
+		Room:    s.roomInfo(name),
+	}, nil
+}
+
+// LeaveRoom removes a user from a room
+func (s *server) LeaveRoom(ctx context.Context, req *pb.RoomRequest) (*pb.RoomResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	name := normalizeRoom(req.Room)
+	if name == defaultRoom {
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot leave the %s room", defaultRoom)
+	}
+	if !s.removeRoomMember(name, req.Username) {
+		return nil, status.Errorf(codes.NotFound, "user %s is not in room %s", req.Username, name)
+	}
+
+	log.Printf("User %s left room %s", req.Username, name)
+	s.saveRoomMember(name, req.Username)
+
+	s.broadcastMessage(&pb.ChatMessage{
+		Sender:    req.Username,
+		Message:   "left the room",
+		Timestamp: time.Now().Format("15:04:05"),
+		Room:      name,
+	})
+	s.broadcastRoomLeave(name, req.Username)
+
+	return &pb.RoomResponse{
+		Success: true,
+		Message: "Left room",
+		Room:    s.roomInfo(name),
+	}, nil
+}
+
+// ListRooms returns all rooms, or only the rooms a user has joined
+func (s *server) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.ListRoomsResponse, error) {
+	s.roomsMutex.RLock()
+	defer s.roomsMutex.RUnlock()
+
+	rooms := make([]*pb.RoomInfo, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		if req.Username != "" && !room.members[req.Username] {
+			continue
+		}
+		rooms = append(rooms, room.info())
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+
+	return &pb.ListRoomsResponse{Rooms: rooms}, nil
+}
+
+// Get the last sequence the message store has for a room
+func (s *server) storedSequence(roomName string) int64 {
+	bounds, err := s.messages.Bounds(roomName)
+	if err != nil {
+		log.Printf("Error reading bounds of room %s: %v", roomName, err)
+		return 0
+	}
+	return bounds.Last
+}
+
+// Advance and return the sequence number of a room, caller must hold s.mu.
+// Sequences carry on from the stored messages so they keep growing after a restart.
+func (s *server) nextSequence(roomName string) int64 {
+	stored := s.storedSequence(roomName)
+
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	room, ok := s.rooms[roomName]
+	if !ok {
+		return 0
+	}
+	room.sequence = max(room.sequence, stored) + 1
+	return room.sequence
+}
+
+// Get the sequence number of the last message sent to a room, caller must hold s.mu
+func (s *server) roomSequence(roomName string) int64 {
+	stored := s.storedSequence(roomName)
+
+	s.roomsMutex.RLock()
+	defer s.roomsMutex.RUnlock()
+
+	if room, ok := s.rooms[roomName]; ok {
+		return max(room.sequence, stored)
+	}
+	return 0
+}
+
+// Get the current protobuf representation of a room
+func (s *server) roomInfo(name string) *pb.RoomInfo {
+	s.roomsMutex.RLock()
+	defer s.roomsMutex.RUnlock()
+
+	if room, ok := s.rooms[name]; ok {
+		return room.info()
+	}
+	return nil
+}
+
+// Notify subscribers of a room that a user joined it
+func (s *server) broadcastRoomJoin(roomName, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Users that aren't in the chat show up in the room when they join the chat
+	if !s.isActive(username) {
+		return
+	}
+	s.sendRoomUpdate(roomName, s.userDelta(pb.ActiveUsersUpdate_JOIN, username))
+}
+
+// Notify subscribers of a room that a user left it
+func (s *server) broadcastRoomLeave(roomName, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sendRoomUpdate(roomName, &pb.ActiveUsersUpdate{
+		UpdateType: pb.ActiveUsersUpdate_LEAVE,
+		Username:   username,
+	})
+}
+
+// Stamp a room scoped delta and queue it only for the streams subscribed to that room, caller must hold s.mu
+func (s *server) sendRoomUpdate(roomName string, update *pb.ActiveUsersUpdate) {
+	update.Version = s.nextPresenceVersion()
+	for id, sub := range s.userUpdateStreams {
+		if sub.room != roomName || s.blockedBetween(sub.username, update.Username) {
+			continue
+		}
+		// Invisible users never appear in a room list, but leaving still clears them
+		if update.UpdateType == pb.ActiveUsersUpdate_JOIN && !s.subscriberSees(sub, update.Username) {
+			continue
+		}
+		s.deliverPresence(id, sub, update)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxSearchTerms = 16 // Words a query may have
+
+// Notices the server keeps among the messages of a room, nobody wants them as search results
+var roomNotices = map[string]bool{
+	"joined the chat":                 true,
+	"left the chat":                   true,
+	"left the chat (client shutdown)": true,
+	"joined the room":                 true,
+	"left the room":                   true,
+}
+
+// Split text into the lowercase words the index is keyed by, each word once
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	seen := make(map[string]bool, len(words))
+	terms := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// What the index keeps about a message, enough to filter without going to the store
+type indexedMessage struct {
+	id     string
+	room   string
+	sender string
+	sentAt int64
+	terms  []string
+}
+
+// searchIndex maps the words and senders of room messages to the messages they appear in.
+// Messages are numbered in the order they were indexed so results can be returned newest first.
+type searchIndex struct {
+	mu      sync.RWMutex
+	lastDoc uint64
+	docs    map[uint64]*indexedMessage
+	ids     map[string]uint64 // Maps message ID to its number
+	terms   map[string]map[uint64]bool
+	senders map[string]map[uint64]bool
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		docs:    make(map[uint64]*indexedMessage),
+		ids:     make(map[string]uint64),
+		terms:   make(map[string]map[uint64]bool),
+		senders: make(map[string]map[uint64]bool),
+	}
+}
+
+// Index a message, or reindex it after an edit. Notices and other non-chat messages are skipped.
+func (x *searchIndex) add(msg *pb.ChatMessage) {
+	if msg.Type != pb.ChatMessage_CHAT || msg.Room == "" || msg.Sender == "System" || roomNotices[msg.Message] {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	// An edited message keeps its place in the results
+	doc, ok := x.ids[msg.Id]
+	if ok {
+		x.unlink(doc)
+	} else {
+		x.lastDoc++
+		doc = x.lastDoc
+		x.ids[msg.Id] = doc
+	}
+
+	entry := &indexedMessage{
+		id:     msg.Id,
+		room:   msg.Room,
+		sender: msg.Sender,
+		sentAt: msg.SentAt,
+		terms:  searchTerms(msg.Message),
+	}
+	x.docs[doc] = entry
+	for _, term := range entry.terms {
+		addPosting(x.terms, term, doc)
+	}
+	addPosting(x.senders, entry.sender, doc)
+}
+
+// Drop a message from the index
+func (x *searchIndex) remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	doc, ok := x.ids[id]
+	if !ok {
+		return
+	}
+	x.unlink(doc)
+	delete(x.docs, doc)
+	delete(x.ids, id)
+}
+
+// Remove a message from the postings of its words and sender, caller must hold x.mu
+func (x *searchIndex) unlink(doc uint64) {
+	entry, ok := x.docs[doc]
+	if !ok {
+		return
+	}
+	for _, term := range entry.terms {
+		removePosting(x.terms, term, doc)
+	}
+	removePosting(x.senders, entry.sender, doc)
+}
+
+func addPosting(postings map[string]map[uint64]bool, key string, doc uint64) {
+	docs, ok := postings[key]
+	if !ok {
+		docs = make(map[uint64]bool)
+		postings[key] = docs
+	}
+	docs[doc] = true
+}
+
+func removePosting(postings map[string]map[uint64]bool, key string, doc uint64) {
+	delete(postings[key], doc)
+	if len(postings[key]) == 0 {
+		delete(postings, key)
+	}
+}
+
+// A search of the index, every set condition has to match
+type searchQuery struct {
+	terms  []string
+	sender string
+	rooms  map[string]bool  // Rooms to search
+	hidden map[string]int64 // Senders the user blocked
+	since  int64
+	until  int64
+	before uint64 // Only messages indexed before this one, zero starts at the newest
+	limit  int
+}
+
+// Find the IDs of matching messages newest first, and where the next page starts or zero on the last page
+func (x *searchIndex) search(q searchQuery) ([]string, uint64) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// Walk the smallest posting set and check the others against it
+	var sets []map[uint64]bool
+	for _, term := range q.terms {
+		sets = append(sets, x.terms[term])
+	}
+	if q.sender != "" {
+		sets = append(sets, x.senders[q.sender])
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	var matches []uint64
+	for doc := range sets[0] {
+		if q.before != 0 && doc >= q.before {
+			continue
+		}
+		entry := x.docs[doc]
+		if !q.rooms[entry.room] {
+			continue
+		}
+		if _, blocked := q.hidden[entry.sender]; blocked {
+			continue
+		}
+		if (q.since != 0 && entry.sentAt < q.since) || (q.until != 0 && entry.sentAt >= q.until) {
+			continue
+		}
+		all := true
+		for _, set := range sets[1:] {
+			if !set[doc] {
+				all = false
+				break
+			}
+		}
+		if all {
+			matches = append(matches, doc)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i] > matches[j] })
+	var next uint64
+	if len(matches) > q.limit {
+		matches = matches[:q.limit]
+		next = matches[q.limit-1]
+	}
+	ids := make([]string, len(matches))
+	for i, doc := range matches {
+		ids[i] = x.docs[doc].id
+	}
+	return ids, next
+}
+
+// Drop messages from the index when the store trims them to make room, otherwise they would
+// keep matching searches until someone finds them missing from the store
+func (s *server) followTrims() {
+	if notifier, ok := s.messages.(TrimNotifier); ok {
+		notifier.OnTrim(func(msg *pb.ChatMessage) {
+			s.search.remove(msg.Id)
+		})
+	}
+}
+
+// Index the stored messages of every room, called on startup before serving
+func (s *server) indexStoredMessages() error {
+	rooms, err := s.messages.Rooms()
+	if err != nil {
+		return err
+	}
+
+	var messages []*pb.ChatMessage
+	for _, room := range rooms {
+		page, _, err := s.messages.Range(MessageRange{Room: room})
+		if err != nil {
+			return err
+		}
+		messages = append(messages, page...)
+	}
+
+	// Number them in the order they were sent so results come out newest first
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].SentAt < messages[j].SentAt })
+	for _, msg := range messages {
+		s.search.add(msg)
+	}
+	s.search.mu.RLock()
+	indexed := len(s.search.docs)
+	s.search.mu.RUnlock()
+	log.Printf("Indexed %d of %d stored messages from %d rooms for search", indexed, len(messages), len(rooms))
+	return nil
+}
+
+// SearchMessages finds messages containing every word of the query in the rooms the user has joined
+func (s *server) SearchMessages(ctx context.Context, req *pb.SearchMessagesRequest) (*pb.SearchMessagesResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	terms := searchTerms(req.Query)
+	if len(terms) == 0 && req.Sender == "" {
+		return nil, status.Error(codes.InvalidArgument, "query or sender is required")
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, status.Errorf(codes.InvalidArgument, "query has more than %d words", maxSearchTerms)
+	}
+	if req.Since != 0 && req.Until != 0 && req.Until <= req.Since {
+		return nil, status.Error(codes.InvalidArgument, "until must be after since")
+	}
+	var before uint64
+	if req.PageToken != "" {
+		var err error
+		if before, err = strconv.ParseUint(req.PageToken, 10, 64); err != nil || before == 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+	}
+
+	rooms := make(map[string]bool)
+	if req.Room != "" {
+		room := normalizeRoom(req.Room)
+		if !s.isRoomMember(room, req.Username) {
+			return nil, status.Errorf(codes.PermissionDenied, "user %s is not in room %s", req.Username, room)
+		}
+		rooms[room] = true
+	} else {
+		for _, room := range s.userRooms(req.Username) {
+			rooms[room] = true
+		}
+	}
+
+	s.contactsMutex.RLock()
+	hidden := make(map[string]int64, len(s.contacts.Blocked[req.Username]))
+	for user, since := range s.contacts.Blocked[req.Username] {
+		hidden[user] = since
+	}
+	s.contactsMutex.RUnlock()
+
+	limit := historyLimit(req.Limit)
+	query := searchQuery{
+		terms:  terms,
+		sender: req.Sender,
+		rooms:  rooms,
+		hidden: hidden,
+		since:  req.Since,
+		until:  req.Until,
+		before: before,
+		limit:  limit,
+	}
+	resp := &pb.SearchMessagesResponse{}
+	for {
+		ids, next := s.search.search(query)
+		for _, id := range ids {
+			msg, err := s.messages.Get(id)
+			if errors.Is(err, errMessageNotFound) {
+				// Gone from the store without the index hearing about it
+				s.search.remove(id)
+				continue
+			}
+			if err != nil {
+				return nil, storeStatus(err, id)
+			}
+			resp.Messages = append(resp.Messages, msg)
+		}
+		if next == 0 {
+			break
+		}
+		// Fill up a page that came back short from where it stopped
+		if len(resp.Messages) == limit {
+			resp.NextPageToken = strconv.FormatUint(next, 10)
+			break
+		}
+		query.before = next
+		query.limit = limit - len(resp.Messages)
+	}
+
+	log.Printf("Search by %s for %q: %d messages", req.Username, req.Query, len(resp.Messages))
+	return resp, nil
+}
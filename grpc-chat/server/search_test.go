@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "grpc-chat/proto"
+)
+
+// A server with a memory store keeping perRoom messages and alice and bob in room general
+func newSearchServer(perRoom int) *server {
+	s := &server{
+		rooms:    make(map[string]*chatRoom),
+		search:   newSearchIndex(),
+		messages: newMemoryStore(perRoom),
+		contacts: contactBook{Blocked: make(map[string]map[string]int64)},
+	}
+	s.followTrims()
+	s.ensureRoom("general", "", "alice")
+	s.addRoomMember("general", "alice")
+	s.addRoomMember("general", "bob")
+	return s
+}
+
+// Store and index a message the way broadcastMessage does
+func postMessage(t *testing.T, s *server, seq int64, sender, text string) {
+	t.Helper()
+	msg := &pb.ChatMessage{
+		Id:       fmt.Sprintf("m%d", seq),
+		Sequence: seq,
+		Room:     "general",
+		Sender:   sender,
+		Message:  text,
+		SentAt:   1700000000 + seq,
+	}
+	if err := s.messages.Append(msg); err != nil {
+		t.Fatal(err)
+	}
+	s.search.add(msg)
+}
+
+// Search page by page and return the sequences found, newest first, and how many pages it took
+func searchAll(t *testing.T, s *server, req *pb.SearchMessagesRequest) ([]int64, int) {
+	t.Helper()
+	var found []int64
+	pages := 0
+	for {
+		resp, err := s.SearchMessages(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		if len(resp.Messages) == 0 && resp.NextPageToken != "" {
+			t.Fatalf("page %d is empty but has a next page token", pages)
+		}
+		for _, msg := range resp.Messages {
+			found = append(found, msg.Sequence)
+		}
+		if resp.NextPageToken == "" {
+			return found, pages
+		}
+		if len(resp.Messages) != int(req.Limit) {
+			t.Fatalf("page %d has %d messages and a next page token", pages, len(resp.Messages))
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+func checkSequences(t *testing.T, got []int64, want ...int64) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("found %v, want %v", got, want)
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	s := newSearchServer(0)
+	for seq := int64(1); seq <= 7; seq++ {
+		postMessage(t, s, seq, "alice", "hello world")
+	}
+	postMessage(t, s, 8, "alice", "something else")
+
+	found, pages := searchAll(t, s, &pb.SearchMessagesRequest{Username: "bob", Query: "hello", Limit: 3})
+	checkSequences(t, found, 7, 6, 5, 4, 3, 2, 1)
+	if pages != 3 {
+		t.Fatalf("took %d pages, want 3", pages)
+	}
+
+	// A last page that is exactly full has no token
+	found, pages = searchAll(t, s, &pb.SearchMessagesRequest{Username: "bob", Query: "world", Limit: 7})
+	checkSequences(t, found, 7, 6, 5, 4, 3, 2, 1)
+	if pages != 1 {
+		t.Fatalf("took %d pages, want 1", pages)
+	}
+}
+
+func TestSearchFillsPagesAfterMissingMessages(t *testing.T) {
+	s := newSearchServer(0)
+	for seq := int64(1); seq <= 6; seq++ {
+		postMessage(t, s, seq, "alice", "hello")
+	}
+	// Deleted from the store behind the index's back
+	s.messages.Delete("m5")
+	s.messages.Delete("m4")
+
+	resp, err := s.SearchMessages(context.Background(), &pb.SearchMessagesRequest{Username: "bob", Query: "hello", Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []int64
+	for _, msg := range resp.Messages {
+		found = append(found, msg.Sequence)
+	}
+	checkSequences(t, found, 6, 3, 2)
+	if resp.NextPageToken == "" {
+		t.Fatal("full page without a next page token")
+	}
+}
+
+func TestSearchForgetsTrimmedMessages(t *testing.T) {
+	s := newSearchServer(3)
+	for seq := int64(1); seq <= 5; seq++ {
+		postMessage(t, s, seq, "alice", "hello")
+	}
+
+	s.search.mu.RLock()
+	indexed := len(s.search.docs)
+	s.search.mu.RUnlock()
+	if indexed != 3 {
+		t.Fatalf("index has %d messages after the store trimmed to 3", indexed)
+	}
+	found, _ := searchAll(t, s, &pb.SearchMessagesRequest{Username: "bob", Query: "hello", Limit: 2})
+	checkSequences(t, found, 5, 4, 3)
+}
+
+func TestSearchHidesBlockedSenders(t *testing.T) {
+	s := newSearchServer(0)
+	s.addRoomMember("general", "carol")
+	postMessage(t, s, 1, "carol", "hello from carol")
+	postMessage(t, s, 2, "alice", "hello from alice")
+	postMessage(t, s, 3, "carol", "hello again")
+	postMessage(t, s, 4, "alice", "hello again")
+	s.contacts.Blocked["bob"] = map[string]int64{"carol": 1700000000}
+
+	found, _ := searchAll(t, s, &pb.SearchMessagesRequest{Username: "bob", Query: "hello", Limit: 1})
+	checkSequences(t, found, 4, 2)
+
+	// Searching for the blocked sender by name finds nothing, others still see them
+	found, _ = searchAll(t, s, &pb.SearchMessagesRequest{Username: "bob", Sender: "carol", Limit: 10})
+	checkSequences(t, found)
+	found, _ = searchAll(t, s, &pb.SearchMessagesRequest{Username: "alice", Sender: "carol", Limit: 10})
+	checkSequences(t, found, 3, 1)
+}
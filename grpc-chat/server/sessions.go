@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Device reported for sessions that didn't describe themselves
+const unknownDevice = "unknown device"
+
+// An open chat stream of a user
+type chatSession struct {
+	username    string
+	device      string
+	connectedAt time.Time
+}
+
+// Attach a chat stream to a user, returns true if the stream wasn't theirs yet, caller must hold s.mu
+func (s *server) attachSession(streamID, username, device string) bool {
+	if session, ok := s.sessions[streamID]; ok {
+		if session.username == username {
+			if device != "" {
+				session.device = device
+			}
+			return false
+		}
+		// The stream now speaks for someone else
+		s.detachSession(streamID)
+	}
+
+	if device == "" {
+		device = unknownDevice
+	}
+	s.sessions[streamID] = &chatSession{
+		username:    username,
+		device:      device,
+		connectedAt: time.Now(),
+	}
+	if s.userStreams[username] == nil {
+		s.userStreams[username] = make(map[string]bool)
+	}
+	s.userStreams[username][streamID] = true
+	return true
+}
+
+// Detach a chat stream from its user, returns the user and how many sessions they still have, caller must hold s.mu
+func (s *server) detachSession(streamID string) (username string, remaining int, ok bool) {
+	session, ok := s.sessions[streamID]
+	if !ok {
+		return "", 0, false
+	}
+	delete(s.sessions, streamID)
+
+	streams := s.userStreams[session.username]
+	delete(streams, streamID)
+	if len(streams) == 0 {
+		delete(s.userStreams, session.username)
+	}
+	return session.username, len(streams), true
+}
+
+// Get the session count and devices of the given users, caller must hold s.mu
+func (s *server) sessionsSnapshot(users []string) map[string]*pb.UserSessions {
+	snapshot := make(map[string]*pb.UserSessions, len(users))
+	for _, user := range users {
+		streams := s.userStreams[user]
+		if len(streams) == 0 {
+			continue
+		}
+
+		sessions := make([]*chatSession, 0, len(streams))
+		for id := range streams {
+			sessions = append(sessions, s.sessions[id])
+		}
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].connectedAt.Before(sessions[j].connectedAt) })
+
+		devices := make([]string, len(sessions))
+		for i, session := range sessions {
+			devices[i] = session.device
+		}
+		snapshot[user] = &pb.UserSessions{
+			Count:   int32(len(sessions)),
+			Devices: devices,
+		}
+	}
+	return snapshot
+}
+
+// Tell subscribers that a user opened or closed a session while staying online
+func (s *server) broadcastSessionChange(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.publishPresence(&pb.ActiveUsersUpdate{
+		UpdateType: pb.ActiveUsersUpdate_SESSION_CHANGE,
+		Username:   username,
+		Sessions:   s.sessionsSnapshot([]string{username}),
+	})
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/protobuf/proto"
+	_ "modernc.org/sqlite" // Pure Go driver, registers "sqlite"
+)
+
+const sqliteFile = "chat.db"
+
+// Schema changes in the order they are applied, the database records how many it has in user_version.
+// Append new statements to change the schema, never edit ones that were released.
+var sqliteMigrations = []string{
+	`CREATE TABLE messages (
+		id        TEXT PRIMARY KEY,
+		room      TEXT NOT NULL,
+		sequence  INTEGER NOT NULL,
+		sender    TEXT NOT NULL,
+		body      TEXT NOT NULL,
+		parent_id TEXT NOT NULL DEFAULT '',
+		sent_at   INTEGER NOT NULL, -- Unix time, zero for messages from before it was recorded
+		data      BLOB NOT NULL     -- The whole message, the other columns are copies for queries
+	);
+	CREATE INDEX messages_room_sequence ON messages (room, sequence);
+	CREATE INDEX messages_room_sent_at ON messages (room, sent_at);
+	CREATE TABLE room_bounds (
+		room TEXT PRIMARY KEY,
+		last INTEGER NOT NULL
+	);
+	CREATE TABLE rooms (
+		name        TEXT PRIMARY KEY,
+		description TEXT NOT NULL,
+		created_by  TEXT NOT NULL,
+		created_at  INTEGER NOT NULL
+	);
+	CREATE TABLE room_members (
+		room     TEXT NOT NULL REFERENCES rooms (name),
+		username TEXT NOT NULL,
+		PRIMARY KEY (room, username)
+	);
+	CREATE TABLE users (
+		username          TEXT PRIMARY KEY,
+		joined_at         INTEGER NOT NULL,
+		last_seen         INTEGER NOT NULL,
+		message_count     INTEGER NOT NULL,
+		presence          TEXT NOT NULL,
+		custom_text       TEXT NOT NULL,
+		custom_emoji      TEXT NOT NULL,
+		custom_expires_at INTEGER NOT NULL
+	);`,
+	// Room retention policies, NULL follows the server's policy
+	`ALTER TABLE rooms ADD COLUMN retention_max_age INTEGER;
+	ALTER TABLE rooms ADD COLUMN retention_max_messages INTEGER;
+	ALTER TABLE rooms ADD COLUMN retention_keep_forever INTEGER;`,
+	// Highest sequence purged from each room, resuming from before it means messages were missed
+	`ALTER TABLE room_bounds ADD COLUMN trimmed INTEGER NOT NULL DEFAULT 0;`,
+	// Read positions and direct messages, which have no room or sequence of their own
+	`CREATE TABLE read_positions (
+		room      TEXT NOT NULL,
+		username  TEXT NOT NULL,
+		delivered INTEGER NOT NULL,
+		read      INTEGER NOT NULL,
+		PRIMARY KEY (room, username)
+	);
+	CREATE TABLE direct_messages (
+		id        TEXT PRIMARY KEY,
+		sender    TEXT NOT NULL,
+		recipient TEXT NOT NULL,
+		data      BLOB NOT NULL -- The whole message, rowid keeps the order they were sent in
+	);
+	CREATE INDEX direct_messages_sender ON direct_messages (sender);
+	CREATE INDEX direct_messages_recipient ON direct_messages (recipient);`,
+}
+
+// sqliteStore keeps messages, rooms, users, read positions and direct messages in an SQLite database
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// Open an SQLite database, creating it if needed, and bring its schema up to date
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=secure_delete(1)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, one connection avoids busy errors between our own queries
+	db.SetMaxOpenConns(1)
+
+	store := &sqliteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Apply the migrations the database doesn't have yet, each in its own transaction
+func (d *sqliteStore) migrate() error {
+	var version int
+	if err := d.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("schema version %d is newer than this server supports (%d)", version, len(sqliteMigrations))
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		// PRAGMA doesn't take parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Applied database migration %d", version+1)
+	}
+	return nil
+}
+
+// Decode messages stored in the data column
+func scanMessages(rows *sql.Rows) ([]*pb.ChatMessage, error) {
+	defer rows.Close()
+
+	var messages []*pb.ChatMessage
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		msg := &pb.ChatMessage{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func (d *sqliteStore) Append(msg *pb.ChatMessage) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO messages (id, room, sequence, sender, body, parent_id, sent_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.Id, msg.Room, msg.Sequence, msg.Sender, msg.Message, msg.ParentId, msg.SentAt, data); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO room_bounds (room, last) VALUES (?, ?)
+		ON CONFLICT (room) DO UPDATE SET last = max(last, excluded.last)`, msg.Room, msg.Sequence); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqliteStore) Range(r MessageRange) ([]*pb.ChatMessage, bool, error) {
+	query := "SELECT data FROM messages WHERE room = ?"
+	args := []any{r.Room}
+	if r.After > 0 {
+		query += " AND sequence > ?"
+		args = append(args, r.After)
+	}
+	if r.Before > 0 {
+		query += " AND sequence < ?"
+		args = append(args, r.Before)
+	}
+	if r.SentBefore > 0 {
+		// Served by the messages_room_sent_at index
+		query += " AND sent_at > 0 AND sent_at < ?"
+		args = append(args, r.SentBefore)
+	}
+
+	// Without an After cursor the newest are kept, so read backwards and flip the page afterwards
+	newest := r.After == 0 && r.Limit > 0
+	if newest {
+		query += " ORDER BY sequence DESC"
+	} else {
+		query += " ORDER BY sequence ASC"
+	}
+	// Ask for one extra row to tell whether more match
+	if r.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, r.Limit+1)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	page, err := scanMessages(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := r.Limit > 0 && len(page) > r.Limit
+	if hasMore {
+		page = page[:r.Limit]
+	}
+	if newest {
+		for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+			page[i], page[j] = page[j], page[i]
+		}
+	}
+	return page, hasMore, nil
+}
+
+func (d *sqliteStore) Get(id string) (*pb.ChatMessage, error) {
+	var data []byte
+	err := d.db.QueryRow("SELECT data FROM messages WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg := &pb.ChatMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (d *sqliteStore) Update(msg *pb.ChatMessage) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	result, err := d.db.Exec("UPDATE messages SET body = ?, data = ? WHERE id = ?", msg.Message, data, msg.Id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errMessageNotFound
+	}
+	return nil
+}
+
+func (d *sqliteStore) Delete(id string) (*pb.ChatMessage, error) {
+	var data []byte
+	err := d.db.QueryRow("DELETE FROM messages WHERE id = ? RETURNING data", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg := &pb.ChatMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Copy the database's own write-ahead log into the database and empty it. Deleted rows are zeroed in the
+// database by secure_delete but the log still has earlier versions of their pages.
+func (d *sqliteStore) Compact() error {
+	var busy, frames, checkpointed int
+	if err := d.db.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &frames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return errors.New("checkpoint blocked by another connection")
+	}
+	return nil
+}
+
+func (d *sqliteStore) Purge(id string) (*pb.ChatMessage, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRow("DELETE FROM messages WHERE id = ? RETURNING data", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg := &pb.ChatMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE room_bounds SET trimmed = max(trimmed, ?) WHERE room = ?", msg.Sequence, msg.Room); err != nil {
+		return nil, err
+	}
+	return msg, tx.Commit()
+}
+
+func (d *sqliteStore) Bounds(room string) (RoomBounds, error) {
+	var bounds RoomBounds
+	err := d.db.QueryRow("SELECT last, trimmed FROM room_bounds WHERE room = ?", room).Scan(&bounds.Last, &bounds.Trimmed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return bounds, nil
+	}
+	return bounds, err
+}
+
+func (d *sqliteStore) Rooms() ([]string, error) {
+	rows, err := d.db.Query("SELECT room FROM room_bounds ORDER BY room")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []string
+	for rows.Next() {
+		var room string
+		if err := rows.Scan(&room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+func (d *sqliteStore) Close() error {
+	return d.db.Close()
+}
+
+func (d *sqliteStore) SaveRoom(room RoomRecord) error {
+	var maxAge, maxMessages, keepForever sql.NullInt64
+	if r := room.Retention; r != nil {
+		maxAge = sql.NullInt64{Int64: r.MaxAgeSeconds, Valid: true}
+		maxMessages = sql.NullInt64{Int64: int64(r.MaxMessages), Valid: true}
+		keepForever = sql.NullInt64{Valid: true}
+		if r.KeepForever {
+			keepForever.Int64 = 1
+		}
+	}
+	_, err := d.db.Exec(`INSERT INTO rooms (name, description, created_by, created_at, retention_max_age, retention_max_messages, retention_keep_forever)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET description = excluded.description, retention_max_age = excluded.retention_max_age,
+			retention_max_messages = excluded.retention_max_messages, retention_keep_forever = excluded.retention_keep_forever`,
+		room.Name, room.Description, room.CreatedBy, room.CreatedAt.Unix(), maxAge, maxMessages, keepForever)
+	return err
+}
+
+func (d *sqliteStore) SaveRoomMember(room, username string, member bool) error {
+	var err error
+	if member {
+		_, err = d.db.Exec("INSERT OR IGNORE INTO room_members (room, username) VALUES (?, ?)", room, username)
+	} else {
+		_, err = d.db.Exec("DELETE FROM room_members WHERE room = ? AND username = ?", room, username)
+	}
+	return err
+}
+
+func (d *sqliteStore) SaveUser(user UserRecord) error {
+	custom := user.Custom
+	if custom == nil {
+		custom = &pb.CustomStatus{}
+	}
+	_, err := d.db.Exec(`INSERT INTO users (username, joined_at, last_seen, message_count, presence, custom_text, custom_emoji, custom_expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET joined_at = excluded.joined_at, last_seen = excluded.last_seen,
+			message_count = excluded.message_count, presence = excluded.presence, custom_text = excluded.custom_text,
+			custom_emoji = excluded.custom_emoji, custom_expires_at = excluded.custom_expires_at`,
+		user.Username, unixOrZero(user.JoinedAt), unixOrZero(user.LastSeen), user.MessageCount, user.Presence,
+		custom.Text, custom.Emoji, custom.ExpiresAt)
+	return err
+}
+
+func (d *sqliteStore) LoadRooms() ([]RoomRecord, error) {
+	rows, err := d.db.Query(`SELECT name, description, created_by, created_at, retention_max_age, retention_max_messages, retention_keep_forever
+		FROM rooms ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []RoomRecord
+	index := make(map[string]int)
+	for rows.Next() {
+		var room RoomRecord
+		var createdAt int64
+		var maxAge, maxMessages, keepForever sql.NullInt64
+		if err := rows.Scan(&room.Name, &room.Description, &room.CreatedBy, &createdAt, &maxAge, &maxMessages, &keepForever); err != nil {
+			return nil, err
+		}
+		room.CreatedAt = time.Unix(createdAt, 0)
+		if keepForever.Valid {
+			room.Retention = &pb.RetentionPolicy{
+				MaxAgeSeconds: maxAge.Int64,
+				MaxMessages:   int32(maxMessages.Int64),
+				KeepForever:   keepForever.Int64 != 0,
+			}
+		}
+		index[room.Name] = len(rooms)
+		rooms = append(rooms, room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := d.db.Query("SELECT room, username FROM room_members ORDER BY room, username")
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+
+	for members.Next() {
+		var room, username string
+		if err := members.Scan(&room, &username); err != nil {
+			return nil, err
+		}
+		if i, ok := index[room]; ok {
+			rooms[i].Members = append(rooms[i].Members, username)
+		}
+	}
+	return rooms, members.Err()
+}
+
+func (d *sqliteStore) LoadUsers() ([]UserRecord, error) {
+	rows, err := d.db.Query(`SELECT username, joined_at, last_seen, message_count, presence, custom_text, custom_emoji, custom_expires_at
+		FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserRecord
+	for rows.Next() {
+		var user UserRecord
+		var joinedAt, lastSeen int64
+		custom := &pb.CustomStatus{}
+		if err := rows.Scan(&user.Username, &joinedAt, &lastSeen, &user.MessageCount, &user.Presence,
+			&custom.Text, &custom.Emoji, &custom.ExpiresAt); err != nil {
+			return nil, err
+		}
+		user.JoinedAt = timeOrZero(joinedAt)
+		user.LastSeen = timeOrZero(lastSeen)
+		if custom.Text != "" || custom.Emoji != "" {
+			user.Custom = custom
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (d *sqliteStore) SaveReadPosition(pos ReadPositionRecord) error {
+	_, err := d.db.Exec(`INSERT INTO read_positions (room, username, delivered, read) VALUES (?, ?, ?, ?)
+		ON CONFLICT (room, username) DO UPDATE SET delivered = excluded.delivered, read = excluded.read`,
+		pos.Room, pos.Username, pos.Delivered, pos.Read)
+	return err
+}
+
+func (d *sqliteStore) LoadReadPositions() ([]ReadPositionRecord, error) {
+	rows, err := d.db.Query("SELECT room, username, delivered, read FROM read_positions ORDER BY room, username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []ReadPositionRecord
+	for rows.Next() {
+		var pos ReadPositionRecord
+		if err := rows.Scan(&pos.Room, &pos.Username, &pos.Delivered, &pos.Read); err != nil {
+			return nil, err
+		}
+		positions = append(positions, pos)
+	}
+	return positions, rows.Err()
+}
+
+func (d *sqliteStore) SaveDirectMessage(msg *pb.ChatMessage) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec("INSERT INTO direct_messages (id, sender, recipient, data) VALUES (?, ?, ?, ?)",
+		msg.Id, msg.Sender, msg.Recipient, data)
+	return err
+}
+
+func (d *sqliteStore) LoadDirectMessages(username string, limit int) ([]*pb.ChatMessage, error) {
+	rows, err := d.db.Query(`SELECT data FROM direct_messages WHERE sender = ? OR recipient = ?
+		ORDER BY rowid DESC LIMIT ?`, username, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// Unix time of t, zero for the zero time
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// Time of a Unix timestamp, the zero time for zero
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
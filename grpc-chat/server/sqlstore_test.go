@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// Open a SQLite store in a new directory, returns its path to reopen it
+func openTestSQLite(t *testing.T) (*sqliteStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), sqliteFile)
+	d, err := openSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, path
+}
+
+// Sequences of a page of messages, in order
+func sequencesOf(messages []*pb.ChatMessage) []int64 {
+	var seqs []int64
+	for _, msg := range messages {
+		seqs = append(seqs, msg.Sequence)
+	}
+	return seqs
+}
+
+func TestSQLiteRange(t *testing.T) {
+	d, _ := openTestSQLite(t)
+	defer d.Close()
+	for seq := int64(1); seq <= 5; seq++ {
+		// Message 1 has no send time, the others are an hour apart
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", Message: fmt.Sprintf("message %d", seq)}
+		if seq > 1 {
+			msg.SentAt = seq * 3600
+		}
+		if err := d.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := &pb.ChatMessage{Id: "other", Sequence: 1, Room: "random", Sender: "bob"}
+	if err := d.Append(other); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		window  MessageRange
+		want    []int64
+		hasMore bool
+	}{
+		{"all", MessageRange{Room: "general"}, []int64{1, 2, 3, 4, 5}, false},
+		{"newest", MessageRange{Room: "general", Limit: 2}, []int64{4, 5}, true},
+		{"after", MessageRange{Room: "general", After: 1, Limit: 2}, []int64{2, 3}, true},
+		{"before", MessageRange{Room: "general", Before: 4, Limit: 2}, []int64{2, 3}, true},
+		{"between", MessageRange{Room: "general", After: 2, Before: 5}, []int64{3, 4}, false},
+		{"sent before", MessageRange{Room: "general", SentBefore: 4 * 3600}, []int64{2, 3}, false},
+		{"empty room", MessageRange{Room: "missing"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, hasMore, err := d.Range(tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSequences(t, sequencesOf(messages), tt.want...)
+			if hasMore != tt.hasMore {
+				t.Fatalf("hasMore is %v, want %v", hasMore, tt.hasMore)
+			}
+		})
+	}
+}
+
+func TestSQLiteMessagesSurviveReopen(t *testing.T) {
+	d, path := openTestSQLite(t)
+	for seq := int64(1); seq <= 4; seq++ {
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", Message: fmt.Sprintf("message %d", seq)}
+		if err := d.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edited := &pb.ChatMessage{Id: "m2", Sequence: 2, Room: "general", Sender: "alice", Message: "edited"}
+	if err := d.Update(edited); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Delete("m4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Purge("m1"); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d, err := openSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	messages, _, err := d.Range(MessageRange{Room: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSequences(t, sequencesOf(messages), 2, 3)
+	msg, err := d.Get("m2")
+	if err != nil || msg.Message != "edited" {
+		t.Fatalf("got %v, %v", msg, err)
+	}
+	if _, err := d.Get("m4"); err != errMessageNotFound {
+		t.Fatalf("deleted message: %v", err)
+	}
+
+	// Deleting the newest message doesn't lower the last sequence, only the purge counts as trimmed
+	bounds, err := d.Bounds("general")
+	if err != nil || bounds.Trimmed != 1 || bounds.Last != 4 {
+		t.Fatalf("bounds %+v, %v", bounds, err)
+	}
+	if bounds, err := d.Bounds("missing"); err != nil || bounds != (RoomBounds{}) {
+		t.Fatalf("bounds of an empty room %+v, %v", bounds, err)
+	}
+}
+
+func TestSQLiteMetadataRoundTrip(t *testing.T) {
+	d, path := openTestSQLite(t)
+	created := time.Unix(1700000000, 0)
+	rooms := []RoomRecord{
+		{Name: "general", Description: "Everyone", CreatedBy: "alice", CreatedAt: created},
+		{Name: "ops", CreatedBy: "bob", CreatedAt: created, Retention: &pb.RetentionPolicy{MaxAgeSeconds: 3600, MaxMessages: 10}},
+	}
+	for _, room := range rooms {
+		if err := d.SaveRoom(room); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		if err := d.SaveRoomMember("general", username, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.SaveRoomMember("general", "carol", false); err != nil {
+		t.Fatal(err)
+	}
+	users := []UserRecord{
+		{Username: "alice", JoinedAt: created, LastSeen: created.Add(time.Hour), MessageCount: 3, Presence: "away",
+			Custom: &pb.CustomStatus{Text: "Lunch", Emoji: "🍕", ExpiresAt: created.Add(2 * time.Hour).Unix()}},
+		{Username: "bob", JoinedAt: created},
+	}
+	for _, user := range users {
+		if err := d.SaveUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saveReadPositionsAndDirectMessages(t, d)
+	d.Close()
+
+	d, err := openSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	loadedRooms, err := d.LoadRooms()
+	if err != nil || len(loadedRooms) != 2 {
+		t.Fatalf("loaded rooms %v, %v", loadedRooms, err)
+	}
+	general, ops := loadedRooms[0], loadedRooms[1]
+	if general.Description != "Everyone" || general.CreatedBy != "alice" || !general.CreatedAt.Equal(created) || general.Retention != nil {
+		t.Fatalf("general loaded as %+v", general)
+	}
+	if len(general.Members) != 2 || general.Members[0] != "alice" || general.Members[1] != "bob" {
+		t.Fatalf("general has members %v", general.Members)
+	}
+	if ops.Retention == nil || ops.Retention.MaxAgeSeconds != 3600 || ops.Retention.MaxMessages != 10 || ops.Retention.KeepForever {
+		t.Fatalf("ops has retention %v", ops.Retention)
+	}
+
+	loadedUsers, err := d.LoadUsers()
+	if err != nil || len(loadedUsers) != 2 {
+		t.Fatalf("loaded users %v, %v", loadedUsers, err)
+	}
+	alice, bob := loadedUsers[0], loadedUsers[1]
+	if !alice.LastSeen.Equal(created.Add(time.Hour)) || alice.MessageCount != 3 || alice.Presence != "away" {
+		t.Fatalf("alice loaded as %+v", alice)
+	}
+	if alice.Custom == nil || alice.Custom.Text != "Lunch" || alice.Custom.Emoji != "🍕" || alice.Custom.ExpiresAt != users[0].Custom.ExpiresAt {
+		t.Fatalf("alice has custom status %v", alice.Custom)
+	}
+	if !bob.LastSeen.IsZero() || bob.Custom != nil {
+		t.Fatalf("bob loaded as %+v", bob)
+	}
+	checkReadPositionsAndDirectMessages(t, d)
+}
+
+// Save read positions and direct messages through a store, checkReadPositionsAndDirectMessages expects them
+func saveReadPositionsAndDirectMessages(t *testing.T, meta MetadataStore) {
+	t.Helper()
+	positions := []ReadPositionRecord{
+		{Room: "general", Username: "alice", Delivered: 3, Read: 1},
+		{Room: "general", Username: "bob", Delivered: 2},
+		{Room: "general", Username: "alice", Delivered: 5, Read: 4},
+	}
+	for _, pos := range positions {
+		if err := meta.SaveReadPosition(pos); err != nil {
+			t.Fatal(err)
+		}
+	}
+	direct := []*pb.ChatMessage{
+		{Id: "d1", Sender: "alice", Recipient: "bob", Message: "hi bob"},
+		{Id: "d2", Sender: "carol", Recipient: "dave", Message: "not for bob"},
+		{Id: "d3", Sender: "bob", Recipient: "alice", Message: "hi alice"},
+		{Id: "d4", Sender: "carol", Recipient: "bob", Message: "hi from carol"},
+	}
+	for _, msg := range direct {
+		if err := meta.SaveDirectMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkReadPositionsAndDirectMessages(t *testing.T, meta MetadataStore) {
+	t.Helper()
+	positions, err := meta.LoadReadPositions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := make(map[string]ReadPositionRecord)
+	for _, pos := range positions {
+		saved[pos.Room+"/"+pos.Username] = pos
+	}
+	if len(saved) != 2 || saved["general/alice"].Delivered != 5 || saved["general/alice"].Read != 4 || saved["general/bob"].Delivered != 2 {
+		t.Fatalf("loaded read positions %+v", positions)
+	}
+
+	// Newest first are kept, returned in the order they were sent
+	direct, err := meta.LoadDirectMessages("bob", 2)
+	if err != nil || len(direct) != 2 || direct[0].Id != "d3" || direct[1].Id != "d4" {
+		t.Fatalf("loaded direct messages of bob %v, %v", direct, err)
+	}
+	if direct, err := meta.LoadDirectMessages("dave", 10); err != nil || len(direct) != 1 || direct[0].Message != "not for bob" {
+		t.Fatalf("loaded direct messages of dave %v, %v", direct, err)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxCachedMessages = 100 // Messages the memory store keeps per room
+
+var errMessageNotFound = errors.New("message not found")
+
+// MessageStore keeps the messages of every room in sequence order, a MetadataStore keeps direct messages.
+// Messages handed out are shared and must not be modified, changes go through Update with a copy.
+type MessageStore interface {
+	// Append adds a stamped message after the last one of its room
+	Append(msg *pb.ChatMessage) error
+	// Range returns a window of a room's messages in sequence order and whether more match beyond it
+	Range(r MessageRange) ([]*pb.ChatMessage, bool, error)
+	// Get finds a message by ID in any room
+	Get(id string) (*pb.ChatMessage, error)
+	// Update replaces the stored message with the same ID
+	Update(msg *pb.ChatMessage) error
+	// Delete removes a message and returns what was stored
+	Delete(id string) (*pb.ChatMessage, error)
+	// Purge removes a message a retention policy no longer keeps and records in the bounds of its room
+	// that messages up to its sequence are gone
+	Purge(id string) (*pb.ChatMessage, error)
+	// Bounds reports how far the messages of a room go
+	Bounds(room string) (RoomBounds, error)
+	// Rooms lists every room that had messages appended
+	Rooms() ([]string, error)
+	// Close releases the store, it can't be used afterwards
+	Close() error
+}
+
+// TrimNotifier is implemented by message stores that drop messages on their own to make room,
+// so whatever else keeps track of messages can let go of them too.
+type TrimNotifier interface {
+	// OnTrim sets a function called with every message dropped, the store may be locked during the call
+	OnTrim(trimmed func(msg *pb.ChatMessage))
+}
+
+// Compacter is implemented by message stores that keep deleted messages on disk until they are compacted.
+// The compactor calls it after each pass so purged messages are gone from disk as well.
+type Compacter interface {
+	// Compact rewrites what the store keeps on disk so deleted messages are no longer in it
+	Compact() error
+}
+
+// A window of a room's messages: sequences strictly between After and Before, zero leaves a side open.
+// When more than Limit match, an After cursor keeps the oldest and otherwise the newest are kept.
+// A zero Limit returns every match. A non-zero SentBefore only matches messages with a known
+// send time before it, in Unix seconds.
+type MessageRange struct {
+	Room       string
+	After      int64
+	Before     int64
+	Limit      int
+	SentBefore int64
+}
+
+// How far the messages of a room go
+type RoomBounds struct {
+	Trimmed int64 // Highest sequence dropped to make room or purged, messages up to it are gone
+	Last    int64 // Highest sequence ever appended, deleting messages doesn't lower it
+}
+
+// MetadataStore is implemented by message stores that also keep rooms, users and their chosen presence,
+// read positions and direct messages, so those survive a restart as well. The server saves changes as
+// they happen and loads them on startup.
+type MetadataStore interface {
+	// SaveRoom creates or updates a room, its members are saved separately
+	SaveRoom(room RoomRecord) error
+	// SaveRoomMember adds a user to a room or removes them
+	SaveRoomMember(room, username string, member bool) error
+	// SaveUser creates or updates a user
+	SaveUser(user UserRecord) error
+	// LoadRooms returns every room with its members
+	LoadRooms() ([]RoomRecord, error)
+	// LoadUsers returns every user
+	LoadUsers() ([]UserRecord, error)
+	// SaveReadPosition creates or updates how far a user has received and read a room
+	SaveReadPosition(pos ReadPositionRecord) error
+	// LoadReadPositions returns every read position
+	LoadReadPositions() ([]ReadPositionRecord, error)
+	// SaveDirectMessage keeps a delivered direct message
+	SaveDirectMessage(msg *pb.ChatMessage) error
+	// LoadDirectMessages returns the newest direct messages a user sent or received, oldest first
+	LoadDirectMessages(username string, limit int) ([]*pb.ChatMessage, error)
+}
+
+// A room as kept by a MetadataStore
+type RoomRecord struct {
+	Name        string
+	Description string
+	CreatedBy   string
+	CreatedAt   time.Time
+	Retention   *pb.RetentionPolicy // Nil follows the server's policy
+	Members     []string            // Only filled in by LoadRooms
+}
+
+// A user and the presence they chose, as kept by a MetadataStore
+type UserRecord struct {
+	Username     string
+	JoinedAt     time.Time
+	LastSeen     time.Time
+	MessageCount int64
+	Presence     string           // Empty when the user never chose one
+	Custom       *pb.CustomStatus // Nil without a custom status
+}
+
+// How far a user has received and read a room, as kept by a MetadataStore
+type ReadPositionRecord struct {
+	Room      string
+	Username  string
+	Delivered int64
+	Read      int64
+}
+
+// Open the store described by a -store flag: "memory", or "file", "sqlite" or "wal" with an optional ":path"
+func openStore(spec, dataDir string) (MessageStore, error) {
+	kind, path, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "memory":
+		return newMemoryStore(maxCachedMessages), nil
+	case "file":
+		if path == "" {
+			path = filepath.Join(dataDir, messagesFile)
+		}
+		return openFileStore(path)
+	case "sqlite":
+		if path == "" {
+			path = filepath.Join(dataDir, sqliteFile)
+		}
+		return openSQLiteStore(path)
+	case "wal":
+		if path == "" {
+			path = filepath.Join(dataDir, walDir)
+		}
+		return openWALStore(path)
+	}
+	return nil, fmt.Errorf("unknown message store %q", spec)
+}
+
+// Turn a store error into a gRPC status, failures that aren't the caller's fault are logged
+func storeStatus(err error, id string) error {
+	if errors.Is(err, errMessageNotFound) {
+		return status.Errorf(codes.NotFound, "message %s not found", id)
+	}
+	log.Printf("Message store error: %v", err)
+	return status.Error(codes.Internal, "message store unavailable")
+}
+
+// Messages of one room in sequence order
+type roomMessages struct {
+	messages []*pb.ChatMessage
+	bounds   RoomBounds
+}
+
+// Find the position of a sequence, or where it would be inserted
+func (r *roomMessages) search(sequence int64) int {
+	return sort.Search(len(r.messages), func(i int) bool { return r.messages[i].Sequence >= sequence })
+}
+
+// memoryStore keeps messages in memory, optionally only the most recent ones of each room
+type memoryStore struct {
+	mu      sync.RWMutex
+	rooms   map[string]*roomMessages
+	index   map[string]string // Maps message ID to its room
+	perRoom int               // Messages kept per room, zero keeps all of them
+	trimmed func(msg *pb.ChatMessage)
+}
+
+func newMemoryStore(perRoom int) *memoryStore {
+	return &memoryStore{
+		rooms:   make(map[string]*roomMessages),
+		index:   make(map[string]string),
+		perRoom: perRoom,
+	}
+}
+
+// Get the messages of a room, creating them if needed, caller must hold m.mu
+func (m *memoryStore) room(name string) *roomMessages {
+	room, ok := m.rooms[name]
+	if !ok {
+		room = &roomMessages{}
+		m.rooms[name] = room
+	}
+	return room
+}
+
+// Find a message and its position, caller must hold m.mu
+func (m *memoryStore) locate(id string) (*roomMessages, int, bool) {
+	name, ok := m.index[id]
+	if !ok {
+		return nil, 0, false
+	}
+	room := m.rooms[name]
+	for i, msg := range room.messages {
+		if msg.Id == id {
+			return room, i, true
+		}
+	}
+	return nil, 0, false
+}
+
+func (m *memoryStore) Append(msg *pb.ChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room := m.room(msg.Room)
+	room.messages = append(room.messages, msg)
+	m.index[msg.Id] = msg.Room
+	if msg.Sequence > room.bounds.Last {
+		room.bounds.Last = msg.Sequence
+	}
+
+	// Drop the oldest message once the room is full
+	if m.perRoom > 0 && len(room.messages) > m.perRoom {
+		oldest := room.messages[0]
+		delete(m.index, oldest.Id)
+		room.bounds.Trimmed = oldest.Sequence
+		room.messages = room.messages[1:]
+		if m.trimmed != nil {
+			m.trimmed(oldest)
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) OnTrim(trimmed func(msg *pb.ChatMessage)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.trimmed = trimmed
+}
+
+func (m *memoryStore) Range(r MessageRange) ([]*pb.ChatMessage, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	room, ok := m.rooms[r.Room]
+	if !ok {
+		return nil, false, nil
+	}
+	start, end := 0, len(room.messages)
+	if r.After > 0 {
+		start = room.search(r.After + 1)
+	}
+	if r.Before > 0 {
+		end = room.search(r.Before)
+	}
+	if end < start {
+		end = start
+	}
+
+	matches := room.messages[start:end]
+	if r.SentBefore > 0 {
+		matches = nil
+		for _, msg := range room.messages[start:end] {
+			if msg.SentAt != 0 && msg.SentAt < r.SentBefore {
+				matches = append(matches, msg)
+			}
+		}
+	}
+
+	hasMore := false
+	if r.Limit > 0 && len(matches) > r.Limit {
+		hasMore = true
+		if r.After > 0 {
+			matches = matches[:r.Limit]
+		} else {
+			matches = matches[len(matches)-r.Limit:]
+		}
+	}
+
+	page := make([]*pb.ChatMessage, len(matches))
+	copy(page, matches)
+	return page, hasMore, nil
+}
+
+func (m *memoryStore) Get(id string) (*pb.ChatMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	room, i, ok := m.locate(id)
+	if !ok {
+		return nil, errMessageNotFound
+	}
+	return room.messages[i], nil
+}
+
+func (m *memoryStore) Update(msg *pb.ChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, i, ok := m.locate(msg.Id)
+	if !ok {
+		return errMessageNotFound
+	}
+	room.messages[i] = msg
+	return nil
+}
+
+func (m *memoryStore) Delete(id string) (*pb.ChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, i, ok := m.locate(id)
+	if !ok {
+		return nil, errMessageNotFound
+	}
+	deleted := room.messages[i]
+	// Build a new slice so pages already handed out keep their backing array
+	remaining := make([]*pb.ChatMessage, 0, len(room.messages)-1)
+	remaining = append(remaining, room.messages[:i]...)
+	room.messages = append(remaining, room.messages[i+1:]...)
+	delete(m.index, id)
+	return deleted, nil
+}
+
+func (m *memoryStore) Purge(id string) (*pb.ChatMessage, error) {
+	deleted, err := m.Delete(id)
+	if err != nil {
+		return nil, err
+	}
+	m.trim(deleted.Room, deleted.Sequence)
+	return deleted, nil
+}
+
+// Record that the messages of a room up to a sequence are gone
+func (m *memoryStore) trim(room string, sequence int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bounds := &m.room(room).bounds
+	if sequence > bounds.Trimmed {
+		bounds.Trimmed = sequence
+	}
+}
+
+func (m *memoryStore) Bounds(room string) (RoomBounds, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if r, ok := m.rooms[room]; ok {
+		return r.bounds, nil
+	}
+	return RoomBounds{}, nil
+}
+
+func (m *memoryStore) Rooms() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rooms := make([]string, 0, len(m.rooms))
+	for name := range m.rooms {
+		rooms = append(rooms, name)
+	}
+	sort.Strings(rooms)
+	return rooms, nil
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
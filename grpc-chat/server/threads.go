@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Point a reply at the root of its thread, replies to replies join the same thread.
+// Returns false if the parent isn't a stored message in the reply's room.
+func (s *server) resolveThreadRoot(msg *pb.ChatMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, err := s.messages.Get(msg.ParentId)
+	if err != nil || parent.Room != msg.Room {
+		return false
+	}
+	if parent.ParentId != "" {
+		msg.ParentId = parent.ParentId
+	}
+	return true
+}
+
+// Change the reply count of a thread root and notify the room, caller must hold s.mu
+func (s *server) updateReplyCount(room, rootID string, delta int32, members []string) {
+	msg, err := s.messages.Get(rootID)
+	if err != nil || msg.Room != room {
+		return
+	}
+
+	// Replace rather than mutate, history pages may still be sending the old message
+	root := proto.Clone(msg).(*pb.ChatMessage)
+	root.ReplyCount += delta
+	if root.ReplyCount < 0 {
+		root.ReplyCount = 0
+	}
+	if err := s.messages.Update(root); err != nil {
+		log.Printf("Error updating reply count of %s: %v", rootID, err)
+		return
+	}
+
+	event := &pb.ChatMessage{
+		Id:         root.Id,
+		Sequence:   root.Sequence,
+		Room:       root.Room,
+		Sender:     root.Sender,
+		Timestamp:  root.Timestamp,
+		ReplyCount: root.ReplyCount,
+		Type:       pb.ChatMessage_THREAD_UPDATE,
+	}
+	s.sendToMembers(members, event)
+}
+
+// GetThread returns a thread root and all of its stored replies
+func (s *server) GetThread(ctx context.Context, req *pb.GetThreadRequest) (*pb.GetThreadResponse, error) {
+	if req.Username == "" || req.MessageId == "" {
+		return nil, status.Error(codes.InvalidArgument, "username and message_id are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, err := s.findMessage(req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	room := root.Room
+	if !s.isRoomMember(room, req.Username) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %s is not in room %s", req.Username, room)
+	}
+
+	// Asking for a reply returns the whole thread it belongs to
+	if root.ParentId != "" {
+		thread, err := s.messages.Get(root.ParentId)
+		if errors.Is(err, errMessageNotFound) {
+			return nil, status.Errorf(codes.NotFound, "thread %s not found", root.ParentId)
+		}
+		if err != nil {
+			return nil, storeStatus(err, root.ParentId)
+		}
+		root = thread
+	}
+
+	// Replies always come after their root
+	messages, _, err := s.messages.Range(MessageRange{Room: room, After: root.Sequence})
+	if err != nil {
+		return nil, storeStatus(err, req.MessageId)
+	}
+	var replies []*pb.ChatMessage
+	for _, msg := range messages {
+		if msg.ParentId == root.Id {
+			replies = append(replies, msg)
+		}
+	}
+	replies = s.visibleMessages(req.Username, replies)
+
+	// A root from a blocked user only keeps what places it, the replies still need it
+	if s.hasBlocked(req.Username, root.Sender) {
+		root = &pb.ChatMessage{
+			Id:         root.Id,
+			Sequence:   root.Sequence,
+			Room:       root.Room,
+			Sender:     root.Sender,
+			Timestamp:  root.Timestamp,
+			SentAt:     root.SentAt,
+			ReplyCount: root.ReplyCount,
+		}
+	}
+
+	log.Printf("Thread %s for %s: %d replies", root.Id, req.Username, len(replies))
+
+	return &pb.GetThreadResponse{
+		Root:    root,
+		Replies: replies,
+	}, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	pb "grpc-chat/proto"
+)
+
+// A typing indicator expires unless the client refreshes it within this time
+const typingTTL = 6 * time.Second
+
+// A user typing in a room or a direct conversation
+type typingEntry struct {
+	username  string
+	room      string // Empty for direct conversations
+	recipient string // Set for direct conversations
+	deadline  time.Time
+	timer     *time.Timer
+}
+
+// Key a typing indicator by user and the room or conversation it belongs to
+func typingKey(username, room, recipient string) string {
+	if recipient != "" {
+		return username + "|dm|" + recipient
+	}
+	return username + "|room|" + room
+}
+
+// Handle a typing message from a client, only starting and stopping are relayed
+func (s *server) updateTyping(msg *pb.ChatMessage) {
+	if msg.Recipient == "" && !s.isRoomMember(msg.Room, msg.Sender) {
+		return
+	}
+	key := typingKey(msg.Sender, msg.Room, msg.Recipient)
+
+	s.typingMutex.Lock()
+	entry, typing := s.typing[key]
+	switch {
+	case msg.Typing && typing:
+		// Refreshing an indicator that is already shown stays quiet
+		entry.deadline = time.Now().Add(typingTTL)
+		entry.timer.Reset(typingTTL)
+		s.typingMutex.Unlock()
+		return
+	case msg.Typing:
+		entry = &typingEntry{
+			username:  msg.Sender,
+			room:      msg.Room,
+			recipient: msg.Recipient,
+			deadline:  time.Now().Add(typingTTL),
+		}
+		entry.timer = time.AfterFunc(typingTTL, func() { s.expireTyping(key, entry) })
+		s.typing[key] = entry
+	case typing:
+		entry.timer.Stop()
+		delete(s.typing, key)
+	default:
+		// Stopping without having started
+		s.typingMutex.Unlock()
+		return
+	}
+	s.typingMutex.Unlock()
+
+	s.sendTypingEvent(entry, msg.Typing)
+}
+
+// Clear an indicator the client stopped refreshing
+func (s *server) expireTyping(key string, entry *typingEntry) {
+	s.typingMutex.Lock()
+	// A refresh may have raced with the timer firing
+	expired := s.typing[key] == entry && !time.Now().Before(entry.deadline)
+	if expired {
+		delete(s.typing, key)
+	}
+	s.typingMutex.Unlock()
+
+	if expired {
+		log.Printf("Typing indicator of %s expired", entry.username)
+		s.sendTypingEvent(entry, false)
+	}
+}
+
+// Clear the indicator of a user in the room or conversation of a message they just sent
+func (s *server) stopTyping(msg *pb.ChatMessage) {
+	s.updateTyping(&pb.ChatMessage{
+		Sender:    msg.Sender,
+		Room:      msg.Room,
+		Recipient: msg.Recipient,
+		Type:      pb.ChatMessage_TYPING,
+	})
+}
+
+// Clear every indicator of a user that left
+func (s *server) clearTyping(username string) {
+	var cleared []*typingEntry
+
+	s.typingMutex.Lock()
+	for key, entry := range s.typing {
+		if entry.username == username {
+			entry.timer.Stop()
+			delete(s.typing, key)
+			cleared = append(cleared, entry)
+		}
+	}
+	s.typingMutex.Unlock()
+
+	for _, entry := range cleared {
+		s.sendTypingEvent(entry, false)
+	}
+}
+
+// Tell the room or the recipient that a user started or stopped typing
+func (s *server) sendTypingEvent(entry *typingEntry, typing bool) {
+	event := &pb.ChatMessage{
+		Sender:    entry.username,
+		Room:      entry.room,
+		Recipient: entry.recipient,
+		Type:      pb.ChatMessage_TYPING,
+		Typing:    typing,
+	}
+
+	targets := []string{entry.recipient}
+	if entry.recipient == "" {
+		targets = s.roomMembers(entry.room)
+	}
+	// Users who blocked the typist only miss the start, a stop still clears an indicator shown before the block
+	if typing {
+		targets = s.withoutBlockers(targets, entry.username)
+	}
+	// The typist's own sessions don't need to hear about it
+	others := make([]string, 0, len(targets))
+	for _, user := range targets {
+		if user != entry.username {
+			others = append(others, user)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendToMembers(others, event)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// What the server remembers about a user across sessions
+type userRecord struct {
+	joinedAt     time.Time // When the latest session joined
+	lastSeen     time.Time // When the user was last connected
+	messageCount int64
+}
+
+// Get the record of a user, creating it if needed, caller must hold activeUsersMutex
+func (s *server) userRecordFor(username string) *userRecord {
+	record, ok := s.userRecords[username]
+	if !ok {
+		record = &userRecord{}
+		s.userRecords[username] = record
+	}
+	return record
+}
+
+// Remember that a user joined the chat
+func (s *server) recordJoin(username string) {
+	s.activeUsersMutex.Lock()
+	now := time.Now()
+	record := s.userRecordFor(username)
+	record.joinedAt = now
+	record.lastSeen = now
+	s.activeUsersMutex.Unlock()
+
+	s.saveUser(username)
+}
+
+// Remember when a user left the chat
+func (s *server) recordLeft(username string) {
+	s.activeUsersMutex.Lock()
+	s.userRecordFor(username).lastSeen = time.Now()
+	s.activeUsersMutex.Unlock()
+
+	s.saveUser(username)
+}
+
+// Count a message sent by a user
+func (s *server) recordMessage(username string) {
+	s.activeUsersMutex.Lock()
+	s.userRecordFor(username).messageCount++
+	s.activeUsersMutex.Unlock()
+
+	s.saveUser(username)
+}
+
+// GetUserProfile reports whether a user is online, their status and their activity
+func (s *server) GetUserProfile(ctx context.Context, req *pb.GetUserProfileRequest) (*pb.UserProfile, error) {
+	if req.Target == "" {
+		return nil, status.Error(codes.InvalidArgument, "target is required")
+	}
+
+	s.activeUsersMutex.RLock()
+	record, known := s.userRecords[req.Target]
+	online := s.activeUsers[req.Target]
+	var profile *pb.UserProfile
+	if known {
+		profile = &pb.UserProfile{
+			Username:     req.Target,
+			JoinedAt:     record.joinedAt.Unix(),
+			LastSeen:     record.lastSeen.Unix(),
+			MessageCount: record.messageCount,
+		}
+	}
+	s.activeUsersMutex.RUnlock()
+
+	if !known {
+		return nil, status.Errorf(codes.NotFound, "user %s has never joined", req.Target)
+	}
+	profile.Profile = s.profileInfo(req.Target)
+
+	profile.Status = "offline"
+	if online {
+		statuses, customs := s.statusSnapshot([]string{req.Target})
+		// Invisible users look offline to everyone but themselves, blocked users always do
+		visible := statuses[req.Target] != presenceInvisible || req.Username == req.Target
+		if visible && !s.blockedBetween(req.Username, req.Target) {
+			profile.Online = true
+			profile.Status = statuses[req.Target]
+			profile.CustomStatus = customs[req.Target]
+			profile.LastSeen = time.Now().Unix()
+		}
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,1059 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "grpc-chat/proto"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	walDir             = "wal"
+	walSegmentExt      = ".wal"
+	walSnapshotExt     = ".snapshot"
+	walSegmentBytes    = 4 << 20  // A new segment is started once the current one grows past this
+	walSnapshotRecords = 1000     // Records written between snapshots
+	walMaxRecordBytes  = 16 << 20 // Larger lengths can only come from a damaged header
+	walHeaderBytes     = 8        // Payload length and CRC-32C, both big endian
+)
+
+var walChecksums = crc32.MakeTable(crc32.Castagnoli)
+
+// errTornRecord is returned when a record was cut short or fails its checksum
+var errTornRecord = errors.New("torn record")
+
+// A record of the write-ahead log, numbered by its log sequence number
+type walRecord struct {
+	LSN      uint64          `json:"lsn"`
+	Op       string          `json:"op"` // append, update, delete, purge, room, member, user, position or direct
+	Message  json.RawMessage `json:"message,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Room     *walRoom        `json:"room,omitempty"`
+	Member   *walMember      `json:"member,omitempty"`
+	User     *walUser        `json:"user,omitempty"`
+	Position *walPosition    `json:"position,omitempty"`
+}
+
+type walRoom struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	CreatedBy   string        `json:"created_by,omitempty"`
+	CreatedAt   int64         `json:"created_at,omitempty"`
+	Retention   *walRetention `json:"retention,omitempty"`
+	Members     []string      `json:"members,omitempty"` // Only in snapshots, the log has member records
+}
+
+type walRetention struct {
+	MaxAge      int64 `json:"max_age,omitempty"`
+	MaxMessages int32 `json:"max_messages,omitempty"`
+	KeepForever bool  `json:"keep_forever,omitempty"`
+}
+
+type walMember struct {
+	Room     string `json:"room"`
+	Username string `json:"username"`
+	Member   bool   `json:"member"`
+}
+
+type walUser struct {
+	Username        string `json:"username"`
+	JoinedAt        int64  `json:"joined_at,omitempty"`
+	LastSeen        int64  `json:"last_seen,omitempty"`
+	MessageCount    int64  `json:"message_count,omitempty"`
+	Presence        string `json:"presence,omitempty"`
+	CustomText      string `json:"custom_text,omitempty"`
+	CustomEmoji     string `json:"custom_emoji,omitempty"`
+	CustomExpiresAt int64  `json:"custom_expires_at,omitempty"`
+}
+
+type walPosition struct {
+	Room      string `json:"room"`
+	Username  string `json:"username"`
+	Delivered int64  `json:"delivered,omitempty"`
+	Read      int64  `json:"read,omitempty"`
+}
+
+// Everything the log describes up to and including LSN
+type walSnapshot struct {
+	LSN       uint64            `json:"lsn"`
+	Messages  []json.RawMessage `json:"messages"`
+	Last      map[string]int64  `json:"last"`              // Highest sequence appended to each room
+	Trimmed   map[string]int64  `json:"trimmed,omitempty"` // Highest sequence purged from each room
+	Rooms     []walRoom         `json:"rooms"`
+	Users     []walUser         `json:"users"`
+	Positions []walPosition     `json:"positions,omitempty"`
+	Direct    []json.RawMessage `json:"direct,omitempty"` // Direct messages in the order they were sent
+
+	messages []*pb.ChatMessage // Captured messages, marshaled into Messages when the snapshot is written
+	direct   []*pb.ChatMessage // Captured direct messages, marshaled into Direct
+}
+
+// walStore keeps messages, rooms, users, read positions and direct messages in memory. Every change is appended to a segmented log
+// that is replayed on startup after loading the latest snapshot.
+type walStore struct {
+	*memoryStore
+	mu            sync.Mutex     // Serializes writes so the log has changes in the order they were applied
+	snapshotMu    sync.Mutex     // Held while snapshots are written and old files dropped, taken before mu
+	snapshots     sync.WaitGroup // Snapshots being written in the background
+	snapshotting  bool           // A snapshot is being written in the background, guarded by mu
+	written       uint64         // Last record covered by a snapshot written since opening, guarded by snapshotMu
+	dir           string
+	segment       *os.File // Segment being appended to
+	segmentBytes  int64
+	lsn           uint64 // Last record written
+	sinceSnapshot int    // Records written since the last snapshot
+	failed        error  // Set when a failed write couldn't be undone, no more records are accepted
+	deleted       int    // Delete records applied since the log was last compacted
+	rooms         map[string]RoomRecord
+	members       map[string]map[string]bool // Maps room to its members
+	users         map[string]UserRecord
+	positions     map[string]map[string]ReadPositionRecord // Maps room to username to read position
+	direct        []*pb.ChatMessage                        // Direct messages in the order they were sent
+}
+
+// Open a write-ahead log directory, creating it if needed, and rebuild the state it describes
+func openWALStore(dir string) (*walStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &walStore{
+		memoryStore: newMemoryStore(0),
+		dir:         dir,
+		rooms:       make(map[string]RoomRecord),
+		members:     make(map[string]map[string]bool),
+		users:       make(map[string]UserRecord),
+		positions:   make(map[string]map[string]ReadPositionRecord),
+	}
+	if err := w.recover(); err != nil {
+		if w.segment != nil {
+			w.segment.Close()
+		}
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	return w, nil
+}
+
+// List the files of the log directory with the given extension, ordered by the LSN in their names
+func (w *walStore) list(ext string) ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var lsns []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ext)
+		if !ok {
+			continue
+		}
+		lsn, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		lsns = append(lsns, lsn)
+	}
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+	return lsns, nil
+}
+
+// Path of the segment starting at an LSN, or of the snapshot up to it
+func (w *walStore) path(lsn uint64, ext string) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", lsn, ext))
+}
+
+// Load the newest readable snapshot and replay the segments after it.
+// A torn record at the end of the last segment, with nothing intact after it, is what a crash mid-write
+// leaves behind and is truncated. Damage anywhere else means records are lost and stops the server.
+func (w *walStore) recover() error {
+	snapshots, err := w.list(walSnapshotExt)
+	if err != nil {
+		return err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		err := w.loadSnapshot(snapshots[i])
+		if err == nil {
+			break
+		}
+		// An older snapshot is kept for this, the segments after it are still around. The damaged one is
+		// moved aside so it isn't counted as the previous snapshot when old segments are dropped.
+		log.Printf("Skipping snapshot %d of the write-ahead log: %v", snapshots[i], err)
+		path := w.path(snapshots[i], walSnapshotExt)
+		if err := os.Rename(path, path+".damaged"); err != nil {
+			return err
+		}
+		w.reset()
+	}
+
+	segments, err := w.list(walSegmentExt)
+	if err != nil {
+		return err
+	}
+	records := 0
+	for i, first := range segments {
+		last := i == len(segments)-1
+		// Skip segments the snapshot covers completely
+		if !last && segments[i+1] <= w.lsn+1 {
+			continue
+		}
+		n, err := w.replay(first, last)
+		records += n
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", first, err)
+		}
+	}
+	log.Printf("Recovered the write-ahead log up to record %d, %d records replayed", w.lsn, records)
+
+	if len(segments) > 0 {
+		return w.openSegment(segments[len(segments)-1])
+	}
+	return w.openSegment(w.lsn + 1)
+}
+
+// Forget state loaded from a damaged snapshot
+func (w *walStore) reset() {
+	w.memoryStore = newMemoryStore(0)
+	w.lsn = 0
+	w.rooms = make(map[string]RoomRecord)
+	w.members = make(map[string]map[string]bool)
+	w.users = make(map[string]UserRecord)
+	w.positions = make(map[string]map[string]ReadPositionRecord)
+	w.direct = nil
+}
+
+// Apply the records of a segment that come after the state in memory
+func (w *walStore) replay(first uint64, last bool) (int, error) {
+	path := w.path(first, walSegmentExt)
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	records := 0
+	for {
+		start := offset
+		payload, err := readFrame(reader)
+		if err == io.EOF {
+			return records, nil
+		}
+		if errors.Is(err, errTornRecord) && last {
+			intact, err := intactFrameAfter(file, start)
+			if err != nil {
+				return records, err
+			}
+			if intact {
+				return records, fmt.Errorf("offset %d: %w followed by intact records", start, errTornRecord)
+			}
+			log.Printf("Truncating a torn record at offset %d of write-ahead log segment %d", start, first)
+			if err := file.Truncate(start); err != nil {
+				return records, err
+			}
+			return records, file.Sync()
+		}
+		if err != nil {
+			return records, fmt.Errorf("offset %d: %w", start, err)
+		}
+		offset += int64(walHeaderBytes + len(payload))
+
+		var record walRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return records, fmt.Errorf("offset %d: %w", start, err)
+		}
+		if record.LSN <= w.lsn {
+			continue
+		}
+		if record.LSN != w.lsn+1 {
+			return records, fmt.Errorf("offset %d: expected record %d, found %d", start, w.lsn+1, record.LSN)
+		}
+		if err := w.apply(&record); err != nil {
+			return records, fmt.Errorf("record %d: %w", record.LSN, err)
+		}
+		w.lsn = record.LSN
+		records++
+	}
+}
+
+// Check whether an intact record starts anywhere after a damaged one at offset. A crash only ever
+// damages the last record written, so one that is followed by intact records was corrupted on disk.
+func intactFrameAfter(file *os.File, offset int64) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	rest := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(rest, offset); err != nil {
+		return false, err
+	}
+	for i := 1; i+walHeaderBytes <= len(rest); i++ {
+		length := int(binary.BigEndian.Uint32(rest[i : i+4]))
+		end := i + walHeaderBytes + length
+		if length == 0 || length > walMaxRecordBytes || end > len(rest) {
+			continue
+		}
+		if crc32.Checksum(rest[i+walHeaderBytes:end], walChecksums) == binary.BigEndian.Uint32(rest[i+4:i+8]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Read one length and checksum framed payload
+func readFrame(reader io.Reader) ([]byte, error) {
+	header := make([]byte, walHeaderBytes)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF && n == 0 {
+			return nil, io.EOF
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, errTornRecord
+		}
+		return nil, err
+	}
+	// Records are never empty, a zero length comes from a tail the file system filled with zeros
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length == 0 || length > walMaxRecordBytes {
+		return nil, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTornRecord
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, walChecksums) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errTornRecord
+	}
+	return payload, nil
+}
+
+// Frame a payload with its length and checksum
+func frame(payload []byte) []byte {
+	data := make([]byte, walHeaderBytes, walHeaderBytes+len(payload))
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(data[4:8], crc32.Checksum(payload, walChecksums))
+	return append(data, payload...)
+}
+
+// Apply a record to the state in memory
+func (w *walStore) apply(record *walRecord) error {
+	switch record.Op {
+	case "append", "update":
+		msg := &pb.ChatMessage{}
+		if err := protojson.Unmarshal(record.Message, msg); err != nil {
+			return err
+		}
+		if record.Op == "append" {
+			return w.memoryStore.Append(msg)
+		}
+		return w.memoryStore.Update(msg)
+	case "delete", "purge":
+		remove := w.memoryStore.Delete
+		if record.Op == "purge" {
+			remove = w.memoryStore.Purge
+		}
+		if _, err := remove(record.ID); err != nil {
+			return err
+		}
+		w.deleted++
+		return nil
+	case "room":
+		if record.Room == nil {
+			break
+		}
+		w.rooms[record.Room.Name] = record.Room.record()
+		return nil
+	case "member":
+		if record.Member == nil {
+			break
+		}
+		m := record.Member
+		if m.Member {
+			if w.members[m.Room] == nil {
+				w.members[m.Room] = make(map[string]bool)
+			}
+			w.members[m.Room][m.Username] = true
+		} else {
+			delete(w.members[m.Room], m.Username)
+		}
+		return nil
+	case "user":
+		if record.User == nil {
+			break
+		}
+		w.users[record.User.Username] = record.User.record()
+		return nil
+	case "position":
+		if record.Position == nil {
+			break
+		}
+		w.setPosition(record.Position.record())
+		return nil
+	case "direct":
+		msg := &pb.ChatMessage{}
+		if err := protojson.Unmarshal(record.Message, msg); err != nil {
+			return err
+		}
+		w.direct = append(w.direct, msg)
+		return nil
+	}
+	return fmt.Errorf("invalid %q record", record.Op)
+}
+
+// Open a segment for appending, creating it if needed
+func (w *walStore) openSegment(first uint64) error {
+	file, err := os.OpenFile(w.path(first, walSegmentExt), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		file.Close()
+		return err
+	}
+	if w.segment != nil {
+		w.segment.Close()
+	}
+	w.segment = file
+	w.segmentBytes = info.Size()
+	return nil
+}
+
+// Number the record, append it to the log, flush it to disk and apply it, caller must hold w.mu.
+// A snapshot is started in the background once enough records were added since the last one.
+func (w *walStore) write(record *walRecord) error {
+	if w.failed != nil {
+		return fmt.Errorf("write-ahead log stopped after an earlier failure: %w", w.failed)
+	}
+	if w.segmentBytes >= walSegmentBytes {
+		if err := w.segment.Sync(); err != nil {
+			return err
+		}
+		if err := w.openSegment(w.lsn + 1); err != nil {
+			return err
+		}
+	}
+
+	record.LSN = w.lsn + 1
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data := frame(payload)
+	if _, err := w.segment.Write(data); err != nil {
+		return w.discard(err, false)
+	}
+	if err := w.segment.Sync(); err != nil {
+		// After a failed sync the kernel may have dropped earlier pages as well, a retry could wrongly succeed
+		return w.discard(err, true)
+	}
+	w.segmentBytes += int64(len(data))
+	w.lsn = record.LSN
+
+	if err := w.apply(record); err != nil {
+		return err
+	}
+
+	w.sinceSnapshot++
+	if w.sinceSnapshot >= walSnapshotRecords && !w.snapshotting {
+		if err := w.startSnapshot(); err != nil {
+			// The log still has every record, the next attempt covers these too
+			log.Printf("Failed to write a snapshot of the write-ahead log: %v", err)
+		}
+	}
+	return nil
+}
+
+// Capture the state for a snapshot and write it in a goroutine, caller must hold w.mu.
+// Writers often hold the server's lock as well, so only copying the state happens under the lock.
+func (w *walStore) startSnapshot() error {
+	snap, err := w.rotate()
+	if err != nil {
+		return err
+	}
+	w.snapshotting = true
+	w.snapshots.Add(1)
+	go func() {
+		defer w.snapshots.Done()
+		w.snapshotMu.Lock()
+		err := w.writeSnapshot(snap)
+		w.snapshotMu.Unlock()
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.snapshotting = false
+		if err != nil {
+			log.Printf("Failed to write a snapshot of the write-ahead log: %v", err)
+			w.sinceSnapshot = walSnapshotRecords
+		}
+	}()
+	return nil
+}
+
+// Cut a record that failed to be written off the segment so the next one doesn't follow a partial frame
+// or reuse its LSN unnoticed, caller must hold w.mu. When that fails too, or stop is set, the log can't
+// be trusted anymore and later writes are refused.
+func (w *walStore) discard(err error, stop bool) error {
+	if terr := w.segment.Truncate(w.segmentBytes); terr != nil {
+		log.Printf("Failed to undo a partial record in the write-ahead log: %v", terr)
+		stop = true
+	}
+	if stop {
+		w.failed = err
+		log.Printf("Write-ahead log stopped accepting records: %v", err)
+	}
+	return err
+}
+
+// Write the state in memory to a snapshot and drop segments older snapshots no longer need, caller must hold
+// w.snapshotMu and w.mu.
+func (w *walStore) snapshot() error {
+	snap, err := w.rotate()
+	if err != nil {
+		return err
+	}
+	return w.writeSnapshot(snap)
+}
+
+// Capture the state in memory and move new records to a new segment, so the segments before it are
+// fully covered by the snapshot. Caller must hold w.mu.
+func (w *walStore) rotate() (*walSnapshot, error) {
+	snap := w.capture()
+	if err := w.segment.Sync(); err != nil {
+		return nil, err
+	}
+	if err := w.openSegment(w.lsn + 1); err != nil {
+		return nil, err
+	}
+	w.sinceSnapshot = 0
+	return snap, nil
+}
+
+// Write a captured snapshot and drop segments older snapshots no longer need, caller must hold w.snapshotMu.
+// The previous snapshot and the segments after it are kept in case the new one can't be read.
+func (w *walStore) writeSnapshot(snap *walSnapshot) error {
+	// A newer one may have been written in the meantime, e.g. by Compact, and the files it needs dropped
+	if snap.LSN <= w.written {
+		return nil
+	}
+	started := time.Now()
+	for _, msg := range snap.messages {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		snap.Messages = append(snap.Messages, data)
+	}
+	for _, msg := range snap.direct {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		snap.Direct = append(snap.Direct, data)
+	}
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so a crash never leaves half a snapshot behind
+	path := w.path(snap.LSN, walSnapshotExt)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, frame(payload)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+	w.written = snap.LSN
+
+	snapshots, err := w.list(walSnapshotExt)
+	if err != nil {
+		return err
+	}
+	keep := snap.LSN
+	if len(snapshots) >= 2 {
+		keep = snapshots[len(snapshots)-2]
+	}
+	for _, lsn := range snapshots {
+		if lsn < keep {
+			os.Remove(w.path(lsn, walSnapshotExt))
+		}
+	}
+	segments, err := w.list(walSegmentExt)
+	if err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i+1] <= keep+1 {
+			os.Remove(w.path(segments[i], walSegmentExt))
+		}
+	}
+
+	log.Printf("Wrote a snapshot of the write-ahead log up to record %d in %v", snap.LSN, time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// Write a snapshot and drop every older snapshot and segment, deleted messages are otherwise still in
+// them until two more snapshots were written. The new snapshot is read back before the old ones go.
+func (w *walStore) Compact() error {
+	w.snapshotMu.Lock()
+	defer w.snapshotMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.deleted == 0 || w.failed != nil {
+		return nil
+	}
+	if err := w.snapshot(); err != nil {
+		return err
+	}
+	if err := w.checkSnapshot(w.lsn); err != nil {
+		return fmt.Errorf("snapshot %d: %w", w.lsn, err)
+	}
+
+	snapshots, err := w.list(walSnapshotExt)
+	if err != nil {
+		return err
+	}
+	for _, lsn := range snapshots {
+		if lsn < w.lsn {
+			if err := os.Remove(w.path(lsn, walSnapshotExt)); err != nil {
+				return err
+			}
+		}
+	}
+	// The segment snapshot started is the only one records after it are in
+	segments, err := w.list(walSegmentExt)
+	if err != nil {
+		return err
+	}
+	for _, first := range segments {
+		if first <= w.lsn {
+			if err := os.Remove(w.path(first, walSegmentExt)); err != nil {
+				return err
+			}
+		}
+	}
+	damaged, err := filepath.Glob(filepath.Join(w.dir, "*"+walSnapshotExt+".damaged"))
+	if err != nil {
+		return err
+	}
+	for _, path := range damaged {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+
+	log.Printf("Compacted the write-ahead log after %d deletes, it starts at snapshot %d", w.deleted, w.lsn)
+	w.deleted = 0
+	return nil
+}
+
+// Check that a snapshot can be read back
+func (w *walStore) checkSnapshot(lsn uint64) error {
+	file, err := os.Open(w.path(lsn, walSnapshotExt))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	payload, err := readFrame(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	if !json.Valid(payload) {
+		return errors.New("invalid snapshot")
+	}
+	return nil
+}
+
+// Copy the state in memory into a snapshot, caller must hold w.mu.
+// Stored messages are replaced rather than changed, so they are marshaled later without the lock.
+func (w *walStore) capture() *walSnapshot {
+	snap := &walSnapshot{LSN: w.lsn, Last: make(map[string]int64), Trimmed: make(map[string]int64)}
+
+	w.memoryStore.mu.RLock()
+	for name, room := range w.memoryStore.rooms {
+		snap.Last[name] = room.bounds.Last
+		if room.bounds.Trimmed > 0 {
+			snap.Trimmed[name] = room.bounds.Trimmed
+		}
+		snap.messages = append(snap.messages, room.messages...)
+	}
+	w.memoryStore.mu.RUnlock()
+
+	for _, room := range w.rooms {
+		r := newWALRoom(room)
+		for member := range w.members[room.Name] {
+			r.Members = append(r.Members, member)
+		}
+		sort.Strings(r.Members)
+		snap.Rooms = append(snap.Rooms, *r)
+	}
+	for _, user := range w.users {
+		snap.Users = append(snap.Users, *newWALUser(user))
+	}
+	for _, positions := range w.positions {
+		for _, pos := range positions {
+			snap.Positions = append(snap.Positions, *newWALPosition(pos))
+		}
+	}
+	snap.direct = append(snap.direct, w.direct...)
+	return snap
+}
+
+// Replace the state in memory with a snapshot
+func (w *walStore) loadSnapshot(lsn uint64) error {
+	file, err := os.Open(w.path(lsn, walSnapshotExt))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	payload, err := readFrame(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	var snap walSnapshot
+	if err := json.Unmarshal(payload, &snap); err != nil {
+		return err
+	}
+
+	for _, data := range snap.Messages {
+		msg := &pb.ChatMessage{}
+		if err := protojson.Unmarshal(data, msg); err != nil {
+			return err
+		}
+		if err := w.memoryStore.Append(msg); err != nil {
+			return err
+		}
+	}
+	// Deleted messages may have had higher sequences than the ones left
+	for name, last := range snap.Last {
+		w.memoryStore.room(name).bounds.Last = last
+	}
+	for name, trimmed := range snap.Trimmed {
+		w.memoryStore.room(name).bounds.Trimmed = trimmed
+	}
+	for _, room := range snap.Rooms {
+		w.rooms[room.Name] = room.record()
+		members := make(map[string]bool, len(room.Members))
+		for _, member := range room.Members {
+			members[member] = true
+		}
+		w.members[room.Name] = members
+	}
+	for _, user := range snap.Users {
+		w.users[user.Username] = user.record()
+	}
+	for _, pos := range snap.Positions {
+		w.setPosition(pos.record())
+	}
+	for _, data := range snap.Direct {
+		msg := &pb.ChatMessage{}
+		if err := protojson.Unmarshal(data, msg); err != nil {
+			return err
+		}
+		w.direct = append(w.direct, msg)
+	}
+	w.lsn = snap.LSN
+	return nil
+}
+
+func (w *walStore) Append(msg *pb.ChatMessage) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(&walRecord{Op: "append", Message: data})
+}
+
+func (w *walStore) Update(msg *pb.ChatMessage) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.memoryStore.Get(msg.Id); err != nil {
+		return err
+	}
+	return w.write(&walRecord{Op: "update", Message: data})
+}
+
+func (w *walStore) Delete(id string) (*pb.ChatMessage, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	msg, err := w.memoryStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.write(&walRecord{Op: "delete", ID: id}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (w *walStore) Purge(id string) (*pb.ChatMessage, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	msg, err := w.memoryStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.write(&walRecord{Op: "purge", ID: id}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (w *walStore) SaveRoom(room RoomRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(&walRecord{Op: "room", Room: newWALRoom(room)})
+}
+
+func (w *walStore) SaveRoomMember(room, username string, member bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Skip records that change nothing, every join of the default room saves its membership
+	if w.members[room][username] == member {
+		return nil
+	}
+	return w.write(&walRecord{Op: "member", Member: &walMember{Room: room, Username: username, Member: member}})
+}
+
+func (w *walStore) SaveUser(user UserRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(&walRecord{Op: "user", User: newWALUser(user)})
+}
+
+func (w *walStore) LoadRooms() ([]RoomRecord, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rooms := make([]RoomRecord, 0, len(w.rooms))
+	for _, room := range w.rooms {
+		for member := range w.members[room.Name] {
+			room.Members = append(room.Members, member)
+		}
+		sort.Strings(room.Members)
+		rooms = append(rooms, room)
+	}
+	return rooms, nil
+}
+
+func (w *walStore) LoadUsers() ([]UserRecord, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	users := make([]UserRecord, 0, len(w.users))
+	for _, user := range w.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (w *walStore) SaveReadPosition(pos ReadPositionRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.positions[pos.Room][pos.Username] == pos {
+		return nil
+	}
+	return w.write(&walRecord{Op: "position", Position: newWALPosition(pos)})
+}
+
+func (w *walStore) LoadReadPositions() ([]ReadPositionRecord, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var positions []ReadPositionRecord
+	for _, room := range w.positions {
+		for _, pos := range room {
+			positions = append(positions, pos)
+		}
+	}
+	return positions, nil
+}
+
+func (w *walStore) SaveDirectMessage(msg *pb.ChatMessage) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(&walRecord{Op: "direct", Message: data})
+}
+
+func (w *walStore) LoadDirectMessages(username string, limit int) ([]*pb.ChatMessage, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var messages []*pb.ChatMessage
+	for i := len(w.direct) - 1; i >= 0 && len(messages) < limit; i-- {
+		if msg := w.direct[i]; msg.Sender == username || msg.Recipient == username {
+			messages = append(messages, msg)
+		}
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// Write a final snapshot so the next start doesn't have to replay the log
+func (w *walStore) Close() error {
+	w.snapshots.Wait()
+	w.snapshotMu.Lock()
+	defer w.snapshotMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.sinceSnapshot > 0 && w.failed == nil {
+		if err := w.snapshot(); err != nil {
+			log.Printf("Failed to write a snapshot of the write-ahead log: %v", err)
+		}
+	}
+	return w.segment.Close()
+}
+
+func newWALRoom(room RoomRecord) *walRoom {
+	r := &walRoom{
+		Name:        room.Name,
+		Description: room.Description,
+		CreatedBy:   room.CreatedBy,
+		CreatedAt:   unixOrZero(room.CreatedAt),
+	}
+	if p := room.Retention; p != nil {
+		r.Retention = &walRetention{MaxAge: p.MaxAgeSeconds, MaxMessages: p.MaxMessages, KeepForever: p.KeepForever}
+	}
+	return r
+}
+
+func (r *walRoom) record() RoomRecord {
+	room := RoomRecord{
+		Name:        r.Name,
+		Description: r.Description,
+		CreatedBy:   r.CreatedBy,
+		CreatedAt:   timeOrZero(r.CreatedAt),
+	}
+	if p := r.Retention; p != nil {
+		room.Retention = &pb.RetentionPolicy{MaxAgeSeconds: p.MaxAge, MaxMessages: p.MaxMessages, KeepForever: p.KeepForever}
+	}
+	return room
+}
+
+func newWALUser(user UserRecord) *walUser {
+	u := &walUser{
+		Username:     user.Username,
+		JoinedAt:     unixOrZero(user.JoinedAt),
+		LastSeen:     unixOrZero(user.LastSeen),
+		MessageCount: user.MessageCount,
+		Presence:     user.Presence,
+	}
+	if user.Custom != nil {
+		u.CustomText = user.Custom.Text
+		u.CustomEmoji = user.Custom.Emoji
+		u.CustomExpiresAt = user.Custom.ExpiresAt
+	}
+	return u
+}
+
+func (u *walUser) record() UserRecord {
+	user := UserRecord{
+		Username:     u.Username,
+		JoinedAt:     timeOrZero(u.JoinedAt),
+		LastSeen:     timeOrZero(u.LastSeen),
+		MessageCount: u.MessageCount,
+		Presence:     u.Presence,
+	}
+	if u.CustomText != "" || u.CustomEmoji != "" {
+		user.Custom = &pb.CustomStatus{Text: u.CustomText, Emoji: u.CustomEmoji, ExpiresAt: u.CustomExpiresAt}
+	}
+	return user
+}
+
+// Record a read position in memory, caller must hold w.mu
+func (w *walStore) setPosition(pos ReadPositionRecord) {
+	if w.positions[pos.Room] == nil {
+		w.positions[pos.Room] = make(map[string]ReadPositionRecord)
+	}
+	w.positions[pos.Room][pos.Username] = pos
+}
+
+func newWALPosition(pos ReadPositionRecord) *walPosition {
+	return &walPosition{Room: pos.Room, Username: pos.Username, Delivered: pos.Delivered, Read: pos.Read}
+}
+
+func (p *walPosition) record() ReadPositionRecord {
+	return ReadPositionRecord{Room: p.Room, Username: p.Username, Delivered: p.Delivered, Read: p.Read}
+}
+
+// Write a file and flush it to disk
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Flush a directory so files created or renamed in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
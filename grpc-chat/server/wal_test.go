@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	pb "grpc-chat/proto"
+)
+
+// Append n messages to room general, numbered after the ones already there
+func appendMessages(t *testing.T, w *walStore, n int) {
+	t.Helper()
+	bounds, err := w.Bounds("general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		seq := bounds.Last + int64(i)
+		msg := &pb.ChatMessage{Id: fmt.Sprintf("m%d", seq), Sequence: seq, Room: "general", Sender: "alice", Message: fmt.Sprintf("message %d", seq)}
+		if err := w.Append(msg); err != nil {
+			t.Fatalf("append %d: %v", seq, err)
+		}
+	}
+}
+
+// Stop using a store the way a crash would, without the snapshot Close writes
+func crash(w *walStore) {
+	w.snapshots.Wait()
+	w.segment.Close()
+}
+
+// Check that the store holds messages 1 to n of room general and has written n records
+func checkMessages(t *testing.T, w *walStore, n int) {
+	t.Helper()
+	messages, _, err := w.Range(MessageRange{Room: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != n {
+		t.Fatalf("got %d messages, want %d", len(messages), n)
+	}
+	for i, msg := range messages {
+		if msg.Sequence != int64(i+1) {
+			t.Fatalf("message %d has sequence %d", i, msg.Sequence)
+		}
+	}
+	if w.lsn != uint64(n) {
+		t.Fatalf("last record is %d, want %d", w.lsn, n)
+	}
+}
+
+func segmentPath(t *testing.T, w *walStore) string {
+	t.Helper()
+	segments, err := w.list(walSegmentExt)
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("no segments: %v", err)
+	}
+	return w.path(segments[len(segments)-1], walSegmentExt)
+}
+
+func TestWALTruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 3)
+	crash(w)
+
+	// Half of a frame, as left by a crash during the write
+	path := segmentPath(t, w)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torn := frame([]byte(`{"lsn":4,"op":"append"}`))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(torn[:len(torn)/2])
+	file.Close()
+
+	w, err = openWALStore(dir)
+	if err != nil {
+		t.Fatalf("recovering a torn tail: %v", err)
+	}
+	checkMessages(t, w, 3)
+	if info2, _ := os.Stat(path); info2.Size() != info.Size() {
+		t.Fatalf("segment is %d bytes after recovery, want %d", info2.Size(), info.Size())
+	}
+
+	// New records continue the sequence and survive another restart
+	appendMessages(t, w, 2)
+	crash(w)
+	w, err = openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	checkMessages(t, w, 5)
+}
+
+func TestWALTruncatesZeroFilledTail(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 2)
+	crash(w)
+
+	file, err := os.OpenFile(segmentPath(t, w), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(make([]byte, 64))
+	file.Close()
+
+	w, err = openWALStore(dir)
+	if err != nil {
+		t.Fatalf("recovering a zero filled tail: %v", err)
+	}
+	defer w.Close()
+	checkMessages(t, w, 2)
+}
+
+func TestWALRejectsMidSegmentCorruption(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 3)
+	crash(w)
+
+	// Flip a byte in the payload of the first record, the two after it are intact
+	path := segmentPath(t, w)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[walHeaderBytes+2] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openWALStore(dir); err == nil {
+		t.Fatal("recovered a segment with a damaged record before intact ones")
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(data) {
+		t.Fatalf("segment was truncated from %d to %d bytes", len(data), len(after))
+	}
+}
+
+func TestWALFallsBackFromDamagedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 2)
+	w.mu.Lock()
+	err = w.snapshot()
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 1)
+	w.mu.Lock()
+	err = w.snapshot()
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 1)
+	crash(w)
+
+	// Damage the newest snapshot, the previous one and the segments after it still have everything
+	newest := w.path(3, walSnapshotExt)
+	data, err := os.ReadFile(newest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(newest, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = openWALStore(dir)
+	if err != nil {
+		t.Fatalf("recovering from the previous snapshot: %v", err)
+	}
+	defer w.Close()
+	checkMessages(t, w, 4)
+	if _, err := os.Stat(newest + ".damaged"); err != nil {
+		t.Fatalf("damaged snapshot wasn't moved aside: %v", err)
+	}
+}
+
+func TestWALUndoesFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 2)
+
+	// A write that stopped halfway, the next record must not follow the partial frame
+	w.mu.Lock()
+	partial := frame([]byte(`{"lsn":3,"op":"append"}`))
+	w.segment.Write(partial[:5])
+	err = w.discard(os.ErrDeadlineExceeded, false)
+	w.mu.Unlock()
+	if err == nil || w.failed != nil {
+		t.Fatalf("discard returned %v, failed is %v", err, w.failed)
+	}
+
+	appendMessages(t, w, 1)
+	crash(w)
+	w, err = openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	checkMessages(t, w, 3)
+}
+
+func TestWALStopsAfterUndoFails(t *testing.T) {
+	w, err := openWALStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, w, 1)
+
+	// Neither the write nor the truncate can go through a closed file
+	w.segment.Close()
+	msg := &pb.ChatMessage{Id: "m2", Sequence: 2, Room: "general", Sender: "alice"}
+	if err := w.Append(msg); err == nil {
+		t.Fatal("append to a closed segment succeeded")
+	}
+	if w.failed == nil {
+		t.Fatal("store wasn't stopped after the undo failed")
+	}
+	msg = &pb.ChatMessage{Id: "m3", Sequence: 3, Room: "general", Sender: "alice"}
+	if err := w.Append(msg); err == nil {
+		t.Fatal("stopped store accepted a record")
+	}
+	if w.lsn != 1 {
+		t.Fatalf("last record is %d, want 1", w.lsn)
+	}
+	// Nothing known to have failed is written to a snapshot on the way out either
+	w.Close()
+	if snapshots, _ := w.list(walSnapshotExt); len(snapshots) != 0 {
+		t.Fatalf("stopped store wrote snapshots %v", snapshots)
+	}
+}
+
+func TestWALSnapshotsWithoutBlockingWrites(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// While a snapshot can't be written the log keeps accepting records
+	w.snapshotMu.Lock()
+	appendMessages(t, w, walSnapshotRecords+10)
+	if snapshots, _ := w.list(walSnapshotExt); len(snapshots) != 0 {
+		t.Fatalf("snapshots %v written while blocked", snapshots)
+	}
+	w.snapshotMu.Unlock()
+	w.snapshots.Wait()
+
+	snapshots, err := w.list(walSnapshotExt)
+	if err != nil || len(snapshots) != 1 || snapshots[0] != walSnapshotRecords {
+		t.Fatalf("snapshots %v, %v", snapshots, err)
+	}
+	crash(w)
+
+	w, err = openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	checkMessages(t, w, walSnapshotRecords+10)
+}
+
+func TestWALKeepsReadPositionsAndDirectMessages(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWALStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveReadPositionsAndDirectMessages(t, w)
+
+	// Once from the log and once from the snapshot Close writes
+	crash(w)
+	if w, err = openWALStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkReadPositionsAndDirectMessages(t, w)
+	w.Close()
+	if w, err = openWALStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	checkReadPositionsAndDirectMessages(t, w)
+}